@@ -0,0 +1,292 @@
+// Command bankctl manages staff accounts with direct database access. It
+// replaces the seed routes for bootstrapping and recovering admin accounts, so
+// those never have to be exposed to the internet. It reads the same
+// environment and .env file as the server.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+
+	"message-backend/internal/accounts"
+	"message-backend/internal/audit"
+	"message-backend/internal/config"
+	"message-backend/internal/database"
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+)
+
+// usage lists the commands bankctl understands
+const usage = `Usage: bankctl <command> [flags] [user]
+
+Users are named by ID, username or email. Passwords are read from standard
+input so they never show up in the process list or shell history.
+
+Commands:
+  create-super-admin -username name -email address
+                      Create the first super admin in the default organization
+  reset-password user Set a new password
+  promote [-role name] user
+                      Give a user another role, super_admin by default
+  list-users [-org id|slug] [-role name] [-inactive]
+                      List users, oldest first
+  unlock user         Reactivate a deactivated or unapproved account
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(run(os.Args[1], os.Args[2:]))
+}
+
+// run executes one command and returns the process exit code
+func run(command string, args []string) int {
+	var cmd func(db *gorm.DB, args []string) error
+	switch command {
+	case "create-super-admin":
+		cmd = createSuperAdmin
+	case "reset-password":
+		cmd = resetPassword
+	case "promote":
+		cmd = promote
+	case "list-users":
+		cmd = listUsers
+	case "unlock":
+		cmd = unlock
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("❌ Invalid configuration:\n%v", err)
+		return 1
+	}
+
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Printf("❌ Failed to initialize database: %v", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	if err := tenancy.Register(db); err != nil {
+		log.Printf("❌ Failed to register tenant scope: %v", err)
+		return 1
+	}
+	// bankctl administers every organization
+	db = db.WithContext(tenancy.System(context.Background()))
+
+	if err := cmd(db, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		log.Printf("❌ %v", err)
+		return 1
+	}
+	return 0
+}
+
+func createSuperAdmin(db *gorm.DB, args []string) error {
+	flags := newFlagSet("create-super-admin")
+	username := flags.String("username", "", "username of the super admin")
+	email := flags.String("email", "", "email address of the super admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || *email == "" {
+		return errors.New("-username and -email are required")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	var created *models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if created, err = accounts.CreateSuperAdmin(tx, *username, *email, password); err != nil {
+			return err
+		}
+		return record(tx, "create-super-admin", "cli.create_super_admin", created.ID, nil, created)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Created super admin %s (%s)", created.Username, created.ID)
+	return nil
+}
+
+func resetPassword(db *gorm.DB, args []string) error {
+	flags := newFlagSet("reset-password")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	target, err := userArg(db, flags)
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := accounts.ResetPassword(tx, target, password); err != nil {
+			return err
+		}
+		return record(tx, "reset-password", "cli.reset_password", target.ID, nil, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Reset the password of %s", target.Username)
+	return nil
+}
+
+func promote(db *gorm.DB, args []string) error {
+	flags := newFlagSet("promote")
+	role := flags.String("role", models.RoleSuperAdmin, "role to give the user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	target, err := userArg(db, flags)
+	if err != nil {
+		return err
+	}
+
+	before := *target
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := accounts.Promote(tx, target, *role); err != nil {
+			return err
+		}
+		return record(tx, "promote", "cli.promote", target.ID, before, target)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ %s is now %s", target.Username, target.Role)
+	return nil
+}
+
+func listUsers(db *gorm.DB, args []string) error {
+	flags := newFlagSet("list-users")
+	var filter accounts.UserFilter
+	flags.StringVar(&filter.Organization, "org", "", "only users of this organization, by ID or slug")
+	flags.StringVar(&filter.Role, "role", "", "only users with this role")
+	flags.BoolVar(&filter.Inactive, "inactive", false, "only deactivated or unapproved users")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	users, err := accounts.ListUsers(db, filter)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tUSERNAME\tEMAIL\tROLE\tORGANIZATION\tACTIVE\tAPPROVED\tLAST LOGIN")
+	for _, u := range users {
+		lastLogin := "never"
+		if !u.LastLogin.IsZero() {
+			lastLogin = u.LastLogin.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%t\t%t\t%s\n",
+			u.ID, u.Username, u.Email, u.Role, u.OrganizationID, u.IsActive, u.IsApproved, lastLogin)
+	}
+	return out.Flush()
+}
+
+func unlock(db *gorm.DB, args []string) error {
+	flags := newFlagSet("unlock")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	target, err := userArg(db, flags)
+	if err != nil {
+		return err
+	}
+
+	before := *target
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := accounts.Unlock(tx, target); err != nil {
+			return err
+		}
+		return record(tx, "unlock", "cli.unlock", target.ID, before, target)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Unlocked %s", target.Username)
+	return nil
+}
+
+func newFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of bankctl %s:\n", command)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// userArg loads the user named by the command's single argument
+func userArg(db *gorm.DB, flags *flag.FlagSet) (*models.User, error) {
+	if flags.NArg() != 1 {
+		return nil, fmt.Errorf("%s takes exactly one user", flags.Name())
+	}
+	target, err := accounts.FindUser(db, flags.Arg(0))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", flags.Arg(0), err)
+	}
+	return target, nil
+}
+
+// readPassword reads a password from the first line of standard input
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters")
+	}
+	return password, nil
+}
+
+// record writes the audit entry of a change, naming the operating system user who made it
+func record(db *gorm.DB, command, action string, targetID interface{}, before, after interface{}) error {
+	return audit.RecordCommand(db, operator(), "bankctl "+command, action, "user", targetID, before, after)
+}
+
+func operator() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"message-backend/internal/config"
+	"message-backend/internal/database"
+	"message-backend/internal/migrations"
+	"message-backend/internal/timeline"
+)
+
+// commandUsage lists the maintenance commands the binary understands
+const commandUsage = `Usage: backend [command]
+
+Without a command the HTTP server is started.
+
+Commands:
+  migrate up         Apply pending schema migrations
+  migrate down [n]   Revert the last n applied migrations (default 1)
+  migrate status     List migrations and when they were applied
+  reconcile-counts   Recompute customers' message_count and last_active from the messages table
+  backfill-timeline  Add timeline events for customers and messages created before the timeline existed
+  config print [--redacted]
+                     Show the effective settings and where each came from
+
+Settings are read from CONFIG_FILE (YAML or TOML) and the environment, which
+takes precedence. Secrets can be read from files named by DB_PASSWORD_FILE,
+JWT_SECRET_FILE, SUPER_ADMIN_SEED_KEY_FILE and SMTP_PASSWORD_FILE.
+`
+
+// runCommand executes a one-off maintenance command and returns the process exit code
+func runCommand(args []string) int {
+	var cmd func(cfg *config.Config) int
+	switch args[0] {
+	case "migrate":
+		cmd = func(cfg *config.Config) int { return migrate(cfg, args[1:]) }
+	case "reconcile-counts":
+		cmd = reconcileCounts
+	case "backfill-timeline":
+		cmd = backfillTimeline
+	case "config":
+		return configCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(commandUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], commandUsage)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("❌ Invalid configuration:\n%v", err)
+		return 1
+	}
+	return cmd(cfg)
+}
+
+// configCommand prints the effective configuration. Invalid settings are
+// listed after it so the output helps to fix them.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintf(os.Stderr, "config needs print\n\n%s", commandUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := flags.Bool("redacted", false, "mask secrets such as JWT_SECRET and DB_PASSWORD")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, loadErr := config.Load()
+	if err := cfg.Print(os.Stdout, *redacted); err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+	if loadErr != nil {
+		log.Printf("❌ Invalid configuration:\n%v", loadErr)
+		return 1
+	}
+	return 0
+}
+
+// reconcileCounts fixes drifted customer counters
+func reconcileCounts(cfg *config.Config) int {
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Printf("❌ Failed to initialize database: %v", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	updated, err := database.ReconcileCustomerCounters(db)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+
+	log.Printf("✅ Reconciled message counters, %d customers updated", updated)
+	return 0
+}
+
+// backfillTimeline adds timeline events for pre-existing customers and messages
+func backfillTimeline(cfg *config.Config) int {
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Printf("❌ Failed to initialize database: %v", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	added, err := timeline.Backfill(db)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+
+	log.Printf("✅ Backfilled customer timeline, %d events added", added)
+	return 0
+}
+
+// migrate applies, reverts or lists schema migrations
+func migrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "migrate needs up, down or status\n\n%s", commandUsage)
+		return 2
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "invalid number of migrations %q\n", args[1])
+			return 2
+		}
+		steps = n
+	}
+
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		log.Printf("❌ Failed to initialize database: %v", err)
+		return 1
+	}
+	defer database.CloseDB()
+
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		for _, m := range applied {
+			log.Printf("✅ Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			log.Printf("✅ Database is up to date")
+		}
+	case "down":
+		reverted, err := runner.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("✅ Reverted %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return 1
+		}
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", args[0], commandUsage)
+		return 2
+	}
+	return 0
+}
+
+// migrateOnStart applies pending migrations before the server starts or, when
+// MIGRATE_ON_START is off, refuses to start on an outdated schema
+func migrateOnStart(cfg *config.Config, db *gorm.DB) error {
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !cfg.MigrateOnStart {
+		if err := runner.Check(ctx); err != nil {
+			return fmt.Errorf("%w; run \"migrate up\" first", err)
+		}
+		return nil
+	}
+
+	applied, err := runner.Up(ctx)
+	for _, m := range applied {
+		slog.Info("Applied migration", slog.Int64("version", m.Version), slog.String("name", m.Name))
+	}
+	return err
+}
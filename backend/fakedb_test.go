@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+)
+
+// fakeDB stands in for Postgres behind the routes that don't go through the
+// repositories. It serves roles, organizations and seeded tables from
+// fixtures, answers every other query with no rows, accepts every write, and
+// records each statement.
+type fakeDB struct {
+	mu         sync.Mutex
+	tables     map[string]fakeTable
+	stubs      map[string]fakeTable
+	statements []fakeStatement
+}
+
+// fakeTable is the fixed content of a table
+type fakeTable struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// fakeStatement is a statement a handler ran
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("fakepg", fakeDriver{})
+}
+
+// fromTable finds the tables a statement reads or writes
+var fromTable = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|INTO|UPDATE)\s+"?(\w+)"?`)
+
+// byID finds a lookup by primary key
+var byID = regexp.MustCompile(`(?:^|[\s.(])"?id"? = \$(\d+)`)
+
+// byOrganization finds a tenant filter. With byID, the only filters fixtures honor.
+var byOrganization = regexp.MustCompile(`(?:^|[\s.(])"?organization_id"? = \$(\d+)`)
+
+// newFakeDB opens a GORM connection on a fake database seeded with roles and organizations
+func newFakeDB(t *testing.T, roles []models.Role, orgs []models.Organization) (*gorm.DB, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{tables: map[string]fakeTable{}, stubs: map[string]fakeTable{}}
+	table := fakeTable{columns: []string{"id", "name", "description", "permissions", "is_system"}}
+	for _, role := range roles {
+		permissions, _ := json.Marshal(role.Permissions)
+		table.rows = append(table.rows, []driver.Value{role.ID.String(), role.Name, role.Description, permissions, role.IsSystem})
+	}
+	fake.tables["roles"] = table
+
+	table = fakeTable{columns: []string{"id", "name", "slug", "settings", "is_active"}}
+	for _, org := range orgs {
+		settings, _ := json.Marshal(org.Settings)
+		table.rows = append(table.rows, []driver.Value{org.ID.String(), org.Name, org.Slug, settings, org.IsActive})
+	}
+	fake.tables["organizations"] = table
+
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeDBsMu.Unlock()
+	t.Cleanup(func() {
+		fakeDBsMu.Lock()
+		delete(fakeDBs, t.Name())
+		fakeDBsMu.Unlock()
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: "fakepg", DSN: t.Name()}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open fake database: %v", err)
+	}
+	if err := tenancy.Register(db); err != nil {
+		t.Fatalf("register tenancy callbacks: %v", err)
+	}
+	return db, fake
+}
+
+// seed serves rows as the content of a table. The first column must be id.
+func (f *fakeDB) seed(table string, columns []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tables[table] = fakeTable{columns: columns, rows: rows}
+}
+
+// stub answers every query containing fragment with rows
+func (f *fakeDB) stub(fragment string, columns []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stubs[fragment] = fakeTable{columns: columns, rows: rows}
+}
+
+// reset forgets the statements recorded so far
+func (f *fakeDB) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = nil
+}
+
+// touching returns the recorded statements that read or write one of tables
+func (f *fakeDB) touching(tables ...string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matched []fakeStatement
+	for _, statement := range f.statements {
+		for _, m := range fromTable.FindAllStringSubmatch(statement.query, -1) {
+			if contains(tables, m[1]) {
+				matched = append(matched, statement)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) driver.Rows {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, fakeStatement{query: query, args: values})
+
+	for fragment, table := range f.stubs {
+		if strings.Contains(query, fragment) {
+			return &fakeRows{columns: table.columns, rows: table.rows}
+		}
+	}
+	if strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+		if m := fromTable.FindStringSubmatch(query); m != nil {
+			if table, ok := f.tables[m[1]]; ok {
+				return &fakeRows{columns: table.columns, rows: table.filter(query, values)}
+			}
+		}
+	}
+	return &fakeRows{}
+}
+
+// filter returns the rows a query asks for: those with the ID it looks up and
+// in the organization it is scoped to, or all of them
+func (t fakeTable) filter(query string, args []driver.Value) [][]driver.Value {
+	rows := t.rows
+	if m := byID.FindStringSubmatch(query); m != nil {
+		rows = t.match(rows, "id", m[1], args)
+	}
+	if m := byOrganization.FindStringSubmatch(query); m != nil {
+		rows = t.match(rows, "organization_id", m[1], args)
+	}
+	return rows
+}
+
+// match keeps the rows whose column equals the nth argument
+func (t fakeTable) match(rows [][]driver.Value, column, nth string, args []driver.Value) [][]driver.Value {
+	n, _ := strconv.Atoi(nth)
+	index := -1
+	for i, c := range t.columns {
+		if c == column {
+			index = i
+		}
+	}
+	if index < 0 {
+		return rows
+	}
+	if n < 1 || n > len(args) {
+		return nil
+	}
+
+	var matched [][]driver.Value
+	for _, row := range rows {
+		if fmt.Sprint(row[index]) == fmt.Sprint(args[n-1]) {
+			matched = append(matched, row)
+		}
+	}
+	return matched
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	return &fakeConn{db: fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.run(query, args), nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.run(query, args)
+	return driver.RowsAffected(1), nil
+}
+
+// CheckNamedValue passes every argument through as is
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package accounts holds the staff account operations that run outside the
+// authenticated API: the bankctl admin CLI and the optional seed routes. The
+// db passed in must be able to see every organization, see tenancy.System.
+package accounts
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+)
+
+var (
+	// ErrSuperAdminExists is returned when bootstrapping a second super admin
+	ErrSuperAdminExists = errors.New("a super admin already exists")
+	// ErrUserExists is returned when the username or email is taken
+	ErrUserExists = errors.New("username or email already exists")
+	// ErrUserNotFound is returned when no user matches a reference
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUnknownRole is returned when promoting a user to a role that doesn't exist
+	ErrUnknownRole = errors.New("role does not exist")
+)
+
+// UserFilter narrows ListUsers. Empty fields match everything.
+type UserFilter struct {
+	Organization string // ID or slug
+	Role         string
+	Inactive     bool // Only deactivated or unapproved users
+}
+
+// FindUser looks a user up by ID, username or email
+func FindUser(db *gorm.DB, ref string) (*models.User, error) {
+	query := db.Model(&models.User{})
+	if id, err := uuid.Parse(ref); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("username = ? OR email = ?", ref, ref)
+	}
+
+	var user models.User
+	err := query.First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateSuperAdmin bootstraps the first super admin in the default
+// organization. It refuses once any super admin exists.
+func CreateSuperAdmin(db *gorm.DB, username, email, password string) (*models.User, error) {
+	var existing int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.RoleSuperAdmin).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrSuperAdminExists
+	}
+	if err := ensureUnique(db, username, email); err != nil {
+		return nil, err
+	}
+
+	defaultOrg, err := tenancy.EnsureDefault(db)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		OrganizationID: defaultOrg.ID,
+		Username:       strings.TrimSpace(username),
+		Email:          strings.TrimSpace(email),
+		Role:           models.RoleSuperAdmin,
+		RawPass:        password,
+		IsActive:       true,
+		IsApproved:     true, // Super admins are auto-approved
+		ApprovedAt:     &now,
+	}
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+	if err := db.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResetPassword replaces a user's password
+func ResetPassword(db *gorm.DB, user *models.User, password string) error {
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	user.RawPass = password // Kept in step for the recovery email
+	return db.Model(user).Updates(map[string]interface{}{
+		"password": user.Password,
+		"raw_pass": user.RawPass,
+	}).Error
+}
+
+// Promote gives a user another role, which must exist in the roles table
+func Promote(db *gorm.DB, user *models.User, roleName string) error {
+	var role models.Role
+	err := db.Where("name = ?", roleName).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownRole
+	}
+	if err != nil {
+		return err
+	}
+
+	user.Role = role.Name
+	return db.Model(user).Update("role", user.Role).Error
+}
+
+// Unlock reactivates a deactivated account and approves it if it never was
+func Unlock(db *gorm.DB, user *models.User) error {
+	user.IsActive = true
+	if !user.IsApproved {
+		now := time.Now()
+		user.IsApproved = true
+		user.ApprovedAt = &now
+	}
+	return db.Model(user).Updates(map[string]interface{}{
+		"is_active":   user.IsActive,
+		"is_approved": user.IsApproved,
+		"approved_at": user.ApprovedAt,
+	}).Error
+}
+
+// ListUsers returns the users matching filter, oldest first
+func ListUsers(db *gorm.DB, filter UserFilter) ([]models.User, error) {
+	query := db.Model(&models.User{}).Order("created_at")
+	if filter.Organization != "" {
+		if id, err := uuid.Parse(filter.Organization); err == nil {
+			query = query.Where("organization_id = ?", id)
+		} else {
+			query = query.Where("organization_id = (?)",
+				db.Model(&models.Organization{}).Select("id").Where("slug = ?", filter.Organization))
+		}
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Inactive {
+		query = query.Where("is_active = ? OR is_approved = ?", false, false)
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ensureUnique rejects a username or email that is already taken
+func ensureUnique(db *gorm.DB, username, email string) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("username = ? OR email = ?", username, email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Package app holds the dependencies the server builds once at startup and
+// hands to every handler and middleware. Sharing one container keeps all of
+// them on the same configuration, and lets tests build one with fakes.
+package app
+
+import (
+	"gorm.io/gorm"
+
+	"message-backend/internal/auth"
+	"message-backend/internal/clock"
+	"message-backend/internal/config"
+	"message-backend/internal/database"
+	"message-backend/internal/metrics"
+	"message-backend/internal/rbac"
+	"message-backend/internal/realtime"
+	"message-backend/internal/rules"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/utils"
+)
+
+// App is the application container
+type App struct {
+	Config  *config.Config
+	DB      *gorm.DB
+	Mailer  utils.Mailer
+	JWT     *auth.JWTService
+	Clock   clock.Clock
+	Policy  *rbac.Policy       // Role permissions, cached
+	Orgs    *tenancy.Directory // Organizations, cached
+	Rules   *rules.Engine      // Active message rules, cached per organization
+	Broker  *realtime.Broker   // Realtime message feed; Run starts it
+	Metrics *metrics.Metrics
+}
+
+// New builds the container around a validated configuration and an open database
+func New(cfg *config.Config, db *gorm.DB) *App {
+	return &App{
+		Config:  cfg,
+		DB:      db,
+		Mailer:  utils.NewSMTPMailer(cfg),
+		JWT:     auth.NewJWTService(cfg, clock.System),
+		Clock:   clock.System,
+		Policy:  rbac.NewPolicy(db),
+		Orgs:    tenancy.NewDirectory(db),
+		Rules:   rules.NewEngine(db),
+		Broker:  realtime.NewBroker(db, database.BuildDSN(cfg)),
+		Metrics: metrics.New(),
+	}
+}
@@ -0,0 +1,385 @@
+// Package audit keeps the append-only audit log of staff actions. The
+// middleware writes one hash-chained entry per mutating request; handlers
+// describe what they changed with Record so the entry carries a before/after diff.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/models"
+)
+
+// chainLockKey is the Postgres advisory lock serializing appends to the chain
+const chainLockKey int64 = 0x61756469746c6f67 // "auditlog"
+
+// Context keys
+const (
+	changeKey = "audit_change"
+	actorKey  = "audit_actor"
+	skipKey   = "audit_skip"
+)
+
+// Redacted replaces secret values in diffs
+const Redacted = "[REDACTED]"
+
+// secretFields never reach the audit log. Card data follows
+// models.Customer.MaskCardData: the expiry date and CVV are hidden, and card
+// numbers keep their last four digits.
+var secretFields = map[string]bool{
+	"password": true, "raw_pass": true, "cvv": true, "expiry_date": true, "secret": true, "secret_key": true,
+	"token": true, "access_token": true, "refresh_token": true,
+}
+
+// noisyFields change on every save and are left out of diffs
+var noisyFields = map[string]bool{"updated_at": true}
+
+type change struct {
+	action     string
+	targetType string
+	targetID   string
+	before     interface{}
+	after      interface{}
+}
+
+// Record describes the change the current request made. before and after are
+// any JSON-serializable values; nil before means a create and nil after a delete.
+func Record(c *gin.Context, action, targetType string, targetID interface{}, before, after interface{}) {
+	c.Set(changeKey, &change{
+		action:     action,
+		targetType: targetType,
+		targetID:   fmt.Sprint(targetID),
+		before:     before,
+		after:      after,
+	})
+}
+
+// RecordCommand appends an entry for a change made outside the API, such as by
+// the bankctl CLI. operator names the person who ran the command.
+func RecordCommand(db *gorm.DB, operator, command, action, targetType string, targetID interface{}, before, after interface{}) error {
+	entry := &models.AuditEntry{
+		ActorType:  models.AuditActorCLI,
+		ActorName:  truncate(operator, 50),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Method:     "CLI",
+		Path:       truncate(command, 255),
+	}
+
+	var err error
+	if entry.Before, entry.After, err = diff(before, after); err != nil {
+		return err
+	}
+	return Append(db, entry)
+}
+
+// SetActor names the actor for routes that authenticate without a user, such as the seed routes
+func SetActor(c *gin.Context, actorType string) {
+	c.Set(actorKey, actorType)
+}
+
+// Skip leaves a route's requests out of the audit log
+func Skip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(skipKey, true)
+		c.Next()
+	}
+}
+
+// Middleware appends an audit entry for every mutating request, and for reads
+// whose handlers called Record, once the handler has finished
+func Middleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.GetBool(skipKey) || c.FullPath() == "" {
+			return
+		}
+		_, recorded := c.Get(changeKey)
+		if !recorded && !isMutating(c.Request.Method) {
+			return
+		}
+
+		entry, err := newEntry(c)
+		if err == nil {
+			err = Append(db, entry)
+		}
+		if err != nil {
+			slog.ErrorContext(c, "Failed to write audit entry", slog.String("method", c.Request.Method), slog.String("path", c.Request.URL.Path), slog.Any("error", err))
+		}
+	}
+}
+
+// Append links entry to the end of the chain and stores it
+func Append(db *gorm.DB, entry *models.AuditEntry) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditEntry
+		err := tx.Select("seq", "hash").Order("seq DESC").Take(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		entry.ID = uuid.New()
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+		entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		entry.Hash = Hash(entry)
+		return tx.Create(entry).Error
+	})
+}
+
+// Hash returns the chain hash of an entry: SHA-256 over its fields and PrevHash
+func Hash(entry *models.AuditEntry) string {
+	actorID := ""
+	if entry.ActorID != nil {
+		actorID = entry.ActorID.String()
+	}
+
+	// Struct fields marshal in declaration order and maps with sorted keys,
+	// so the encoding is stable across a round trip through jsonb
+	payload, _ := json.Marshal(struct {
+		ID         string                 `json:"id"`
+		Seq        int64                  `json:"seq"`
+		PrevHash   string                 `json:"prev_hash"`
+		ActorType  string                 `json:"actor_type"`
+		ActorID    string                 `json:"actor_id"`
+		ActorName  string                 `json:"actor_name"`
+		ActorRole  string                 `json:"actor_role"`
+		Action     string                 `json:"action"`
+		TargetType string                 `json:"target_type"`
+		TargetID   string                 `json:"target_id"`
+		Before     map[string]interface{} `json:"before"`
+		After      map[string]interface{} `json:"after"`
+		Method     string                 `json:"method"`
+		Path       string                 `json:"path"`
+		Status     int                    `json:"status"`
+		IP         string                 `json:"ip"`
+		UserAgent  string                 `json:"user_agent"`
+		RequestID  string                 `json:"request_id"`
+		CreatedAt  string                 `json:"created_at"`
+	}{
+		ID:         entry.ID.String(),
+		Seq:        entry.Seq,
+		PrevHash:   entry.PrevHash,
+		ActorType:  entry.ActorType,
+		ActorID:    actorID,
+		ActorName:  entry.ActorName,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Before:     nonEmpty(entry.Before),
+		After:      nonEmpty(entry.After),
+		Method:     entry.Method,
+		Path:       entry.Path,
+		Status:     entry.Status,
+		IP:         entry.IP,
+		UserAgent:  entry.UserAgent,
+		RequestID:  entry.RequestID,
+		CreatedAt:  entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verification is the result of walking the chain
+type Verification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt *int64 `json:"broken_at,omitempty"` // Seq of the first entry that fails
+	Reason   string `json:"reason,omitempty"`
+	LastHash string `json:"last_hash,omitempty"`
+}
+
+// Verify recomputes every hash in sequence order and reports the first break
+func Verify(db *gorm.DB) (*Verification, error) {
+	result := &Verification{Valid: true}
+	var lastSeq int64
+	prevHash := ""
+
+	for {
+		var batch []models.AuditEntry
+		if err := db.Where("seq > ?", lastSeq).Order("seq").Limit(1000).Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		for i := range batch {
+			entry := &batch[i]
+			reason := ""
+			switch {
+			case entry.Seq != lastSeq+1:
+				reason = fmt.Sprintf("entries %d to %d are missing", lastSeq+1, entry.Seq-1)
+			case entry.PrevHash != prevHash:
+				reason = "prev_hash does not match the previous entry"
+			case Hash(entry) != entry.Hash:
+				reason = "hash does not match the entry's contents"
+			}
+			if reason != "" {
+				seq := entry.Seq
+				result.Valid = false
+				result.BrokenAt = &seq
+				result.Reason = reason
+				return result, nil
+			}
+
+			result.Checked++
+			result.LastHash = entry.Hash
+			lastSeq = entry.Seq
+			prevHash = entry.Hash
+		}
+	}
+}
+
+// newEntry builds the entry for the finished request
+func newEntry(c *gin.Context) (*models.AuditEntry, error) {
+	entry := &models.AuditEntry{
+		ActorType: models.AuditActorAnonymous,
+		Action:    c.Request.Method + " " + c.FullPath(),
+		TargetID:  c.Param("id"),
+		Method:    c.Request.Method,
+		Path:      truncate(c.Request.URL.Path, 255),
+		Status:    c.Writer.Status(),
+		IP:        c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 255),
+		RequestID: c.GetString("request_id"),
+	}
+
+	if value, ok := c.Get("user"); ok {
+		if user, ok := value.(*models.User); ok {
+			entry.ActorType = models.AuditActorStaff
+			entry.ActorID = &user.ID
+			entry.ActorName = user.Username
+			entry.ActorRole = user.Role
+		}
+	} else if actorType := c.GetString(actorKey); actorType != "" {
+		entry.ActorType = actorType
+	}
+
+	if value, ok := c.Get(changeKey); ok {
+		ch := value.(*change)
+		entry.Action = ch.action
+		entry.TargetType = ch.targetType
+		entry.TargetID = ch.targetID
+
+		var err error
+		if entry.Before, entry.After, err = diff(ch.before, ch.after); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// diff reduces before and after to the fields that changed, with secrets redacted.
+// Creates and deletes keep the whole record.
+func diff(before, after interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		for key := range b {
+			if noisyFields[key] || reflect.DeepEqual(b[key], a[key]) {
+				delete(b, key)
+				delete(a, key)
+			}
+		}
+		for key := range a {
+			if _, ok := b[key]; !ok && (noisyFields[key] || a[key] == nil) {
+				delete(a, key)
+			}
+		}
+	}
+
+	redact(b)
+	redact(a)
+	return nonEmpty(b), nonEmpty(a), nil
+}
+
+// toMap converts a value to its JSON object form
+func toMap(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	if object, ok := decoded.(map[string]interface{}); ok {
+		return object, nil
+	}
+	return map[string]interface{}{"value": decoded}, nil
+}
+
+// redact blanks secret fields and masks card numbers, recursing into nested values
+func redact(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			switch {
+			case secretFields[key]:
+				v[key] = Redacted
+			case key == "card_number":
+				if number, ok := field.(string); ok && len(number) > 4 {
+					v[key] = strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+				}
+			default:
+				redact(field)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			redact(item)
+		}
+	}
+}
+
+func nonEmpty(m map[string]interface{}) map[string]interface{} {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
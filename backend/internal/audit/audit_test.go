@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"message-backend/internal/models"
+)
+
+func TestDiffRedactsCustomerCardData(t *testing.T) {
+	before := models.Customer{
+		ID:             uuid.New(),
+		FullName:       "Jane Doe",
+		CardholderName: "JANE DOE",
+		CardNumber:     "4111111111111111",
+		ExpiryDate:     "04/27",
+		CVV:            "123",
+		TotalLimit:     1000,
+	}
+	after := before
+	after.CardNumber = "5500000000000004"
+	after.ExpiryDate = "09/30"
+	after.CVV = "456"
+	after.TotalLimit = 2000
+
+	tests := []struct {
+		name       string
+		before     interface{}
+		after      interface{}
+		wantBefore map[string]interface{}
+		wantAfter  map[string]interface{}
+	}{
+		{
+			name:   "update keeps only changed fields",
+			before: before,
+			after:  after,
+			wantBefore: map[string]interface{}{
+				"card_number": "************1111", "expiry_date": Redacted, "cvv": Redacted, "total_limit": 1000.0,
+			},
+			wantAfter: map[string]interface{}{
+				"card_number": "************0004", "expiry_date": Redacted, "cvv": Redacted, "total_limit": 2000.0,
+			},
+		},
+		{
+			name:   "create keeps the whole record",
+			before: nil,
+			after:  &before,
+		},
+	}
+
+	for _, tt := range tests {
+		b, a, err := diff(tt.before, tt.after)
+		if err != nil {
+			t.Fatalf("%s: diff: %v", tt.name, err)
+		}
+		for _, side := range []map[string]interface{}{b, a} {
+			if side == nil {
+				continue
+			}
+			if side["cvv"] != nil && side["cvv"] != Redacted || side["expiry_date"] != nil && side["expiry_date"] != Redacted {
+				t.Errorf("%s: card secrets leaked: %v", tt.name, side)
+			}
+			if number, ok := side["card_number"].(string); ok && number[:len(number)-4] != "************" {
+				t.Errorf("%s: card_number = %q, want it masked", tt.name, number)
+			}
+		}
+		if tt.wantBefore != nil && !reflect.DeepEqual(b, tt.wantBefore) {
+			t.Errorf("%s: before = %v, want %v", tt.name, b, tt.wantBefore)
+		}
+		if tt.wantAfter != nil && !reflect.DeepEqual(a, tt.wantAfter) {
+			t.Errorf("%s: after = %v, want %v", tt.name, a, tt.wantAfter)
+		}
+	}
+}
+
+func TestRedactNestedValues(t *testing.T) {
+	value := map[string]interface{}{
+		"customers": []interface{}{
+			map[string]interface{}{"expiry_date": "04/27", "cvv": "123", "card_number": "1234"},
+		},
+		"user": map[string]interface{}{"password": "hunter2", "username": "jane"},
+	}
+	redact(value)
+
+	want := map[string]interface{}{
+		"customers": []interface{}{
+			map[string]interface{}{"expiry_date": Redacted, "cvv": Redacted, "card_number": "1234"},
+		},
+		"user": map[string]interface{}{"password": Redacted, "username": "jane"},
+	}
+	if !reflect.DeepEqual(value, want) {
+		t.Errorf("redact = %v, want %v", value, want)
+	}
+}
@@ -2,8 +2,8 @@ package auth
 
 import (
 	"errors"
-	"time"
 
+	"message-backend/internal/clock"
 	"message-backend/internal/config"
 	"message-backend/internal/models"
 
@@ -12,35 +12,40 @@ import (
 )
 
 type Claims struct {
-	UserID    uuid.UUID `json:"user_id"`
-	Username  string    `json:"username"`
-	Role      string    `json:"role"`
-	TokenType string    `json:"token_type"` // "access" or "refresh"
+	UserID         uuid.UUID `json:"user_id"`
+	OrganizationID uuid.UUID `json:"organization_id"` // Tenant every request with the token is scoped to
+	Username       string    `json:"username"`
+	Role           string    `json:"role"`
+	TokenType      string    `json:"token_type"` // "access" or "refresh"
 	jwt.StandardClaims
 }
 
 type JWTService struct {
 	secretKey string
+	clock     clock.Clock // Stamps and expires tokens
 }
 
-func NewJWTService(cfg *config.Config) *JWTService {
+func NewJWTService(cfg *config.Config, clk clock.Clock) *JWTService {
 	return &JWTService{
 		secretKey: cfg.JWTSecret,
+		clock:     clk,
 	}
 }
 
 // GenerateAccessToken creates a short-lived access token
 func (j *JWTService) GenerateAccessToken(user *models.User, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(cfg.JWTAccessExpiration)
+	now := j.clock.Now()
+	expirationTime := now.Add(cfg.JWTAccessExpiration)
 
 	claims := &Claims{
-		UserID:    user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		TokenType: "access",
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		Username:       user.Username,
+		Role:           user.Role,
+		TokenType:      "access",
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
-			IssuedAt:  time.Now().Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
 
@@ -50,14 +55,16 @@ func (j *JWTService) GenerateAccessToken(user *models.User, cfg *config.Config)
 
 // GenerateRefreshToken creates a long-lived refresh token
 func (j *JWTService) GenerateRefreshToken(user *models.User, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(cfg.JWTRefreshExpiration)
+	now := j.clock.Now()
+	expirationTime := now.Add(cfg.JWTRefreshExpiration)
 
 	claims := &Claims{
-		UserID:    user.ID,
-		TokenType: "refresh",
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+		TokenType:      "refresh",
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
-			IssuedAt:  time.Now().Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
 
@@ -86,7 +93,9 @@ func (j *JWTService) GenerateTokenPair(user *models.User, cfg *config.Config) (m
 func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+	// Expiry is checked against the service's clock rather than jwt-go's
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(j.secretKey), nil
 	})
 
@@ -98,6 +107,14 @@ func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	now := j.clock.Now().Unix()
+	if !claims.VerifyExpiresAt(now, false) {
+		return nil, errors.New("token is expired")
+	}
+	if !claims.VerifyIssuedAt(now, false) || !claims.VerifyNotBefore(now, false) {
+		return nil, errors.New("token used before issued")
+	}
+
 	return claims, nil
 }
 
@@ -145,9 +162,10 @@ func (j *JWTService) RefreshAccessToken(refreshToken string, cfg *config.Config)
 
 	// Create user struct for generating new access token
 	user := &models.User{
-		ID:       claims.UserID,
-		Username: claims.Username,
-		Role:     claims.Role,
+		ID:             claims.UserID,
+		OrganizationID: claims.OrganizationID,
+		Username:       claims.Username,
+		Role:           claims.Role,
 	}
 
 	return j.GenerateAccessToken(user, cfg)
@@ -0,0 +1,26 @@
+// Package clock abstracts the current time so code that stamps or expires
+// things can be run against a fixed time.
+package clock
+
+import "time"
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the real wall clock
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fixed is a clock stopped at one instant
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
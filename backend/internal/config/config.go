@@ -1,10 +1,20 @@
-// Package config handles loading and parsing all environment-based configuration
-// used by the application, including server, database, JWT, and app-level settings.
+// Package config handles loading and parsing all configuration used by the
+// application, including server, database, JWT, and app-level settings.
+//
+// Settings come from, in increasing precedence: built-in defaults, an optional
+// YAML or TOML file named by CONFIG_FILE, and environment variables (a .env
+// file is loaded first if present). Secrets may also be read from a file named
+// by the variable with a _FILE suffix, e.g. JWT_SECRET_FILE=/run/secrets/jwt.
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -13,10 +23,36 @@ import (
 // Config holds all application configuration
 type Config struct {
 	// Server Configuration
-	ServerPort         string
-	ServerHost         string
-	ServerReadTimeout  time.Duration
-	ServerWriteTimeout time.Duration
+	ServerPort              string
+	ServerHost              string
+	ServerReadTimeout       time.Duration
+	ServerWriteTimeout      time.Duration
+	ServerReadHeaderTimeout time.Duration
+	ServerIdleTimeout       time.Duration
+	ServerMaxHeaderBytes    int
+	ServerShutdownTimeout   time.Duration // Grace period for in-flight requests on shutdown
+
+	// HTTP/2 is negotiated over TLS; H2C also accepts it in cleartext, for
+	// proxies that speak HTTP/2 to the backend
+	ServerHTTP2 bool
+	ServerH2C   bool
+
+	// Proxies (IPs or CIDRs) whose X-Forwarded-For and X-Real-IP headers are
+	// believed when resolving the client IP. Empty trusts none.
+	TrustedProxies []string
+
+	// Browser origins (scheme://host[:port]) allowed to call the API and open
+	// WebSockets. "*" allows any origin.
+	CORSAllowedOrigins []string
+
+	// TLS Configuration. TLS is on when a certificate and key are set; a client
+	// CA turns on mutual TLS. The files are reloaded when they change.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCAFile   string
+	TLSClientAuth     string // require or verify_if_given
+	TLSMinVersion     string // 1.2 or 1.3
+	TLSReloadInterval time.Duration
 
 	// Database Configuration (PostgreSQL)
 	DBHost     string
@@ -26,6 +62,10 @@ type Config struct {
 	DBName     string
 	DBSSLMode  string
 
+	// Apply pending schema migrations when the server starts. Without it the
+	// server refuses to start until "migrate up" has been run.
+	MigrateOnStart bool
+
 	// JWT Configuration
 	JWTSecret            string
 	JWTAccessExpiration  time.Duration
@@ -35,7 +75,31 @@ type Config struct {
 	AppEnv   string
 	AppDebug bool
 
-	// Super admin Seed Configuration
+	// Logging Configuration. SQL statements are logged without their values.
+	LogLevel  string // debug, info, warn or error
+	LogFormat string // json or text
+	LogSQL    bool   // Log every statement, not just failed and slow ones
+
+	// Metrics Configuration. /metrics is served on a listener of its own at
+	// MetricsAddress, or on the main port when the address is empty, where
+	// MetricsToken is required. MetricsToken, when set, must be sent as a
+	// bearer token.
+	MetricsEnabled bool
+	MetricsAddress string
+	MetricsToken   string
+
+	// Tracing Configuration. Spans are exported over OTLP/HTTP, printed to
+	// stdout for local runs, or not recorded at all; W3C trace context is
+	// propagated either way.
+	TracingExporter     string   // none, stdout or otlp
+	TracingOTLPEndpoint string   // Collector base URL; /v1/traces is appended
+	TracingOTLPHeaders  []string // key=value pairs sent with every export, e.g. for collector auth
+	TracingSampleRatio  float64  // Share of new traces recorded; incoming sampling decisions are kept
+	TracingServiceName  string
+
+	// Super admin Seed Configuration. The seed routes are off unless enabled;
+	// bankctl is the supported way to manage admin accounts.
+	SeedRoutesEnabled bool
 	SuperAdminSeedKey string
 
 	// SMTP Configuration
@@ -45,83 +109,377 @@ type Config struct {
 	SMTPPassword string
 
 	AdminEmail string
+
+	// Message Retention Configuration
+	RetentionEnabled   bool
+	RetentionInterval  time.Duration
+	RetentionBatchSize int
+
+	// Fraud Signal Configuration
+	SignalsEnabled        bool
+	SignalVelocityWindow  time.Duration
+	SignalVelocityCount   int
+	SignalLargeDebitRatio float64
+	SignalHomeCurrency    string
+	SignalTimezone        string
+
+	// sources records where each setting's value came from, keyed by env name
+	sources map[string]string
+}
+
+// setting describes one configuration value and where it can be read from
+type setting struct {
+	env    string // Environment variable
+	key    string // Dotted path in the config file
+	def    string // Default, empty when the setting has none
+	secret bool   // Readable from <env>_FILE and redacted when printed
+	field  func(c *Config) interface{}
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() *Config {
+// settings lists every configuration value in the order they are printed
+var settings = []setting{
+	// Server settings
+	{env: "SERVER_PORT", key: "server.port", def: "8080", field: func(c *Config) interface{} { return &c.ServerPort }},
+	{env: "SERVER_HOST", key: "server.host", def: "localhost", field: func(c *Config) interface{} { return &c.ServerHost }},
+	{env: "SERVER_READ_TIMEOUT", key: "server.read_timeout", def: "30s", field: func(c *Config) interface{} { return &c.ServerReadTimeout }},
+	{env: "SERVER_WRITE_TIMEOUT", key: "server.write_timeout", def: "30s", field: func(c *Config) interface{} { return &c.ServerWriteTimeout }},
+	{env: "SERVER_READ_HEADER_TIMEOUT", key: "server.read_header_timeout", def: "10s", field: func(c *Config) interface{} { return &c.ServerReadHeaderTimeout }},
+	{env: "SERVER_IDLE_TIMEOUT", key: "server.idle_timeout", def: "60s", field: func(c *Config) interface{} { return &c.ServerIdleTimeout }},
+	{env: "SERVER_MAX_HEADER_BYTES", key: "server.max_header_bytes", def: "1048576", field: func(c *Config) interface{} { return &c.ServerMaxHeaderBytes }},
+	{env: "SERVER_SHUTDOWN_TIMEOUT", key: "server.shutdown_timeout", def: "5s", field: func(c *Config) interface{} { return &c.ServerShutdownTimeout }},
+	{env: "SERVER_HTTP2", key: "server.http2", def: "true", field: func(c *Config) interface{} { return &c.ServerHTTP2 }},
+	{env: "SERVER_H2C", key: "server.h2c", def: "false", field: func(c *Config) interface{} { return &c.ServerH2C }},
+	{env: "TRUSTED_PROXIES", key: "server.trusted_proxies", field: func(c *Config) interface{} { return &c.TrustedProxies }},
+	{env: "CORS_ALLOWED_ORIGINS", key: "server.cors_allowed_origins", def: "*", field: func(c *Config) interface{} { return &c.CORSAllowedOrigins }},
+
+	// TLS settings
+	{env: "TLS_CERT_FILE", key: "tls.cert_file", field: func(c *Config) interface{} { return &c.TLSCertFile }},
+	{env: "TLS_KEY_FILE", key: "tls.key_file", field: func(c *Config) interface{} { return &c.TLSKeyFile }},
+	{env: "TLS_CLIENT_CA_FILE", key: "tls.client_ca_file", field: func(c *Config) interface{} { return &c.TLSClientCAFile }},
+	{env: "TLS_CLIENT_AUTH", key: "tls.client_auth", def: "require", field: func(c *Config) interface{} { return &c.TLSClientAuth }},
+	{env: "TLS_MIN_VERSION", key: "tls.min_version", def: "1.2", field: func(c *Config) interface{} { return &c.TLSMinVersion }},
+	{env: "TLS_RELOAD_INTERVAL", key: "tls.reload_interval", def: "1m", field: func(c *Config) interface{} { return &c.TLSReloadInterval }},
+
+	// Database settings
+	{env: "DB_HOST", key: "database.host", def: "localhost", field: func(c *Config) interface{} { return &c.DBHost }},
+	{env: "DB_PORT", key: "database.port", def: "5432", field: func(c *Config) interface{} { return &c.DBPort }},
+	{env: "DB_USER", key: "database.user", def: "postgres", field: func(c *Config) interface{} { return &c.DBUser }},
+	{env: "DB_PASSWORD", key: "database.password", secret: true, field: func(c *Config) interface{} { return &c.DBPassword }},
+	{env: "DB_NAME", key: "database.name", def: "message_db", field: func(c *Config) interface{} { return &c.DBName }},
+	{env: "DB_SSL_MODE", key: "database.ssl_mode", def: "disable", field: func(c *Config) interface{} { return &c.DBSSLMode }},
+	{env: "MIGRATE_ON_START", key: "database.migrate_on_start", def: "true", field: func(c *Config) interface{} { return &c.MigrateOnStart }},
+
+	// JWT settings
+	{env: "JWT_SECRET", key: "jwt.secret", secret: true, field: func(c *Config) interface{} { return &c.JWTSecret }},
+	{env: "JWT_ACCESS_EXPIRATION", key: "jwt.access_expiration", def: "15m", field: func(c *Config) interface{} { return &c.JWTAccessExpiration }},
+	{env: "JWT_REFRESH_EXPIRATION", key: "jwt.refresh_expiration", def: "168h", field: func(c *Config) interface{} { return &c.JWTRefreshExpiration }},
+
+	// Application settings
+	{env: "APP_ENV", key: "app.env", def: "development", field: func(c *Config) interface{} { return &c.AppEnv }},
+	{env: "APP_DEBUG", key: "app.debug", def: "true", field: func(c *Config) interface{} { return &c.AppDebug }},
+	{env: "ADMIN_EMAIL", key: "app.admin_email", field: func(c *Config) interface{} { return &c.AdminEmail }},
+
+	// Logging settings
+	{env: "LOG_LEVEL", key: "log.level", def: "info", field: func(c *Config) interface{} { return &c.LogLevel }},
+	{env: "LOG_FORMAT", key: "log.format", def: "json", field: func(c *Config) interface{} { return &c.LogFormat }},
+	{env: "LOG_SQL", key: "log.sql", def: "false", field: func(c *Config) interface{} { return &c.LogSQL }},
+
+	// Metrics settings
+	{env: "METRICS_ENABLED", key: "metrics.enabled", def: "true", field: func(c *Config) interface{} { return &c.MetricsEnabled }},
+	{env: "METRICS_ADDRESS", key: "metrics.address", def: "127.0.0.1:9090", field: func(c *Config) interface{} { return &c.MetricsAddress }},
+	{env: "METRICS_TOKEN", key: "metrics.token", secret: true, field: func(c *Config) interface{} { return &c.MetricsToken }},
 
+	// Tracing settings
+	{env: "TRACING_EXPORTER", key: "tracing.exporter", def: "none", field: func(c *Config) interface{} { return &c.TracingExporter }},
+	{env: "TRACING_OTLP_ENDPOINT", key: "tracing.otlp_endpoint", def: "http://localhost:4318", field: func(c *Config) interface{} { return &c.TracingOTLPEndpoint }},
+	{env: "TRACING_OTLP_HEADERS", key: "tracing.otlp_headers", secret: true, field: func(c *Config) interface{} { return &c.TracingOTLPHeaders }},
+	{env: "TRACING_SAMPLE_RATIO", key: "tracing.sample_ratio", def: "1", field: func(c *Config) interface{} { return &c.TracingSampleRatio }},
+	{env: "TRACING_SERVICE_NAME", key: "tracing.service_name", def: "message-backend", field: func(c *Config) interface{} { return &c.TracingServiceName }},
+
+	// Super Admin Seed routes and key
+	{env: "SEED_ROUTES_ENABLED", key: "seed.routes_enabled", def: "false", field: func(c *Config) interface{} { return &c.SeedRoutesEnabled }},
+	{env: "SUPER_ADMIN_SEED_KEY", key: "seed.super_admin_key", secret: true, field: func(c *Config) interface{} { return &c.SuperAdminSeedKey }},
+
+	// SMTP settings
+	{env: "SMTP_HOST", key: "smtp.host", field: func(c *Config) interface{} { return &c.SMTPHost }},
+	{env: "SMTP_PORT", key: "smtp.port", field: func(c *Config) interface{} { return &c.SMTPPort }},
+	{env: "SMTP_USERNAME", key: "smtp.username", field: func(c *Config) interface{} { return &c.SMTPUsername }},
+	{env: "SMTP_PASSWORD", key: "smtp.password", secret: true, field: func(c *Config) interface{} { return &c.SMTPPassword }},
+
+	// Message retention settings
+	{env: "RETENTION_ENABLED", key: "retention.enabled", def: "true", field: func(c *Config) interface{} { return &c.RetentionEnabled }},
+	{env: "RETENTION_INTERVAL", key: "retention.interval", def: "1h", field: func(c *Config) interface{} { return &c.RetentionInterval }},
+	{env: "RETENTION_BATCH_SIZE", key: "retention.batch_size", def: "1000", field: func(c *Config) interface{} { return &c.RetentionBatchSize }},
+
+	// Fraud signal settings
+	{env: "SIGNALS_ENABLED", key: "signals.enabled", def: "true", field: func(c *Config) interface{} { return &c.SignalsEnabled }},
+	{env: "SIGNALS_VELOCITY_WINDOW", key: "signals.velocity_window", def: "10m", field: func(c *Config) interface{} { return &c.SignalVelocityWindow }},
+	{env: "SIGNALS_VELOCITY_COUNT", key: "signals.velocity_count", def: "5", field: func(c *Config) interface{} { return &c.SignalVelocityCount }},
+	{env: "SIGNALS_LARGE_DEBIT_RATIO", key: "signals.large_debit_ratio", def: "0.5", field: func(c *Config) interface{} { return &c.SignalLargeDebitRatio }},
+	{env: "SIGNALS_HOME_CURRENCY", key: "signals.home_currency", def: "INR", field: func(c *Config) interface{} { return &c.SignalHomeCurrency }},
+	{env: "SIGNALS_TIMEZONE", key: "signals.timezone", def: "Asia/Kolkata", field: func(c *Config) interface{} { return &c.SignalTimezone }},
+}
+
+// Load reads the configuration and reports every invalid or missing value at
+// once. The returned config is usable for display even when err is non-nil; a
+// setting that failed to parse keeps its default.
+func Load() (*Config, error) {
 	// Load .env file if it exists (ignores errors for production)
 	godotenv.Load()
 
-	return &Config{
-		// Server settings
-		ServerPort:         getEnv("SERVER_PORT", "8080"),
-		ServerHost:         getEnv("SERVER_HOST", "localhost"),
-		ServerReadTimeout:  getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second),
-		ServerWriteTimeout: getEnvDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
-
-		// Database settings
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "message_db"),
-		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
-
-		// JWT settings
-		JWTSecret:            getEnv("JWT_SECRET", "change-this-in-production"),
-		JWTAccessExpiration:  getEnvDuration("JWT_ACCESS_EXPIRATION", 15*time.Minute),
-		JWTRefreshExpiration: getEnvDuration("JWT_REFRESH_EXPIRATION", 7*24*time.Hour),
-
-		// Application settings
-		AppEnv:   getEnv("APP_ENV", "development"),
-		AppDebug: getEnvBool("APP_DEBUG", true),
-
-		// Super Admin Seed Key
-		SuperAdminSeedKey: getEnv("SUPER_ADMIN_SEED_KEY", "change-this-to-a-very-secure-random-key"),
-
-		SMTPHost:     getEnv("SMTP_HOST", ""),
-		SMTPPort:     getEnv("SMTP_PORT", ""),
-		SMTPUsername: getEnv("SMTP_USERNAME", ""),
-		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
-		AdminEmail:   getEnv("ADMIN_EMAIL", ""),
+	cfg := &Config{sources: make(map[string]string, len(settings))}
+
+	var errs []error
+	file, err := readFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, s := range settings {
+		// Defaults are known to parse
+		s.parse(cfg, s.def)
+		cfg.sources[s.env] = "default"
+
+		value, source, err := s.lookup(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if source == "" {
+			continue
+		}
+		if err := s.parse(cfg, value); err != nil {
+			if s.secret {
+				errs = append(errs, fmt.Errorf("%s from %s is invalid: %v", s.env, source, err))
+			} else {
+				errs = append(errs, fmt.Errorf("%s from %s is invalid: %q: %v", s.env, source, value, err))
+			}
+			continue
+		}
+		cfg.sources[s.env] = source
 	}
+
+	// Anything left in the file is a typo or a setting that doesn't exist
+	for _, key := range file.unused() {
+		errs = append(errs, fmt.Errorf("unknown setting %q in %s", key, file.path))
+	}
+
+	errs = append(errs, cfg.Validate())
+	return cfg, errors.Join(errs...)
 }
 
-// getEnv gets environment variable or returns default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// lookup finds the configured value of a setting and names its source. An empty
+// source means the setting isn't configured and keeps its default.
+func (s setting) lookup(file *fileValues) (value, source string, err error) {
+	// Taken first so a key overridden by the environment isn't reported as unknown
+	fileValue, inFile := file.take(s.key)
+	env := os.Getenv(s.env)
+
+	if s.secret {
+		if path := os.Getenv(s.env + "_FILE"); path != "" {
+			if env != "" {
+				return "", "", fmt.Errorf("only one of %s and %s_FILE may be set", s.env, s.env)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", "", fmt.Errorf("%s_FILE: %v", s.env, err)
+			}
+			return strings.TrimRight(string(data), "\r\n"), s.env + "_FILE", nil
+		}
 	}
-	return defaultValue
+	if env != "" {
+		return env, "env", nil
+	}
+	if inFile {
+		return fileValue, file.path, nil
+	}
+	return "", "", nil
 }
 
-// getEnvBool gets environment variable as boolean
-func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+// parse stores value in the setting's field according to the field's type
+func (s setting) parse(c *Config, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		*field = parsed
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*field = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		*field = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a duration such as 30s or 15m")
+		}
+		*field = parsed
+	case *[]string:
+		*field = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field = append(*field, item)
+			}
 		}
 	}
-	return defaultValue
+	return nil
 }
 
-// getEnvDuration gets environment variable as time.Duration
-func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+// format renders the setting's current value the way it would be configured
+func (s setting) format(c *Config) string {
+	switch field := s.field(c).(type) {
+	case *string:
+		return *field
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *int:
+		return strconv.Itoa(*field)
+	case *float64:
+		return strconv.FormatFloat(*field, 'g', -1, 64)
+	case *time.Duration:
+		return field.String()
+	case *[]string:
+		return strings.Join(*field, ",")
+	}
+	return ""
+}
+
+// minJWTSecretLength is the shortest JWT_SECRET accepted, in bytes
+const minJWTSecretLength = 32
+
+// exampleSecrets are placeholders shipped in examples that must never be used
+var exampleSecrets = map[string]bool{
+	"change-this-in-production":                         true,
+	"your-super-secure-64-character-random-secret-here": true,
+	"your_secure_password_here":                         true,
+}
+
+// Validate reports every setting the application can't run with
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
 		}
 	}
-	return defaultValue
+	production := c.AppEnv == "production"
+
+	check(validPort(c.ServerPort), "SERVER_PORT must be a port number, got %q", c.ServerPort)
+	check(c.ServerReadTimeout > 0, "SERVER_READ_TIMEOUT must be positive")
+	check(c.ServerWriteTimeout > 0, "SERVER_WRITE_TIMEOUT must be positive")
+	check(c.ServerReadHeaderTimeout > 0, "SERVER_READ_HEADER_TIMEOUT must be positive")
+	check(c.ServerIdleTimeout > 0, "SERVER_IDLE_TIMEOUT must be positive")
+	check(c.ServerMaxHeaderBytes > 0, "SERVER_MAX_HEADER_BYTES must be positive")
+	check(c.ServerShutdownTimeout > 0, "SERVER_SHUTDOWN_TIMEOUT must be positive")
+	for _, proxy := range c.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES entry %q is not an IP or CIDR", proxy)
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		u, err := url.Parse(origin)
+		check(origin == "*" || err == nil && u.Scheme != "" && u.Host != "" && strings.TrimSuffix(u.Path, "/") == "",
+			"CORS_ALLOWED_ORIGINS entry %q must be * or scheme://host[:port]", origin)
+	}
+
+	check((c.TLSCertFile == "") == (c.TLSKeyFile == ""), "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	check(c.TLSClientCAFile == "" || c.TLSEnabled(), "TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+	check(c.TLSClientAuth == "require" || c.TLSClientAuth == "verify_if_given", "TLS_CLIENT_AUTH must be require or verify_if_given, got %q", c.TLSClientAuth)
+	check(c.TLSMinVersion == "1.2" || c.TLSMinVersion == "1.3", "TLS_MIN_VERSION must be 1.2 or 1.3, got %q", c.TLSMinVersion)
+	check(c.TLSReloadInterval >= 0, "TLS_RELOAD_INTERVAL must not be negative")
+
+	check(c.DBHost != "", "DB_HOST is required")
+	check(validPort(c.DBPort), "DB_PORT must be a port number, got %q", c.DBPort)
+	check(c.DBName != "", "DB_NAME is required")
+	check(!production || c.DBPassword != "", "DB_PASSWORD is required in production")
+	check(!exampleSecrets[c.DBPassword], "DB_PASSWORD is still the example value")
+
+	check(c.JWTSecret != "", "JWT_SECRET is required")
+	check(c.JWTSecret == "" || len(c.JWTSecret) >= minJWTSecretLength, "JWT_SECRET must be at least %d characters", minJWTSecretLength)
+	check(!exampleSecrets[c.JWTSecret], "JWT_SECRET is still the example value")
+	check(c.JWTAccessExpiration > 0, "JWT_ACCESS_EXPIRATION must be positive")
+	check(c.JWTRefreshExpiration > 0, "JWT_REFRESH_EXPIRATION must be positive")
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	check(c.LogFormat == "json" || c.LogFormat == "text", "LOG_FORMAT must be json or text, got %q", c.LogFormat)
+
+	if c.MetricsAddress != "" {
+		_, port, err := net.SplitHostPort(c.MetricsAddress)
+		check(err == nil && validPort(port), "METRICS_ADDRESS must be host:port, got %q", c.MetricsAddress)
+	}
+	check(!c.MetricsEnabled || c.MetricsAddress != "" || c.MetricsToken != "",
+		"METRICS_TOKEN is required when /metrics is served on the API port")
+
+	switch c.TracingExporter {
+	case "none", "stdout":
+	case "otlp":
+		endpoint, err := url.Parse(c.TracingOTLPEndpoint)
+		check(err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+			"TRACING_OTLP_ENDPOINT must be an http or https URL, got %q", c.TracingOTLPEndpoint)
+		for _, header := range c.TracingOTLPHeaders {
+			name, _, ok := strings.Cut(header, "=")
+			check(ok && strings.TrimSpace(name) != "", "TRACING_OTLP_HEADERS entries must be key=value")
+		}
+	default:
+		check(false, "TRACING_EXPORTER must be none, stdout or otlp, got %q", c.TracingExporter)
+	}
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+	check(c.TracingServiceName != "", "TRACING_SERVICE_NAME is required")
+
+	check(!c.SeedRoutesEnabled || c.SuperAdminSeedKey != "", "SUPER_ADMIN_SEED_KEY is required when SEED_ROUTES_ENABLED is set")
+
+	if c.SMTPHost != "" {
+		check(validPort(c.SMTPPort), "SMTP_PORT must be a port number when SMTP_HOST is set, got %q", c.SMTPPort)
+	}
+
+	if c.RetentionEnabled {
+		check(c.RetentionInterval > 0, "RETENTION_INTERVAL must be positive")
+		check(c.RetentionBatchSize > 0, "RETENTION_BATCH_SIZE must be positive")
+	}
+
+	if c.SignalsEnabled {
+		check(c.SignalVelocityWindow > 0, "SIGNALS_VELOCITY_WINDOW must be positive")
+		check(c.SignalVelocityCount > 0, "SIGNALS_VELOCITY_COUNT must be positive")
+		check(c.SignalLargeDebitRatio > 0 && c.SignalLargeDebitRatio <= 1, "SIGNALS_LARGE_DEBIT_RATIO must be between 0 and 1")
+		_, err := time.LoadLocation(c.SignalTimezone)
+		check(err == nil, "SIGNALS_TIMEZONE %q is not a known time zone", c.SignalTimezone)
+	}
+
+	return errors.Join(errs...)
 }
 
-// getEnvInt gets environment variable as integer
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// TLSEnabled reports whether the server listens with TLS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// OriginAllowed reports whether a browser origin may call the API
+func (c *Config) OriginAllowed(origin string) bool {
+	origin = strings.TrimSuffix(origin, "/")
+	for _, allowed := range c.CORSAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
 		}
 	}
-	return defaultValue
+	return false
+}
+
+// validPort reports whether value is a TCP port number
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port < 65536
 }
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// fileValues holds the settings of a config file flattened to dotted keys
+type fileValues struct {
+	path   string
+	values map[string]string
+	taken  map[string]bool
+}
+
+// readFile parses a YAML or TOML config file, chosen by its extension. An empty
+// path means no file is used. The result is never nil so lookups always work.
+func readFile(path string) (*fileValues, error) {
+	file := &fileValues{path: path, values: map[string]string{}, taken: map[string]bool{}}
+	if path == "" {
+		return file, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file, fmt.Errorf("CONFIG_FILE: %v", err)
+	}
+
+	var tree map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return file, fmt.Errorf("CONFIG_FILE %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return file, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	flatten("", tree, file.values)
+	return file, nil
+}
+
+// flatten turns nested sections into dotted keys such as server.port
+func flatten(prefix string, tree map[string]interface{}, out map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			flatten(key, value, out)
+		case []interface{}:
+			// Lists are written as comma-separated values
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			// An empty value leaves the setting at its default
+		default:
+			out[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+// take returns a key's value and marks it as belonging to a known setting
+func (f *fileValues) take(key string) (string, bool) {
+	f.taken[key] = true
+	value, ok := f.values[key]
+	return value, ok
+}
+
+// unused lists keys no setting took, in sorted order
+func (f *fileValues) unused() []string {
+	var keys []string
+	for key := range f.values {
+		if !f.taken[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// redactedValue replaces a configured secret in printed output
+const redactedValue = "[redacted]"
+
+// Print writes every effective setting with the source it came from. With
+// redact set, secrets that have a value are masked.
+func (c *Config) Print(w io.Writer, redact bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings {
+		value := s.format(c)
+		if redact && s.secret && value != "" {
+			value = redactedValue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.env, value, c.sources[s.env])
+	}
+	return tw.Flush()
+}
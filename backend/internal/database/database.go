@@ -3,7 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"message-backend/internal/config"
 	"time"
 
@@ -15,26 +15,27 @@ import (
 // DB holds the global database instance
 var DB *gorm.DB
 
+// slowQueryThreshold is how long a statement may take before it is logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
 // InitDB initializes the database connection with connection pooling and proper configuration
-func InitDB() (*gorm.DB, error) {
-	cfg := config.LoadConfig()
-	
+func InitDB(cfg *config.Config) (*gorm.DB, error) {
 	// Build PostgreSQL Data Source Name (DSN)
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBName,
-		cfg.DBSSLMode,
-	)
+	dsn := BuildDSN(cfg)
 	
-	// Configure GORM logger based on environment
-	gormConfig := &gorm.Config{}
-	if cfg.AppEnv == "development" {
-		gormConfig.Logger = logger.Default.LogMode(logger.Info)
-	} else {
-		gormConfig.Logger = logger.Default.LogMode(logger.Error)
+	// Log SQL through slog without its values, which can hold card numbers
+	// and CVVs. LOG_SQL adds every statement to the failed and slow ones.
+	logLevel := logger.Warn
+	if cfg.LogSQL {
+		logLevel = logger.Info
+	}
+	gormConfig := &gorm.Config{
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			LogLevel:                  logLevel,
+			SlowThreshold:             slowQueryThreshold,
+			ParameterizedQueries:      true,
+			IgnoreRecordNotFoundError: true,
+		}),
 	}
 	
 	// Open database connection
@@ -64,12 +65,25 @@ func InitDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("database ping failed: %v", err)
 	}
 	
-	log.Printf("✅ Successfully connected to PostgreSQL database: %s@%s:%s/%s",
-		cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName)
+	slog.Info("Connected to PostgreSQL database",
+		slog.String("user", cfg.DBUser), slog.String("host", cfg.DBHost),
+		slog.String("port", cfg.DBPort), slog.String("database", cfg.DBName))
 	
 	return DB, nil
 }
 
+// BuildDSN builds the PostgreSQL Data Source Name (DSN) from configuration
+func BuildDSN(cfg *config.Config) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.DBHost,
+		cfg.DBPort,
+		cfg.DBUser,
+		cfg.DBPassword,
+		cfg.DBName,
+		cfg.DBSSLMode,
+	)
+}
+
 // GetDB returns the global database instance
 func GetDB() *gorm.DB {
 	return DB
@@ -118,27 +132,27 @@ func WithTransaction(txFunc func(*gorm.DB) error) error {
 	})
 }
 
-// MigrateModels automigrates all database models using modern 'any' type
-func MigrateModels(models []any) error {
-	if DB == nil {
-		return fmt.Errorf("database not initialized")
-	}
-	
-	return DB.AutoMigrate(models...)
-}
-
-// CreateTablesIfNotExists creates tables only if they don't exist
-func CreateTablesIfNotExists(models []any) error {
-	if DB == nil {
-		return fmt.Errorf("database not initialized")
-	}
-	
-	for _, model := range models {
-		if !DB.Migrator().HasTable(model) {
-			if err := DB.Migrator().CreateTable(model); err != nil {
-				return fmt.Errorf("failed to create table: %v", err)
-			}
-		}
+// ReconcileCustomerCounters recomputes every customer's message_count and
+// last_active from the messages table and returns how many rows were corrected
+func ReconcileCustomerCounters(db *gorm.DB) (int64, error) {
+	result := db.Exec(`
+		UPDATE customers AS c
+		SET message_count = COALESCE(m.message_count, 0),
+			last_active = COALESCE(m.last_message_at, c.last_active),
+			updated_at = NOW()
+		FROM customers AS c2
+		LEFT JOIN (
+			SELECT customer_id, COUNT(*) AS message_count, MAX(created_at) AS last_message_at
+			FROM messages
+			WHERE deleted_at IS NULL
+			GROUP BY customer_id
+		) AS m ON m.customer_id = c2.id
+		WHERE c.id = c2.id
+		AND (c.message_count <> COALESCE(m.message_count, 0)
+			OR c.last_active IS DISTINCT FROM COALESCE(m.last_message_at, c.last_active))
+	`)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to reconcile customer counters: %v", result.Error)
 	}
-	return nil
+	return result.RowsAffected, nil
 }
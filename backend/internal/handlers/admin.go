@@ -1,20 +1,28 @@
 package handlers
 
 import (
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 
-	"message-backend/internal/database"
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/clock"
+	"message-backend/internal/listquery"
 	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/repository"
 	"message-backend/internal/types"
 	"message-backend/internal/utils"
 )
 
 type AdminHandler struct {
-	db *gorm.DB
+	customers repository.CustomerRepository
+	messages  repository.MessageRepository
+	users     repository.UserRepository
+	policy    *rbac.Policy
+	clock     clock.Clock
 }
 
 // GetStats returns general statistics for dashboard/analytics
@@ -28,9 +36,13 @@ type StatsResponse struct {
 	TotalCreditLimit float64 `json:"totalCreditLimit"`
 }
 
-func NewAdminHandler() *AdminHandler {
+func NewAdminHandler(a *app.App, customers repository.CustomerRepository, messages repository.MessageRepository, users repository.UserRepository) *AdminHandler {
 	return &AdminHandler{
-		db: database.GetDB(),
+		customers: customers,
+		messages:  messages,
+		users:     users,
+		policy:    a.Policy,
+		clock:     a.Clock,
 	}
 }
 
@@ -48,29 +60,21 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	if !user.CanManageUsers() {
-		utils.Forbidden(c, "Insufficient permissions to create users")
-		return
-	}
-
 	var req types.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "Invalid request data", err)
 		return
 	}
 
-	if req.Role == models.RoleSuperAdmin && !user.IsSuperAdmin() {
-		utils.Forbidden(c, "Only super admins can create super admin accounts")
+	if !h.checkAssignable(c, user, req.Role) {
 		return
 	}
 
-	if req.Role == models.RoleAdmin && !user.IsSuperAdmin() {
-		utils.Forbidden(c, "Only super admins can create admin accounts")
+	// Usernames and emails are unique across organizations
+	if exists, err := h.users.Exists(c, req.Username, req.Email); err != nil {
+		utils.InternalServerError(c, "Failed to check existing users", err)
 		return
-	}
-
-	var existingUser models.User
-	if err := h.db.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
+	} else if exists {
 		utils.Conflict(c, "User already exists")
 		return
 	}
@@ -87,55 +91,64 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Create(newUser).Error; err != nil {
+	if err := h.users.Create(c, newUser); err != nil {
 		utils.InternalServerError(c, "Failed to create user", err)
 		return
 	}
+	audit.Record(c, "user.create", "user", newUser.ID, nil, newUser)
 
 	utils.Created(c, "User created successfully by "+user.Username, newUser)
 }
 
 func (h *AdminHandler) GetStats(c *gin.Context) {
-	// Get total customers
-	var totalCustomers int64
-	h.db.Model(&models.Customer{}).Count(&totalCustomers)
-
-	// Get new customers this month
-	now := time.Now()
+	// Get customer totals, counting new customers from the start of this month
+	now := h.clock.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	var newCustomers int64
-	h.db.Model(&models.Customer{}).Where("created_at >= ?", startOfMonth).Count(&newCustomers)
+	customers, err := h.customers.Summary(c, startOfMonth)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch statistics", err)
+		return
+	}
 
 	// Get total messages
-	var totalMessages int64
-	h.db.Model(&models.Message{}).Count(&totalMessages)
-
-	// Get active customers
-	var activeCustomers int64
-	h.db.Model(&models.Customer{}).Where("is_active = ?", true).Count(&activeCustomers)
-
-	// Get total credit limit
-	var totalCreditLimit struct {
-		Sum float64
+	totalMessages, err := h.messages.Count(c)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch statistics", err)
+		return
 	}
-	h.db.Model(&models.Customer{}).Select("COALESCE(SUM(total_limit), 0) as sum").Scan(&totalCreditLimit)
 
 	// For now, unread messages = total messages (since we don't have a read status yet)
 	unreadMessages := totalMessages
 
 	stats := StatsResponse{
-		TotalCustomers:   totalCustomers,
-		NewCustomers:     newCustomers,
+		TotalCustomers:   customers.Total,
+		NewCustomers:     customers.New,
 		TotalMessages:    totalMessages,
 		UnreadMessages:   unreadMessages,
-		ActiveCustomers:  activeCustomers,
-		TotalCreditLimit: totalCreditLimit.Sum,
+		ActiveCustomers:  customers.Active,
+		TotalCreditLimit: customers.TotalLimit,
 	}
 
 	utils.Success(c, "Statistics retrieved successfully", stats)
 }
 
-// GetAllUsers returns all users with optional filtering (admin only)
+// userListSpec is the list query language of user collections
+var userListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"created_at":  {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"last_login":  {Column: "last_login", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"username":    {Column: "username", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"email":       {Column: "email", Type: listquery.String, Ops: listquery.Text},
+		"role":        {Column: "role", Type: listquery.String, Ops: listquery.Equality},
+		"is_active":   {Column: "is_active", Type: listquery.Bool, Ops: listquery.Flag},
+		"is_approved": {Column: "is_approved", Type: listquery.Bool, Ops: listquery.Flag},
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetAllUsers returns a page of users with optional filtering (admin only)
 func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 	currentUser, exists := c.Get("user")
 	if !exists {
@@ -149,66 +162,31 @@ func (h *AdminHandler) GetAllUsers(c *gin.Context) {
 		return
 	}
 
-	if !admin.CanManageUsers() {
-		utils.Forbidden(c, "Insufficient permissions to view users")
+	query, ok := parseListQuery(c, userListSpec)
+	if !ok {
 		return
 	}
 
-	// Filtering parameters
-	role := c.Query("role")
-	isActive := c.Query("is_active")
-	isApproved := c.Query("is_approved")
-	search := c.Query("search") // Search in username or email
-
-	// Build query
-	query := h.db.Model(&models.User{})
-
-	// Exclude the current user from results
-	query = query.Where("id != ?", admin.ID)
+	filter := repository.UserFilter{
+		ExcludeID: &admin.ID,         // Exclude the current user from results
+		Search:    c.Query("search"), // Search in username or email
+	}
 
 	// If current user is NOT super admin, exclude super_admin users from results
 	if !admin.IsSuperAdmin() {
-		query = query.Where("role != ?", models.RoleSuperAdmin)
-	}
-
-	if role != "" {
-		query = query.Where("role = ?", role)
-	}
-
-	if isActive != "" {
-		active, _ := strconv.ParseBool(isActive)
-		query = query.Where("is_active = ?", active)
-	}
-
-	if isApproved != "" {
-		approved, _ := strconv.ParseBool(isApproved)
-		query = query.Where("is_approved = ?", approved)
-	}
-
-	if search != "" {
-		query = query.Where("username ILIKE ? OR email ILIKE ?", "%"+search+"%", "%"+search+"%")
+		filter.ExcludeRole = models.RoleSuperAdmin
 	}
 
-	// Get all matching users
-	var users []models.User
-	if err := query.Order("created_at DESC").Find(&users).Error; err != nil {
-		utils.InternalServerError(c, "Failed to fetch users", err)
+	// Get a page of matching users
+	users, page, err := h.users.List(c, query, filter)
+	if err != nil {
+		listFailed(c, err)
 		return
 	}
 
-	// Get total count
-	var totalCount int64
-	query.Count(&totalCount)
-
 	response := gin.H{
 		"users":       users,
-		"total_count": totalCount,
-		"filters": gin.H{
-			"role":        role,
-			"is_active":   isActive,
-			"is_approved": isApproved,
-			"search":      search,
-		},
+		"pagination":  page,
 		"viewer_role": admin.Role, // Include viewer's role for transparency
 		"viewer_id":   admin.ID,   // Include viewer's ID for reference
 	}
@@ -232,13 +210,8 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	if !user.CanManageUsers() {
-		utils.Forbidden(c, "Insufficient permissions")
-		return
-	}
-
 	var req struct {
-		Role string `json:"role" binding:"required,oneof=super_admin admin user"`
+		Role string `json:"role" binding:"required,max=20"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -246,9 +219,8 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	var targetUser models.User
-	if err := h.db.First(&targetUser, userID).Error; err != nil {
-		utils.NotFound(c, "User not found")
+	targetUser, ok := h.findUser(c, userID)
+	if !ok {
 		return
 	}
 
@@ -257,54 +229,41 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	if req.Role == models.RoleSuperAdmin && !user.IsSuperAdmin() {
-		utils.Forbidden(c, "Only super admins can assign super admin role")
+	if !h.checkAssignable(c, user, req.Role) {
 		return
 	}
 
-	if req.Role == models.RoleAdmin && !user.IsSuperAdmin() {
-		utils.Forbidden(c, "Only super admins can assign admin role")
+	// Taking a role away needs the same rights as handing it out
+	if !h.checkAssignable(c, user, targetUser.Role) {
 		return
 	}
 
-	if err := h.db.Model(&targetUser).Update("role", req.Role).Error; err != nil {
+	before := *targetUser
+	if err := h.users.UpdateRole(c, targetUser, req.Role); err != nil {
 		utils.InternalServerError(c, "Failed to update user role", err)
 		return
 	}
+	audit.Record(c, "user.role_update", "user", targetUser.ID, before, targetUser)
 
 	utils.Success(c, "User role updated successfully", targetUser)
 }
 
 // GetPendingUsers returns users waiting for approval
 func (h *AdminHandler) GetPendingUsers(c *gin.Context) {
-	currentUser, exists := c.Get("user")
-	if !exists {
-		utils.Unauthorized(c, "User not found in context")
-		return
-	}
-
-	admin, ok := currentUser.(*models.User)
+	query, ok := parseListQuery(c, userListSpec)
 	if !ok {
-		utils.InternalServerError(c, "Invalid user type", nil)
-		return
-	}
-
-	if !admin.CanManageUsers() {
-		utils.Forbidden(c, "Insufficient permissions")
 		return
 	}
-
-	var userList []models.User
-	err := h.db.Where("is_approved = ? AND role = ?", false, models.RoleUser).Find(&userList).Error
-
+	userList, page, err := h.users.List(c, query, repository.UserFilter{PendingOnly: true})
 	if err != nil {
-		utils.InternalServerError(c, "Failed to fetch pending users", err)
+		listFailed(c, err)
 		return
 	}
 
 	utils.Success(c, "Pending users retrieved successfully", gin.H{
-		"users": userList,
-		"count": len(userList),
+		"users":      userList,
+		"count":      len(userList),
+		"pagination": page,
 	})
 }
 
@@ -324,14 +283,8 @@ func (h *AdminHandler) ApproveUser(c *gin.Context) {
 		return
 	}
 
-	if !admin.CanManageUsers() {
-		utils.Forbidden(c, "Insufficient permissions to approve users")
-		return
-	}
-
-	var targetUser models.User
-	if err := h.db.Where("id = ?", userID).First(&targetUser).Error; err != nil {
-		utils.NotFound(c, "User not found")
+	targetUser, ok := h.findUser(c, userID)
+	if !ok {
 		return
 	}
 
@@ -345,12 +298,14 @@ func (h *AdminHandler) ApproveUser(c *gin.Context) {
 		return
 	}
 
+	before := *targetUser
 	targetUser.Approve(admin.ID)
 
-	if err := h.db.Save(&targetUser).Error; err != nil {
+	if err := h.users.Approve(c, targetUser); err != nil {
 		utils.InternalServerError(c, "Failed to approve user", err)
 		return
 	}
+	audit.Record(c, "user.approve", "user", targetUser.ID, before, targetUser)
 
 	utils.Success(c, "User approved successfully", targetUser)
 }
@@ -371,15 +326,8 @@ func (h *AdminHandler) RejectUser(c *gin.Context) {
 		return
 	}
 
-	if !admin.CanManageUsers() {
-		utils.Forbidden(c, "Insufficient permissions")
-		return
-	}
-
-	var targetUser models.User
-	// Fix: Use WHERE clause instead of direct parameter for proper UUID handling
-	if err := h.db.Where("id = ?", userID).First(&targetUser).Error; err != nil {
-		utils.NotFound(c, "User not found")
+	targetUser, ok := h.findUser(c, userID)
+	if !ok {
 		return
 	}
 
@@ -395,14 +343,49 @@ func (h *AdminHandler) RejectUser(c *gin.Context) {
 		return
 	}
 
-	// Delete the user
-	if err := h.db.Delete(&targetUser).Error; err != nil {
+	// Move the user to trash
+	if err := h.users.Trash(c, targetUser); err != nil {
 		utils.InternalServerError(c, "Failed to reject user", err)
 		return
 	}
+	audit.Record(c, "user.reject", "user", targetUser.ID, *targetUser, nil)
 
-	utils.Success(c, "User rejected and deleted successfully", gin.H{
+	utils.Success(c, "User rejected and moved to trash", gin.H{
 		"rejected_user_id":  targetUser.ID,
 		"rejected_username": targetUser.Username,
 	})
 }
+
+// findUser loads the user with the given ID, responding with 404 when there is none
+func (h *AdminHandler) findUser(c *gin.Context, userID string) (*models.User, bool) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		utils.NotFound(c, "User not found")
+		return nil, false
+	}
+	user, err := h.users.Get(c, id)
+	if err != nil {
+		utils.NotFound(c, "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// checkAssignable makes sure role exists and that user may hand it out,
+// responding with the error when not
+func (h *AdminHandler) checkAssignable(c *gin.Context, user *models.User, roleName string) bool {
+	role, err := h.policy.Role(roleName)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load roles", err)
+		return false
+	}
+	if role == nil {
+		utils.BadRequest(c, "Unknown role: "+roleName, nil)
+		return false
+	}
+	if !h.policy.CanAssign(user.Role, role) {
+		utils.Forbidden(c, "Cannot assign the "+role.Name+" role")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type AlertHandler struct {
+	db *gorm.DB
+}
+
+func NewAlertHandler(a *app.App) *AlertHandler {
+	return &AlertHandler{
+		db: a.DB,
+	}
+}
+
+// alertListSpec is the list query language of the alerts collection
+var alertListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"created_at":  {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"status":      {Column: "status", Type: listquery.String, Ops: listquery.Equality},
+		"severity":    {Column: "severity", Type: listquery.String, Ops: listquery.Equality},
+		"source":      {Column: "source", Type: listquery.String, Ops: listquery.Equality},
+		"type":        {Column: "type", Type: listquery.String, Ops: listquery.Equality},
+		"customer_id": {Column: "customer_id", Type: listquery.UUID, Ops: listquery.Equality},
+		"message_id":  {Column: "message_id", Type: listquery.UUID, Ops: listquery.Equality},
+		"rule_id":     {Column: "rule_id", Type: listquery.UUID, Ops: listquery.Equality},
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetAlerts lists rule and fraud signal alerts, newest first (admin only)
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	var alerts []models.Alert
+	page, ok := listPage(c, alertListSpec, h.db.WithContext(c).Model(&models.Alert{}), &alerts)
+	if !ok {
+		return
+	}
+
+	// Open alert counts per severity, for dashboard badges
+	var counts []struct {
+		Severity string
+		Count    int64
+	}
+	h.db.WithContext(c).Model(&models.Alert{}).
+		Select("severity, COUNT(*) AS count").
+		Where("status = ?", models.AlertStatusOpen).
+		Group("severity").
+		Scan(&counts)
+	open := make(map[string]int64, len(models.AlertSeverities))
+	for _, severity := range models.AlertSeverities {
+		open[severity] = 0
+	}
+	for _, count := range counts {
+		open[count.Severity] = count.Count
+	}
+
+	utils.Success(c, "Alerts retrieved successfully", gin.H{
+		"alerts":     alerts,
+		"open":       open,
+		"pagination": page,
+	})
+}
+
+// GetAlert returns an alert with the message and parsed transaction that raised it (admin only)
+func (h *AlertHandler) GetAlert(c *gin.Context) {
+	alert, ok := h.findAlert(c)
+	if !ok {
+		return
+	}
+
+	response := gin.H{"alert": alert}
+	if alert.MessageID != nil {
+		var message models.Message
+		if err := h.db.WithContext(c).Unscoped().First(&message, "id = ?", *alert.MessageID).Error; err == nil {
+			response["message"] = message
+		}
+		var transaction models.Transaction
+		if err := h.db.WithContext(c).First(&transaction, "message_id = ?", *alert.MessageID).Error; err == nil {
+			response["transaction"] = transaction
+		}
+	}
+
+	utils.Success(c, "Alert retrieved successfully", response)
+}
+
+// AcknowledgeAlert marks an alert as handled by the current user (admin only)
+func (h *AlertHandler) AcknowledgeAlert(c *gin.Context) {
+	alert, ok := h.findAlert(c)
+	if !ok {
+		return
+	}
+
+	var req types.AcknowledgeAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	if alert.Status == models.AlertStatusAcknowledged {
+		utils.Conflict(c, "Alert is already acknowledged")
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		utils.Unauthorized(c, "User not found in context")
+		return
+	}
+
+	alert.Acknowledge(user.ID, req.Note)
+	if err := h.db.WithContext(c).Save(alert).Error; err != nil {
+		utils.InternalServerError(c, "Failed to acknowledge alert", err)
+		return
+	}
+
+	utils.Success(c, "Alert acknowledged successfully", alert)
+}
+
+// findAlert loads the alert named by the :id route parameter
+func (h *AlertHandler) findAlert(c *gin.Context) (*models.Alert, bool) {
+	alertID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid alert ID format", nil)
+		return nil, false
+	}
+
+	var alert models.Alert
+	if err := h.db.WithContext(c).First(&alert, "id = ?", alertID).Error; err != nil {
+		utils.NotFound(c, "Alert not found")
+		return nil, false
+	}
+	return &alert, true
+}
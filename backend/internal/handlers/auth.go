@@ -1,31 +1,40 @@
 package handlers
 
 import (
-	"time"
-
+	"message-backend/internal/app"
 	"message-backend/internal/auth"
+	"message-backend/internal/clock"
 	"message-backend/internal/config"
-	"message-backend/internal/database"
+	"message-backend/internal/metrics"
 	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/repository"
+	"message-backend/internal/tenancy"
 	"message-backend/internal/types"
 	"message-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	db         *gorm.DB
+	users      repository.UserRepository
 	jwtService *auth.JWTService
 	cfg        *config.Config
+	clock      clock.Clock
+	policy     *rbac.Policy
+	orgs       *tenancy.Directory
+	metrics    *metrics.Metrics
 }
 
-func NewAuthHandler() *AuthHandler {
-	cfg := config.LoadConfig()
+func NewAuthHandler(a *app.App, users repository.UserRepository) *AuthHandler {
 	return &AuthHandler{
-		db:         database.GetDB(),
-		jwtService: auth.NewJWTService(cfg),
-		cfg:        cfg,
+		users:      users,
+		jwtService: a.JWT,
+		cfg:        a.Config,
+		clock:      a.Clock,
+		policy:     a.Policy,
+		orgs:       a.Orgs,
+		metrics:    a.Metrics,
 	}
 }
 
@@ -38,9 +47,20 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
-	var existingUser models.User
-	if err := h.db.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
+	org, ok := requestOrganization(c, h.orgs)
+	if !ok {
+		return
+	}
+	if !org.Settings.AllowSignup {
+		utils.Forbidden(c, "This organization does not accept signups")
+		return
+	}
+
+	// Check if user already exists. Usernames and emails are unique across organizations.
+	if exists, err := h.users.Exists(c, req.Username, req.Email); err != nil {
+		utils.InternalServerError(c, "Failed to check existing users", err)
+		return
+	} else if exists {
 		utils.Conflict(c, "User already exists")
 		return
 	}
@@ -55,11 +75,12 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		role = req.Role
 	}
 	user := &models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		RawPass:  req.Password,
-		Role:     role,
-		IsActive: true,
+		OrganizationID: org.ID,
+		Username:       req.Username,
+		Email:          req.Email,
+		RawPass:        req.Password,
+		Role:           role,
+		IsActive:       true,
 	}
 
 	if err := user.SetPassword(req.Password); err != nil {
@@ -67,10 +88,13 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Create(user).Error; err != nil {
+	if err := h.users.Create(tenancy.WithOrganization(c, org.ID), user); err != nil {
 		utils.InternalServerError(c, "Failed to create user", err)
 		return
 	}
+	if user.IsPendingApproval() {
+		h.metrics.SignupsPendingApproval.Inc()
+	}
 
 	response := types.SignUpResponse{
 		User: user,
@@ -88,38 +112,57 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	// Emails are unique across organizations, and the token carries the user's own
+	ctx := tenancy.System(c)
+	user, err := h.users.GetByEmail(ctx, req.Email)
+	if err != nil {
+		h.metrics.Logins.Inc(metrics.LoginFailure)
 		utils.Unauthorized(c, "Invalid credentials")
 		return
 	}
 
 	// Check if user is approved (if approval system is enabled)
 	if !user.IsApproved {
+		h.metrics.Logins.Inc(metrics.LoginFailure)
 		utils.Unauthorized(c, "Account pending admin approval")
 		return
 	}
 
 	if !user.IsActive {
+		h.metrics.Logins.Inc(metrics.LoginFailure)
 		utils.Unauthorized(c, "Account is deactivated")
 		return
 	}
 
 	if err := user.ValidatePassword(req.Password); err != nil {
+		h.metrics.Logins.Inc(metrics.LoginFailure)
 		utils.Unauthorized(c, "Invalid credentials")
 		return
 	}
 
-	h.db.Model(&user).Update("last_login", time.Now())
+	org, err := h.orgs.Get(c, user.OrganizationID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load organization", nil)
+		return
+	}
+	if org == nil || !org.IsActive {
+		h.metrics.Logins.Inc(metrics.LoginFailure)
+		utils.Unauthorized(c, "Organization is deactivated")
+		return
+	}
+
+	h.users.RecordLogin(ctx, user, h.clock.Now())
 
-	tokens, err := h.jwtService.GenerateTokenPair(&user, h.cfg)
+	tokens, err := h.jwtService.GenerateTokenPair(user, h.cfg)
 	if err != nil {
 		utils.InternalServerError(c, "Failed to generate tokens", err)
 		return
 	}
 
+	h.metrics.Logins.Inc(metrics.LoginSuccess)
+
 	response := types.AuthResponse{
-		User:         &user,
+		User:         user,
 		AccessToken:  tokens["access_token"],
 		RefreshToken: tokens["refresh_token"],
 		ExpiresIn:    int(h.cfg.JWTAccessExpiration.Seconds()),
@@ -170,5 +213,8 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	utils.Success(c, "Profile retrieved successfully", userModel)
+	profile := *userModel
+	profile.Permissions = h.policy.Permissions(profile.Role)
+
+	utils.Success(c, "Profile retrieved successfully", profile)
 }
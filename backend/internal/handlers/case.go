@@ -0,0 +1,595 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/clock"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/timeline"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+// errUnknownMessages is returned when linked message IDs don't belong to the case's customer
+var errUnknownMessages = errors.New("messages not found for this customer")
+
+type CaseHandler struct {
+	db     *gorm.DB
+	clock  clock.Clock
+	policy *rbac.Policy
+}
+
+func NewCaseHandler(a *app.App) *CaseHandler {
+	return &CaseHandler{
+		db:     a.DB,
+		clock:  a.Clock,
+		policy: a.Policy,
+	}
+}
+
+// caseListSpec is the list query language of the cases collection
+var caseListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"resolution_due_at":     {Column: "resolution_due_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"first_response_due_at": {Column: "first_response_due_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"created_at":            {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"updated_at":            {Column: "updated_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"status":                {Column: "status", Type: listquery.String, Ops: listquery.Equality},
+		"severity":              {Column: "severity", Type: listquery.String, Ops: listquery.Equality},
+		"customer_id":           {Column: "customer_id", Type: listquery.UUID, Ops: listquery.Equality},
+		"title":                 {Column: "title", Type: listquery.String, Ops: []string{listquery.OpContains}},
+	},
+	DefaultSort:  "resolution_due_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// caseEventListSpec is the list query language of a case's history
+var caseEventListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"created_at": {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"action":     {Column: "action", Type: listquery.String, Ops: listquery.Equality},
+		"actor_id":   {Column: "actor_id", Type: listquery.UUID, Ops: listquery.Equality},
+	},
+	DefaultSort:  "created_at",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+}
+
+// GetCases lists cases with filters, most urgent due date first (admin only)
+func (h *CaseHandler) GetCases(c *gin.Context) {
+	query := h.db.WithContext(c).Model(&models.Case{})
+
+	// assignee accepts a user ID, "me" or "none"
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "none":
+		query = query.Where("assignee_id IS NULL")
+	case "me":
+		user, ok := currentUser(c)
+		if !ok {
+			utils.Unauthorized(c, "User not found in context")
+			return
+		}
+		query = query.Where("assignee_id = ?", user.ID)
+	default:
+		assigneeID, err := uuid.Parse(assignee)
+		if err != nil {
+			utils.BadRequest(c, "Invalid assignee ID format", nil)
+			return
+		}
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+
+	if overdue, _ := strconv.ParseBool(c.Query("overdue")); overdue {
+		query = query.Where("status IN ? AND resolution_due_at < ?",
+			[]string{models.CaseStatusOpen, models.CaseStatusInvestigating}, h.clock.Now())
+	}
+
+	var cases []models.Case
+	page, ok := listPage(c, caseListSpec, query.Preload("Assignee"), &cases)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Cases retrieved successfully", gin.H{
+		"cases":      cases,
+		"pagination": page,
+	})
+}
+
+// CreateCase opens a case on a customer (admin only)
+func (h *CaseHandler) CreateCase(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		utils.Unauthorized(c, "User not found in context")
+		return
+	}
+
+	var req types.CreateCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	customerID, err := uuid.Parse(req.CustomerID)
+	if err != nil {
+		utils.BadRequest(c, "Invalid customer ID format", nil)
+		return
+	}
+	var customer models.Customer
+	if err := h.db.WithContext(c).First(&customer, "id = ?", customerID).Error; err != nil {
+		utils.NotFound(c, "Customer not found")
+		return
+	}
+
+	messageIDs, err := parseUUIDs(req.MessageIDs)
+	if err != nil {
+		utils.BadRequest(c, "Invalid message ID format", nil)
+		return
+	}
+	alertIDs, err := parseUUIDs(req.AlertIDs)
+	if err != nil {
+		utils.BadRequest(c, "Invalid alert ID format", nil)
+		return
+	}
+
+	kase := &models.Case{
+		CustomerID:  customer.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		Severity:    req.Severity,
+		Status:      models.CaseStatusOpen,
+		CreatedBy:   user.ID,
+		CreatedAt:   h.clock.Now(),
+	}
+	kase.ApplySLA()
+
+	if req.AssigneeID != "" {
+		assignee, ok := h.findAssignee(c, req.AssigneeID)
+		if !ok {
+			return
+		}
+		kase.AssigneeID = &assignee.ID
+	}
+
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(kase).Error; err != nil {
+			return err
+		}
+		if err := recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionCreated, "", kase.Status, kase.Title); err != nil {
+			return err
+		}
+		if err := timeline.Record(tx, customer.ID, models.CustomerEventCaseOpened, timeline.Staff(user), "Case opened: "+kase.Title,
+			map[string]interface{}{"case_id": kase.ID, "severity": kase.Severity}); err != nil {
+			return err
+		}
+		if kase.AssigneeID != nil {
+			if err := recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionAssigned, "", kase.AssigneeID.String(), ""); err != nil {
+				return err
+			}
+		}
+
+		// Alerts bring their messages into the case and count as handled
+		if len(alertIDs) > 0 {
+			var alerts []models.Alert
+			if err := tx.Where("id IN ? AND customer_id = ?", alertIDs, customer.ID).Find(&alerts).Error; err != nil {
+				return err
+			}
+			if len(alerts) != len(uniqueUUIDs(alertIDs)) {
+				return errUnknownMessages
+			}
+			for i := range alerts {
+				if alerts[i].MessageID != nil {
+					messageIDs = append(messageIDs, *alerts[i].MessageID)
+				}
+				if alerts[i].Status == models.AlertStatusOpen {
+					alerts[i].Acknowledge(user.ID, fmt.Sprintf("Moved to case %s", kase.ID))
+					if err := tx.Save(&alerts[i]).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return h.linkMessages(tx, kase, user.ID, messageIDs)
+	})
+	if errors.Is(err, errUnknownMessages) {
+		utils.BadRequest(c, "Some messages or alerts do not belong to this customer", nil)
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to create case", err)
+		return
+	}
+
+	utils.Created(c, "Case created successfully", kase)
+}
+
+// GetCase returns a case with its customer, messages and comments (admin only)
+func (h *CaseHandler) GetCase(c *gin.Context) {
+	caseID, ok := parseCaseID(c)
+	if !ok {
+		return
+	}
+
+	var kase models.Case
+	err := h.db.WithContext(c).Preload("Customer").
+		Preload("Assignee").
+		Preload("Messages", func(db *gorm.DB) *gorm.DB { return db.Order("timestamp DESC") }).
+		Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&kase, "id = ?", caseID).Error
+	if err != nil {
+		utils.NotFound(c, "Case not found")
+		return
+	}
+	maskCustomer(c, h.policy, kase.Customer)
+
+	utils.Success(c, "Case retrieved successfully", kase)
+}
+
+// UpdateCase edits a case's title, description or severity (admin only)
+func (h *CaseHandler) UpdateCase(c *gin.Context) {
+	user, kase, ok := h.loadForChange(c)
+	if !ok {
+		return
+	}
+
+	var req types.UpdateCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if req.Title != nil && *req.Title != kase.Title {
+			if err := recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionUpdated, "", "", "title"); err != nil {
+				return err
+			}
+			kase.Title = *req.Title
+		}
+		if req.Description != nil && *req.Description != kase.Description {
+			if err := recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionUpdated, "", "", "description"); err != nil {
+				return err
+			}
+			kase.Description = *req.Description
+		}
+		if req.Severity != nil && *req.Severity != kase.Severity {
+			if err := recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionUpdated, kase.Severity, *req.Severity, "severity"); err != nil {
+				return err
+			}
+			kase.Severity = *req.Severity
+			kase.ApplySLA()
+		}
+		return tx.Save(kase).Error
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to update case", err)
+		return
+	}
+
+	utils.Success(c, "Case updated successfully", kase)
+}
+
+// AssignCase assigns a case to a staff user, or unassigns it (admin only)
+func (h *CaseHandler) AssignCase(c *gin.Context) {
+	user, kase, ok := h.loadForChange(c)
+	if !ok {
+		return
+	}
+
+	var req types.AssignCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	var assigneeID *uuid.UUID
+	if req.AssigneeID != nil && *req.AssigneeID != "" {
+		assignee, ok := h.findAssignee(c, *req.AssigneeID)
+		if !ok {
+			return
+		}
+		assigneeID = &assignee.ID
+	}
+
+	from, to := "", ""
+	if kase.AssigneeID != nil {
+		from = kase.AssigneeID.String()
+	}
+	if assigneeID != nil {
+		to = assigneeID.String()
+	}
+	if from == to {
+		utils.Success(c, "Case assignment unchanged", kase)
+		return
+	}
+
+	kase.AssigneeID = assigneeID
+	kase.Assignee = nil
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(kase).Error; err != nil {
+			return err
+		}
+		return recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionAssigned, from, to, "")
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to assign case", err)
+		return
+	}
+
+	utils.Success(c, "Case assigned successfully", kase)
+}
+
+// TransitionCase moves a case to another status (admin only)
+func (h *CaseHandler) TransitionCase(c *gin.Context) {
+	user, kase, ok := h.loadForChange(c)
+	if !ok {
+		return
+	}
+
+	var req types.TransitionCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	if !kase.CanTransition(req.Status) {
+		utils.Conflict(c, fmt.Sprintf("Cannot move a case from %s to %s", kase.Status, req.Status))
+		return
+	}
+
+	from := kase.Status
+	kase.Status = req.Status
+	kase.MarkResponded()
+	if kase.IsClosed() {
+		now := h.clock.Now()
+		kase.ResolvedAt = &now
+	} else {
+		kase.ResolvedAt = nil
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(kase).Error; err != nil {
+			return err
+		}
+		if req.Note != "" {
+			comment := &models.CaseComment{CaseID: kase.ID, AuthorID: user.ID, Body: req.Note}
+			if err := tx.Create(comment).Error; err != nil {
+				return err
+			}
+		}
+		return recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionStatusChanged, from, kase.Status, req.Note)
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to change case status", err)
+		return
+	}
+
+	utils.Success(c, "Case status changed successfully", kase)
+}
+
+// AddCaseComment comments on a case (admin only)
+func (h *CaseHandler) AddCaseComment(c *gin.Context) {
+	user, kase, ok := h.loadForChange(c)
+	if !ok {
+		return
+	}
+
+	var req types.CaseCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	comment := &models.CaseComment{CaseID: kase.ID, AuthorID: user.ID, Body: req.Body}
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if kase.FirstRespondedAt == nil {
+			kase.MarkResponded()
+			if err := tx.Model(kase).UpdateColumn("first_responded_at", kase.FirstRespondedAt).Error; err != nil {
+				return err
+			}
+		}
+		return recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionCommented, "", "", comment.ID.String())
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to add comment", err)
+		return
+	}
+
+	utils.Created(c, "Comment added successfully", comment)
+}
+
+// LinkCaseMessages relates more of the customer's messages to a case (admin only)
+func (h *CaseHandler) LinkCaseMessages(c *gin.Context) {
+	user, kase, ok := h.loadForChange(c)
+	if !ok {
+		return
+	}
+
+	messageIDs, ok := bindCaseMessages(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		return h.linkMessages(tx, kase, user.ID, messageIDs)
+	})
+	if errors.Is(err, errUnknownMessages) {
+		utils.BadRequest(c, "Some messages do not belong to this customer", nil)
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to link messages", err)
+		return
+	}
+
+	utils.Success(c, "Messages linked successfully", nil)
+}
+
+// UnlinkCaseMessages removes messages from a case (admin only)
+func (h *CaseHandler) UnlinkCaseMessages(c *gin.Context) {
+	user, kase, ok := h.loadForChange(c)
+	if !ok {
+		return
+	}
+
+	messageIDs, ok := bindCaseMessages(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM case_messages WHERE case_id = ? AND message_id IN ?", kase.ID, messageIDs)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return recordCaseEvent(tx, kase.ID, user.ID, models.CaseActionMessagesRemoved, "", "",
+			fmt.Sprintf("%d messages", result.RowsAffected))
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to unlink messages", err)
+		return
+	}
+
+	utils.Success(c, "Messages unlinked successfully", nil)
+}
+
+// GetCaseHistory lists everything that happened to a case, oldest first (admin only)
+func (h *CaseHandler) GetCaseHistory(c *gin.Context) {
+	caseID, ok := parseCaseID(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if h.db.WithContext(c).Model(&models.Case{}).Where("id = ?", caseID).Count(&count); count == 0 {
+		utils.NotFound(c, "Case not found")
+		return
+	}
+
+	var events []models.CaseEvent
+	query := h.db.WithContext(c).Model(&models.CaseEvent{}).Where("case_id = ?", caseID)
+	page, ok := listPage(c, caseEventListSpec, query, &events)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Case history retrieved successfully", gin.H{
+		"history":    events,
+		"pagination": page,
+	})
+}
+
+// linkMessages relates messages of the case's customer to the case and records it
+func (h *CaseHandler) linkMessages(tx *gorm.DB, kase *models.Case, actorID uuid.UUID, messageIDs []uuid.UUID) error {
+	messageIDs = uniqueUUIDs(messageIDs)
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	var found int64
+	if err := tx.Model(&models.Message{}).Where("id IN ? AND customer_id = ?", messageIDs, kase.CustomerID).Count(&found).Error; err != nil {
+		return err
+	}
+	if int(found) != len(messageIDs) {
+		return errUnknownMessages
+	}
+
+	result := tx.Exec(`
+		INSERT INTO case_messages (case_id, message_id)
+		SELECT ?, id FROM messages WHERE id IN ?
+		ON CONFLICT DO NOTHING`, kase.ID, messageIDs)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return recordCaseEvent(tx, kase.ID, actorID, models.CaseActionMessagesLinked, "", "",
+		fmt.Sprintf("%d messages", result.RowsAffected))
+}
+
+// loadForChange resolves the acting user and the case named by the :id route parameter
+func (h *CaseHandler) loadForChange(c *gin.Context) (*models.User, *models.Case, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		utils.Unauthorized(c, "User not found in context")
+		return nil, nil, false
+	}
+
+	caseID, ok := parseCaseID(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var kase models.Case
+	if err := h.db.WithContext(c).First(&kase, "id = ?", caseID).Error; err != nil {
+		utils.NotFound(c, "Case not found")
+		return nil, nil, false
+	}
+	return user, &kase, true
+}
+
+// findAssignee loads an active, approved staff user a case can be assigned to
+func (h *CaseHandler) findAssignee(c *gin.Context, id string) (*models.User, bool) {
+	assigneeID, err := uuid.Parse(id)
+	if err != nil {
+		utils.BadRequest(c, "Invalid assignee ID format", nil)
+		return nil, false
+	}
+
+	var assignee models.User
+	if err := h.db.WithContext(c).Where("id = ? AND is_active = true AND is_approved = true", assigneeID).First(&assignee).Error; err != nil {
+		utils.NotFound(c, "Assignee not found or inactive")
+		return nil, false
+	}
+	return &assignee, true
+}
+
+// recordCaseEvent appends an entry to a case's history
+func recordCaseEvent(tx *gorm.DB, caseID, actorID uuid.UUID, action, from, to, detail string) error {
+	return tx.Create(&models.CaseEvent{
+		CaseID:    caseID,
+		ActorID:   actorID,
+		Action:    action,
+		FromValue: from,
+		ToValue:   to,
+		Detail:    detail,
+	}).Error
+}
+
+// bindCaseMessages parses the message IDs of a link or unlink request
+func bindCaseMessages(c *gin.Context) ([]uuid.UUID, bool) {
+	var req types.CaseMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return nil, false
+	}
+
+	messageIDs, err := parseUUIDs(req.MessageIDs)
+	if err != nil {
+		utils.BadRequest(c, "Invalid message ID format", nil)
+		return nil, false
+	}
+	return messageIDs, true
+}
+
+// parseCaseID parses the :id route parameter as a case ID
+func parseCaseID(c *gin.Context) (uuid.UUID, bool) {
+	caseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid case ID format", nil)
+		return uuid.Nil, false
+	}
+	return caseID, true
+}
@@ -1,27 +1,41 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
-	"message-backend/internal/database"
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/clock"
+	"message-backend/internal/listquery"
 	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/repository"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/timeline"
 	"message-backend/internal/types"
 	"message-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 )
 
 type CustomerHandler struct {
-	db *gorm.DB
+	customers repository.CustomerRepository
+	clock     clock.Clock
+	policy    *rbac.Policy
+	orgs      *tenancy.Directory
 }
 
-func NewCustomerHandler() *CustomerHandler {
+func NewCustomerHandler(a *app.App, customers repository.CustomerRepository) *CustomerHandler {
 	return &CustomerHandler{
-		db: database.GetDB(),
+		customers: customers,
+		clock:     a.Clock,
+		policy:    a.Policy,
+		orgs:      a.Orgs,
 	}
 }
 
@@ -33,9 +47,16 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 		return
 	}
 
-	// Check if customer with phone number already exists
-	var existingCustomer models.Customer
-	if err := h.db.Where("phone_number = ?", req.PhoneNumber).First(&existingCustomer).Error; err == nil {
+	org, ok := requestOrganization(c, h.orgs)
+	if !ok {
+		return
+	}
+
+	// Check if customer with phone number already exists. Phone numbers are unique across organizations.
+	if taken, err := h.customers.PhoneTaken(c, req.PhoneNumber, uuid.Nil); err != nil {
+		utils.InternalServerError(c, "Failed to check phone number", err)
+		return
+	} else if taken {
 		utils.Conflict(c, "Customer with this phone number already exists")
 		return
 	}
@@ -53,7 +74,7 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 		ExpiryDate:     req.ExpiryDate,
 		CVV:            req.CVV,
 		IsActive:       true,
-		LastActive:     time.Now(),
+		LastActive:     h.clock.Now(),
 	}
 
 	// Parse DOB if provided
@@ -66,7 +87,8 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 		customer.DOB = &dob
 	}
 
-	if err := h.db.Create(customer).Error; err != nil {
+	tenancy.Set(c, org.ID)
+	if err := h.customers.Create(c, customer); err != nil {
 		utils.InternalServerError(c, "Failed to create customer", err)
 		return
 	}
@@ -74,34 +96,41 @@ func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
 	utils.Created(c, "Customer created successfully", customer)
 }
 
-// GetCustomers returns all customers (admin only)
-func (h *CustomerHandler) GetCustomers(c *gin.Context) {
-	var customers []models.Customer
-
-	// Get query parameters for filtering
-	phone := c.Query("phone")
-	email := c.Query("email")
-	isActive := c.Query("is_active")
-
-	query := h.db
+// customerListSpec is the list query language of customer collections
+var customerListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"created_at":    {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"last_active":   {Column: "last_active", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"message_count": {Column: "message_count", Type: listquery.Number, Sortable: true, Ops: listquery.Comparable},
+		"total_limit":   {Column: "total_limit", Type: listquery.Number, Sortable: true, Ops: listquery.Comparable},
+		"full_name":     {Column: "full_name", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"phone_number":  {Column: "phone_number", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"email":         {Column: "email", Type: listquery.String, Ops: listquery.Text},
+		"is_active":     {Column: "is_active", Type: listquery.Bool, Ops: listquery.Flag},
+		"legal_hold":    {Column: "legal_hold", Type: listquery.Bool, Ops: listquery.Flag},
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
 
-	if phone != "" {
-		query = query.Where("phone_number LIKE ?", "%"+phone+"%")
-	}
-	if email != "" {
-		query = query.Where("email LIKE ?", "%"+email+"%")
-	}
-	if isActive != "" {
-		active, _ := strconv.ParseBool(isActive)
-		query = query.Where("is_active = ?", active)
+// GetCustomers returns a page of customers (authenticated users)
+func (h *CustomerHandler) GetCustomers(c *gin.Context) {
+	query, ok := parseListQuery(c, customerListSpec)
+	if !ok {
+		return
 	}
-
-	if err := query.Find(&customers).Error; err != nil {
-		utils.InternalServerError(c, "Failed to fetch customers", err)
+	customers, page, err := h.customers.List(c, query, "")
+	if err != nil {
+		listFailed(c, err)
 		return
 	}
+	maskCustomers(c, h.policy, customers)
 
-	utils.Success(c, "Customers retrieved successfully", customers)
+	utils.Success(c, "Customers retrieved successfully", gin.H{
+		"customers":  customers,
+		"pagination": page,
+	})
 }
 
 // GetTopCustomers returns top customers by credit limit
@@ -116,14 +145,9 @@ func (h *CustomerHandler) GetTopCustomers(c *gin.Context) {
 		limit = 50
 	}
 
-	var customers []models.Customer
-	result := h.db.Where("is_active = ?", true).
-		Order("total_limit DESC").
-		Limit(limit).
-		Find(&customers)
-
-	if result.Error != nil {
-		utils.InternalServerError(c, "Failed to fetch top customers", result.Error)
+	customers, err := h.customers.Top(c, limit)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch top customers", err)
 		return
 	}
 
@@ -160,7 +184,7 @@ func (h *CustomerHandler) GetTopCustomers(c *gin.Context) {
 	utils.Success(c, "Top customers retrieved successfully", gin.H{"customers": topCustomers})
 }
 
-// SearchCustomers searches customers by name or email
+// SearchCustomers searches customers by name, email or phone number
 func (h *CustomerHandler) SearchCustomers(c *gin.Context) {
 	query := c.Query("q")
 	if strings.TrimSpace(query) == "" {
@@ -168,30 +192,14 @@ func (h *CustomerHandler) SearchCustomers(c *gin.Context) {
 		return
 	}
 
-	// Get limit from query param, default to 20
-	limitStr := c.DefaultQuery("limit", "20")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 20
-	}
-	if limit > 100 { // Max limit for search
-		limit = 100
+	// Search by name, email or phone number (case insensitive)
+	listQuery, ok := parseListQuery(c, customerSearchSpec)
+	if !ok {
+		return
 	}
-
-	var customers []models.Customer
-
-	// Search by name or email (case insensitive)
-	searchPattern := "%" + strings.ToLower(query) + "%"
-	result := h.db.Where(
-		"LOWER(full_name) LIKE ? OR LOWER(name) LIKE ? OR LOWER(email) LIKE ? OR phone_number LIKE ?",
-		searchPattern, searchPattern, searchPattern, searchPattern,
-	).
-		Order("full_name ASC, name ASC").
-		Limit(limit).
-		Find(&customers)
-
-	if result.Error != nil {
-		utils.InternalServerError(c, "Failed to search customers", result.Error)
+	customers, page, err := h.customers.List(c, listQuery, query)
+	if err != nil {
+		listFailed(c, err)
 		return
 	}
 
@@ -226,28 +234,31 @@ func (h *CustomerHandler) SearchCustomers(c *gin.Context) {
 	}
 
 	utils.Success(c, fmt.Sprintf("Found %d customers matching '%s'", len(searchResults), query),
-		gin.H{"customers": searchResults, "query": query})
+		gin.H{"customers": searchResults, "query": query, "pagination": page})
+}
+
+// customerSearchSpec is customerListSpec with search's smaller pages, sorted by name
+var customerSearchSpec = &listquery.Spec{
+	Fields:       customerListSpec.Fields,
+	DefaultSort:  "full_name",
+	DefaultLimit: 20,
+	MaxLimit:     100,
 }
 
 // GetCustomer returns a specific customer by ID (admin only)
 func (h *CustomerHandler) GetCustomer(c *gin.Context) {
-	id := c.Param("id")
-
-	// Convert string ID to uint
-	var customerID uint
-	if parsedID, err := strconv.ParseUint(id, 10, 32); err != nil {
-		utils.BadRequest(c, "Invalid customer ID format", nil)
+	customerID, ok := parseCustomerID(c)
+	if !ok {
 		return
-	} else {
-		customerID = uint(parsedID)
 	}
 
-	var customer models.Customer
-	if err := h.db.First(&customer, customerID).Error; err != nil {
+	customer, err := h.customers.Get(c, customerID)
+	if err != nil {
 		utils.NotFound(c, "Customer not found")
 		return
 	}
 
+	maskCustomer(c, h.policy, customer)
 	utils.Success(c, "Customer retrieved successfully", customer)
 }
 
@@ -260,24 +271,29 @@ func (h *CustomerHandler) GetMyProfile(c *gin.Context) {
 		return
 	}
 
-	var customer models.Customer
-	if err := h.db.Where("device_id = ?", deviceID).First(&customer).Error; err != nil {
+	customer, err := h.customers.GetByDevice(c, deviceID)
+	if err != nil {
 		utils.NotFound(c, "Customer not found")
 		return
 	}
 
+	maskCustomer(c, h.policy, customer)
 	utils.Success(c, "Profile retrieved successfully", customer)
 }
 
 // UpdateCustomer updates customer information (admin only)
 func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
-	id := c.Param("id")
+	customerID, ok := parseCustomerID(c)
+	if !ok {
+		return
+	}
 
-	var customer models.Customer
-	if err := h.db.First(&customer, id).Error; err != nil {
+	stored, err := h.customers.Get(c, customerID)
+	if err != nil {
 		utils.NotFound(c, "Customer not found")
 		return
 	}
+	customer := *stored
 
 	var req types.UpdateCustomerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -285,22 +301,37 @@ func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
 		return
 	}
 
+	if (req.TotalLimit != nil || req.AvailableLimit != nil) && !hasPermission(c, h.policy, models.PermLimitsWrite) {
+		utils.Forbidden(c, "Changing limits requires the "+models.PermLimitsWrite+" permission")
+		return
+	}
+	if (req.CardNumber != "" || req.ExpiryDate != "" || req.CVV != "") && !hasPermission(c, h.policy, models.PermCustomersReadPAN) {
+		utils.Forbidden(c, "Changing card details requires the "+models.PermCustomersReadPAN+" permission")
+		return
+	}
+
 	// Check for phone number conflicts if updating phone
 	if req.PhoneNumber != "" && req.PhoneNumber != customer.PhoneNumber {
-		var existingCustomer models.Customer
-		if err := h.db.Where("phone_number = ? AND id != ?", req.PhoneNumber, customer.ID).First(&existingCustomer).Error; err == nil {
+		if taken, err := h.customers.PhoneTaken(c, req.PhoneNumber, customer.ID); err != nil {
+			utils.InternalServerError(c, "Failed to check phone number", err)
+			return
+		} else if taken {
 			utils.Conflict(c, "Phone number already exists")
 			return
 		}
 	}
 
+	user, _ := currentUser(c)
+	before := customer
 	h.updateCustomerFields(&customer, req)
 
-	if err := h.db.Save(&customer).Error; err != nil {
+	if err := h.customers.Update(c, &before, &customer, timeline.Staff(user)); err != nil {
 		utils.InternalServerError(c, "Failed to update customer", err)
 		return
 	}
+	audit.Record(c, "customer.update", "customer", customer.ID, before, customer)
 
+	maskCustomer(c, h.policy, &customer)
 	utils.Success(c, "Customer updated successfully", customer)
 }
 
@@ -313,11 +344,12 @@ func (h *CustomerHandler) UpdateMyProfile(c *gin.Context) {
 		return
 	}
 
-	var customer models.Customer
-	if err := h.db.Where("device_id = ?", deviceID).First(&customer).Error; err != nil {
+	stored, err := h.customers.GetByDevice(c, deviceID)
+	if err != nil {
 		utils.NotFound(c, "Customer not found")
 		return
 	}
+	customer := *stored
 
 	var req types.CustomerSelfUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -327,8 +359,10 @@ func (h *CustomerHandler) UpdateMyProfile(c *gin.Context) {
 
 	// Check for phone number conflicts if updating phone
 	if req.PhoneNumber != "" && req.PhoneNumber != customer.PhoneNumber {
-		var existingCustomer models.Customer
-		if err := h.db.Where("phone_number = ? AND id != ?", req.PhoneNumber, customer.ID).First(&existingCustomer).Error; err == nil {
+		if taken, err := h.customers.PhoneTaken(c, req.PhoneNumber, customer.ID); err != nil {
+			utils.InternalServerError(c, "Failed to check phone number", err)
+			return
+		} else if taken {
 			utils.Conflict(c, "Phone number already exists")
 			return
 		}
@@ -345,14 +379,17 @@ func (h *CustomerHandler) UpdateMyProfile(c *gin.Context) {
 		// Financial and card fields are intentionally omitted for security
 	}
 
+	before := customer
 	h.updateCustomerFields(&customer, updateReq)
-	customer.UpdatedAt = time.Now()
+	customer.UpdatedAt = h.clock.Now()
 
-	if err := h.db.Save(&customer).Error; err != nil {
+	if err := h.customers.Update(c, &before, &customer, timeline.Customer); err != nil {
 		utils.InternalServerError(c, "Failed to update profile", err)
 		return
 	}
+	audit.Record(c, "customer.profile_update", "customer", customer.ID, before, customer)
 
+	maskCustomer(c, h.policy, &customer)
 	utils.Success(c, "Profile updated successfully", customer)
 }
 
@@ -370,11 +407,14 @@ func (h *CustomerHandler) updateCustomerFields(customer *models.Customer, req ty
 	if req.Name != "" {
 		customer.Name = req.Name
 	}
-	if req.TotalLimit > 0 {
-		customer.TotalLimit = req.TotalLimit
+	if req.TotalLimit != nil {
+		customer.TotalLimit = *req.TotalLimit
 	}
-	if req.AvailableLimit >= 0 {
-		customer.AvailableLimit = req.AvailableLimit
+	if req.AvailableLimit != nil {
+		customer.AvailableLimit = *req.AvailableLimit
+	}
+	if req.IsActive != nil {
+		customer.IsActive = *req.IsActive
 	}
 	if req.CardholderName != "" {
 		customer.CardholderName = req.CardholderName
@@ -398,14 +438,52 @@ func (h *CustomerHandler) updateCustomerFields(customer *models.Customer, req ty
 	}
 }
 
-// DeleteCustomer soft deletes a customer (sets is_active = false) - admin only
+// DeleteCustomer moves a customer and their messages to trash - admin only
 func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
-	id := c.Param("id")
+	customerID, ok := parseCustomerID(c)
+	if !ok {
+		return
+	}
+	user, _ := currentUser(c)
 
-	if err := h.db.Model(&models.Customer{}).Where("id = ?", id).Update("is_active", false).Error; err != nil {
+	err := h.customers.Trash(c, customerID, timeline.Staff(user))
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.NotFound(c, "Customer not found")
+		return
+	}
+	if err != nil {
 		utils.InternalServerError(c, "Failed to delete customer", err)
 		return
 	}
 
-	utils.Success(c, "Customer deleted successfully", nil)
+	audit.Record(c, "customer.delete", "customer", customerID, nil, nil)
+
+	utils.Success(c, "Customer moved to trash", nil)
+}
+
+// parseCustomerID parses the :id route parameter, responding with 400 when it is not a UUID
+func parseCustomerID(c *gin.Context) (uuid.UUID, bool) {
+	customerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid customer ID format", nil)
+		return uuid.Nil, false
+	}
+	return customerID, true
+}
+
+// maskCustomer hides card data from users without the customers.read_pan permission
+func maskCustomer(c *gin.Context, policy *rbac.Policy, customer *models.Customer) {
+	if customer != nil && !hasPermission(c, policy, models.PermCustomersReadPAN) {
+		customer.MaskCardData()
+	}
+}
+
+// maskCustomers is maskCustomer for a page of customers
+func maskCustomers(c *gin.Context, policy *rbac.Policy, customers []models.Customer) {
+	if hasPermission(c, policy, models.PermCustomersReadPAN) {
+		return
+	}
+	for i := range customers {
+		customers[i].MaskCardData()
+	}
 }
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/repository"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type LabelHandler struct {
+	db *gorm.DB
+}
+
+func NewLabelHandler(a *app.App) *LabelHandler {
+	return &LabelHandler{
+		db: a.DB,
+	}
+}
+
+// labelListSpec is the list query language of the labels collection
+var labelListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"name":       {Column: "labels.name", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"created_at": {Column: "labels.created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"color":      {Column: "labels.color", Type: listquery.String, Ops: listquery.Equality},
+	},
+	DefaultSort:  "name",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+	IDColumn:     "labels.id",
+}
+
+// GetLabels lists labels with the number of the organization's messages carrying each
+func (h *LabelHandler) GetLabels(c *gin.Context) {
+	var labels []types.LabelWithCount
+	query := h.db.WithContext(c).Model(&models.Label{}).
+		Select("labels.*, COUNT(messages.id) AS message_count").
+		Joins("LEFT JOIN message_labels ON message_labels.label_id = labels.id").
+		Joins("LEFT JOIN (?) AS messages ON messages.id = message_labels.message_id", h.messageIDs(c)).
+		Group("labels.id")
+	page, ok := listPage(c, labelListSpec, query, &labels)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Labels retrieved successfully", gin.H{
+		"labels":     labels,
+		"pagination": page,
+	})
+}
+
+// CreateLabel adds a label
+func (h *LabelHandler) CreateLabel(c *gin.Context) {
+	var req types.CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	organizationID, ok := scopedOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	label := &models.Label{
+		OrganizationID: organizationID,
+		Name:           strings.TrimSpace(req.Name),
+		Color:          strings.ToLower(req.Color),
+		Description:    req.Description,
+	}
+	if label.Color == "" {
+		label.Color = models.DefaultLabelColor
+	}
+	if user, ok := currentUser(c); ok {
+		label.CreatedBy = &user.ID
+	}
+
+	var existing models.Label
+	if err := h.db.WithContext(c).Where("name = ?", label.Name).First(&existing).Error; err == nil {
+		utils.Conflict(c, "Label with this name already exists")
+		return
+	}
+
+	if err := h.db.WithContext(c).Create(label).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create label", err)
+		return
+	}
+
+	utils.Created(c, "Label created successfully", label)
+}
+
+// UpdateLabel renames, recolors or describes a label
+func (h *LabelHandler) UpdateLabel(c *gin.Context) {
+	label, ok := h.findLabel(c)
+	if !ok {
+		return
+	}
+
+	var req types.UpdateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		var existing models.Label
+		if err := h.db.WithContext(c).Where("name = ? AND id != ?", name, label.ID).First(&existing).Error; err == nil {
+			utils.Conflict(c, "Label with this name already exists")
+			return
+		}
+		label.Name = name
+	}
+	if req.Color != nil {
+		label.Color = strings.ToLower(*req.Color)
+	}
+	if req.Description != nil {
+		label.Description = *req.Description
+	}
+
+	if err := h.db.WithContext(c).Save(label).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update label", err)
+		return
+	}
+
+	utils.Success(c, "Label updated successfully", label)
+}
+
+// DeleteLabel removes a label from every message and deletes it (admin only)
+func (h *LabelHandler) DeleteLabel(c *gin.Context) {
+	label, ok := h.findLabel(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM message_labels WHERE label_id = ?", label.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(label).Error
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to delete label", err)
+		return
+	}
+
+	utils.Success(c, "Label deleted successfully", nil)
+}
+
+// LabelMessages adds labels to many messages at once. Existing links are kept.
+func (h *LabelHandler) LabelMessages(c *gin.Context) {
+	messageIDs, labelIDs, ok := h.bindBulkLabel(c)
+	if !ok {
+		return
+	}
+
+	result := h.db.WithContext(c).Exec(`
+		INSERT INTO message_labels (message_id, label_id)
+		SELECT messages.id, labels.id
+		FROM (?) AS messages CROSS JOIN labels
+		WHERE labels.id IN ?
+		ON CONFLICT DO NOTHING`, h.messageIDs(c).Where("id IN ?", messageIDs), labelIDs)
+	if result.Error != nil {
+		utils.InternalServerError(c, "Failed to label messages", result.Error)
+		return
+	}
+
+	utils.Success(c, "Messages labeled successfully", gin.H{"added": result.RowsAffected})
+}
+
+// UnlabelMessages removes labels from many messages at once
+func (h *LabelHandler) UnlabelMessages(c *gin.Context) {
+	messageIDs, labelIDs, ok := h.bindBulkLabel(c)
+	if !ok {
+		return
+	}
+
+	result := h.db.WithContext(c).Exec("DELETE FROM message_labels WHERE message_id IN (?) AND label_id IN ?",
+		h.messageIDs(c).Unscoped().Where("id IN ?", messageIDs), labelIDs)
+	if result.Error != nil {
+		utils.InternalServerError(c, "Failed to unlabel messages", result.Error)
+		return
+	}
+
+	utils.Success(c, "Messages unlabeled successfully", gin.H{"removed": result.RowsAffected})
+}
+
+// messageIDs selects the IDs of the organization's messages, for use as a subquery.
+// Labels are shared across organizations but their links to messages are not.
+func (h *LabelHandler) messageIDs(c *gin.Context) *gorm.DB {
+	return h.db.WithContext(c).Model(&models.Message{}).Select("id")
+}
+
+// bindBulkLabel parses a bulk label request and checks that every label exists
+func (h *LabelHandler) bindBulkLabel(c *gin.Context) ([]uuid.UUID, []uuid.UUID, bool) {
+	var req types.BulkLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return nil, nil, false
+	}
+
+	messageIDs, err := parseUUIDs(req.MessageIDs)
+	if err != nil {
+		utils.BadRequest(c, "Invalid message ID format", nil)
+		return nil, nil, false
+	}
+	labelIDs, err := parseUUIDs(req.LabelIDs)
+	if err != nil {
+		utils.BadRequest(c, "Invalid label ID format", nil)
+		return nil, nil, false
+	}
+
+	var found int64
+	if err := h.db.WithContext(c).Model(&models.Label{}).Where("id IN ?", labelIDs).Count(&found).Error; err != nil {
+		utils.InternalServerError(c, "Failed to load labels", err)
+		return nil, nil, false
+	}
+	if int(found) != len(uniqueUUIDs(labelIDs)) {
+		utils.NotFound(c, "Label not found")
+		return nil, nil, false
+	}
+
+	return messageIDs, labelIDs, true
+}
+
+// findLabel loads the label named by the :id route parameter
+func (h *LabelHandler) findLabel(c *gin.Context) (*models.Label, bool) {
+	labelID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid label ID format", nil)
+		return nil, false
+	}
+
+	var label models.Label
+	if err := h.db.WithContext(c).First(&label, "id = ?", labelID).Error; err != nil {
+		utils.NotFound(c, "Label not found")
+		return nil, false
+	}
+	return &label, true
+}
+
+// labelFilter reads the labels named in the label query parameter (repeatable
+// or comma separated, IDs or names) into a message filter. label_match=all
+// requires every label, the default matches any of them.
+func labelFilter(c *gin.Context, filter *repository.MessageFilter) {
+	for _, value := range c.QueryArray("label") {
+		for _, ref := range strings.Split(value, ",") {
+			if ref = strings.TrimSpace(ref); ref != "" {
+				filter.Labels = append(filter.Labels, ref)
+			}
+		}
+	}
+	filter.AllLabels = c.Query("label_match") == "all"
+}
+
+// parseUUIDs parses a list of UUID strings
+func parseUUIDs(values []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// uniqueUUIDs drops repeated IDs
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
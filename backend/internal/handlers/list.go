@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/repository"
+	"message-backend/internal/utils"
+)
+
+// listPage runs the request's list query against base and fills dest with one
+// page of rows. It writes the error response itself and reports false on failure.
+func listPage(c *gin.Context, spec *listquery.Spec, base *gorm.DB, dest interface{}) (listquery.Page, bool) {
+	query, ok := parseListQuery(c, spec)
+	if !ok {
+		return listquery.Page{}, false
+	}
+
+	paged, err := query.Apply(base.Session(&gorm.Session{}))
+	if err != nil {
+		utils.BadRequest(c, "Invalid list query", err)
+		return listquery.Page{}, false
+	}
+	if err := paged.Find(dest).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch records", nil)
+		return listquery.Page{}, false
+	}
+
+	page, err := query.Finish(dest)
+	if err == nil {
+		err = query.Count(base.Session(&gorm.Session{}), &page)
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch records", nil)
+		return listquery.Page{}, false
+	}
+	return page, true
+}
+
+// parseListQuery parses the request's list query for a repository, writing
+// the error response and reporting false when it is invalid
+func parseListQuery(c *gin.Context, spec *listquery.Spec) (*listquery.Query, bool) {
+	query, err := listquery.Parse(spec, c.Request.URL.Query())
+	if err != nil {
+		utils.BadRequest(c, "Invalid list query", err)
+		return nil, false
+	}
+	return query, true
+}
+
+// listFailed writes the error response of a repository list that failed
+func listFailed(c *gin.Context, err error) {
+	var queryErr *listquery.Error
+	var labelErr *repository.UnknownLabelError
+	switch {
+	case errors.As(err, &labelErr):
+		utils.BadRequest(c, "Unknown label: "+labelErr.Ref, nil)
+	case errors.As(err, &queryErr):
+		utils.BadRequest(c, "Invalid list query", err)
+	default:
+		utils.InternalServerError(c, "Failed to fetch records", nil)
+	}
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"strconv"
+	"context"
+	"errors"
 	"strings"
 	"time"
 
@@ -9,19 +10,47 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
-	"message-backend/internal/database"
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/clock"
+	"message-backend/internal/listquery"
+	"message-backend/internal/metrics"
 	"message-backend/internal/models"
+	"message-backend/internal/realtime"
+	"message-backend/internal/repository"
+	"message-backend/internal/rules"
+	"message-backend/internal/signals"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/timeline"
 	"message-backend/internal/types"
 	"message-backend/internal/utils"
+	"message-backend/internal/webhooks"
 )
 
 type MessageHandler struct {
-	db *gorm.DB
+	db             *gorm.DB
+	customers      repository.CustomerRepository
+	messages       repository.MessageRepository
+	clock          clock.Clock
+	rules          *rules.Engine
+	orgs           *tenancy.Directory
+	metrics        *metrics.Metrics
+	signals        *signals.Detector
+	signalsEnabled bool // Deployment default; organizations may override it
 }
 
-func NewMessageHandler() *MessageHandler {
+func NewMessageHandler(a *app.App, customers repository.CustomerRepository, messages repository.MessageRepository) *MessageHandler {
+	cfg := a.Config
 	return &MessageHandler{
-		db: database.GetDB(),
+		db:             a.DB,
+		customers:      customers,
+		messages:       messages,
+		clock:          a.Clock,
+		rules:          a.Rules,
+		orgs:           a.Orgs,
+		metrics:        a.Metrics,
+		signals:        signals.NewDetector(cfg),
+		signalsEnabled: cfg.SignalsEnabled,
 	}
 }
 
@@ -41,53 +70,147 @@ func (h *MessageHandler) CreateMessage(c *gin.Context) {
 	}
 
 	// Verify customer exists and is active
-	var customer models.Customer
-	if err := h.db.Where("id = ? AND is_active = true", customerUUID).First(&customer).Error; err != nil {
+	customer := h.scopeToCustomer(c, customerUUID)
+	if customer == nil || !customer.IsActive {
 		utils.NotFound(c, "Customer not found or inactive")
 		return
 	}
 
 	// Create the message
 	message := &models.Message{
-		CustomerID: customer.ID,
-		Content:    req.Content,
-		Timestamp:  req.Timestamp,
-		Starred:    false,
-	}
-
-	if err := h.db.Create(message).Error; err != nil {
+		OrganizationID: customer.OrganizationID,
+		CustomerID:     customer.ID,
+		ClientID:       optionalString(req.ClientID),
+		Sender:         req.Sender,
+		Content:        req.Content,
+		Timestamp:      req.Timestamp,
+		Starred:        false,
+	}
+
+	// Star, prioritize, label and alert according to the active rules
+	matches := evaluateRules(c, h.rules, message, customer)
+
+	// Insert the message and bump the customer's counters in one transaction
+	created, err := h.messages.Create(c, message, func(tx *gorm.DB) error {
+		return h.afterInsert(tx, message, customer, matches)
+	})
+	if err != nil {
 		utils.InternalServerError(c, "Failed to store message", nil)
 		return
 	}
 
-	// Update customer's message count and last active
-	customer.MessageCount++
-	customer.LastActive = time.Now()
-	h.db.Save(&customer)
+	// A retried upload returns the message that was stored the first time
+	if !created {
+		existing, err := h.messages.FindDuplicate(c, message)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to load stored message", nil)
+			return
+		}
+		utils.Success(c, "Message already stored", existing)
+		return
+	}
 
+	h.metrics.MessagesIngested.Inc(message.OrganizationID.String())
 	utils.Created(c, "Message stored successfully", message)
 }
 
+// afterInsert runs everything that follows storing a new message, inside its transaction:
+// rule labels and alerts, fraud signals, the webhook and the realtime notification
+func (h *MessageHandler) afterInsert(tx *gorm.DB, message *models.Message, customer *models.Customer, matches []rules.Match) error {
+	// Everything derived from the message belongs to the customer's organization
+	tx = tx.WithContext(tenancy.WithOrganization(tx.Statement.Context, message.OrganizationID))
+
+	if err := rules.Attach(tx, message, matches); err != nil {
+		return err
+	}
+	detector, err := h.detectorFor(tx.Statement.Context, message.OrganizationID)
+	if err != nil {
+		return err
+	}
+	if detector != nil {
+		if _, err := detector.Process(tx, message, customer); err != nil {
+			return err
+		}
+	}
+	if err := timeline.RecordMessage(tx, message); err != nil {
+		return err
+	}
+	if err := webhooks.Enqueue(tx, models.EventMessageCreated, message); err != nil {
+		return err
+	}
+	return realtime.NotifyMessageCreated(tx, message)
+}
+
+// detectorFor returns the fraud signal detector for an organization's messages,
+// or nil when signals are off for it
+func (h *MessageHandler) detectorFor(ctx context.Context, organizationID uuid.UUID) (*signals.Detector, error) {
+	org, err := h.orgs.Get(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := h.signalsEnabled
+	if org != nil && org.Settings.SignalsEnabled != nil {
+		enabled = *org.Settings.SignalsEnabled
+	}
+	if !enabled {
+		return nil, nil
+	}
+	if org == nil {
+		return h.signals, nil
+	}
+	return h.signals.WithSettings(org.Settings), nil
+}
+
+// scopeToCustomer scopes a public request to the organization of the customer
+// it acts for. It returns nil when the customer doesn't exist.
+func (h *MessageHandler) scopeToCustomer(c *gin.Context, customerID uuid.UUID) *models.Customer {
+	customer, err := h.customers.Get(tenancy.System(c), customerID)
+	if err != nil {
+		return nil
+	}
+	tenancy.Set(c, customer.OrganizationID)
+	return customer
+}
+
+// messageListSpec is the list query language of message collections
+var messageListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"timestamp":   {Column: "timestamp", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"created_at":  {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"sender":      {Column: "sender", Type: listquery.String, Ops: listquery.Text},
+		"content":     {Column: "content", Type: listquery.String, Ops: []string{listquery.OpContains}},
+		"priority":    {Column: "priority", Type: listquery.String, Ops: listquery.Equality},
+		"starred":     {Column: "starred", Type: listquery.Bool, Ops: listquery.Flag},
+		"legal_hold":  {Column: "legal_hold", Type: listquery.Bool, Ops: listquery.Flag},
+		"customer_id": {Column: "customer_id", Type: listquery.UUID, Ops: listquery.Equality},
+	},
+	DefaultSort:  "-timestamp",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// recentMessageListSpec is messageListSpec with the dashboard's small pages
+var recentMessageListSpec = &listquery.Spec{
+	Fields:       messageListSpec.Fields,
+	DefaultSort:  "-timestamp",
+	DefaultLimit: 5,
+	MaxLimit:     20,
+}
+
 // GetRecentMessages returns recent messages for dashboard/notifications
 func (h *MessageHandler) GetRecentMessages(c *gin.Context) {
-	// Get limit from query param, default to 5
-	limitStr := c.DefaultQuery("limit", "5")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 5
-	}
-	if limit > 20 { // Max limit
-		limit = 20
+	query, ok := parseListQuery(c, recentMessageListSpec)
+	if !ok {
+		return
 	}
+	var filter repository.MessageFilter
+	labelFilter(c, &filter)
 
 	// First get the recent messages
-	var messages []models.Message
-	result := h.db.Order("timestamp DESC").
-		Limit(limit).
-		Find(&messages)
-
-	if result.Error != nil {
-		utils.InternalServerError(c, "Failed to fetch recent messages", result.Error)
+	messages, page, err := h.messages.List(c, query, filter)
+	if err != nil {
+		listFailed(c, err)
 		return
 	}
 
@@ -98,10 +221,7 @@ func (h *MessageHandler) GetRecentMessages(c *gin.Context) {
 	}
 
 	// Query customers by IDs
-	var customers []models.Customer
-	if len(customerIDs) > 0 {
-		h.db.Where("id IN ?", customerIDs).Find(&customers)
-	}
+	customers, _ := h.customers.GetMany(c, customerIDs)
 
 	// Create a map of customer ID to customer for quick lookup
 	customerMap := make(map[uuid.UUID]models.Customer)
@@ -148,11 +268,14 @@ func (h *MessageHandler) GetRecentMessages(c *gin.Context) {
 			Date:    date,
 			Status:  "unread", // For now, all messages are unread
 		}
+		for _, label := range msg.Labels {
+			recentMessage.Labels = append(recentMessage.Labels, label.Name)
+		}
 
 		recentMessages = append(recentMessages, recentMessage)
 	}
 
-	utils.Success(c, "Recent messages retrieved successfully", gin.H{"messages": recentMessages})
+	utils.Success(c, "Recent messages retrieved successfully", gin.H{"messages": recentMessages, "pagination": page})
 }
 
 // GetMessages retrieves messages for a customer (with pagination and filters)
@@ -171,61 +294,29 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
-	var customer models.Customer
-	if err := h.db.Where("id = ? AND is_active = true", customerUUID).First(&customer).Error; err != nil {
+	customer := h.scopeToCustomer(c, customerUUID)
+	if customer == nil || !customer.IsActive {
 		utils.NotFound(c, "Customer not found")
 		return
 	}
 
-	// Parse query parameters
-	limit := 50 // default limit
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 200 {
-			limit = parsedLimit
-		}
-	}
-
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
-	}
-
-	starredOnly := false
-	if starredStr := c.Query("starred"); starredStr != "" {
-		starredOnly, _ = strconv.ParseBool(starredStr)
-	}
-
-	// Build query
-	query := h.db.Where("customer_id = ?", customer.ID)
-	if starredOnly {
-		query = query.Where("starred = ?", true)
-	}
-
-	// Get messages with pagination
-	var messages []models.Message
-	if err := query.Order("timestamp DESC").Limit(limit).Offset(offset).Find(&messages).Error; err != nil {
-		utils.InternalServerError(c, "Failed to retrieve messages", nil)
+	query, ok := parseListQuery(c, messageListSpec)
+	if !ok {
 		return
 	}
+	filter := repository.MessageFilter{CustomerID: &customer.ID}
+	labelFilter(c, &filter)
 
-	// Get total count for pagination info
-	var totalCount int64
-	countQuery := h.db.Model(&models.Message{}).Where("customer_id = ?", customer.ID)
-	if starredOnly {
-		countQuery = countQuery.Where("starred = ?", true)
+	// Get a page of messages
+	messages, page, err := h.messages.List(c, query, filter)
+	if err != nil {
+		listFailed(c, err)
+		return
 	}
-	countQuery.Count(&totalCount)
 
 	response := gin.H{
-		"messages": messages,
-		"pagination": gin.H{
-			"total":    totalCount,
-			"limit":    limit,
-			"offset":   offset,
-			"has_more": offset+len(messages) < int(totalCount),
-		},
+		"messages":   messages,
+		"pagination": page,
 	}
 
 	utils.Success(c, "Messages retrieved successfully", response)
@@ -248,17 +339,20 @@ func (h *MessageHandler) GetMessage(c *gin.Context) {
 		return
 	}
 
-	// Convert string ID to uint
-	var msgID uint
-	if parsedID, err := strconv.ParseUint(messageID, 10, 32); err != nil {
+	// Parse message UUID
+	msgID, err := uuid.Parse(messageID)
+	if err != nil {
 		utils.BadRequest(c, "Invalid message ID format", nil)
 		return
-	} else {
-		msgID = uint(parsedID)
 	}
 
-	var message models.Message
-	if err := h.db.Where("id = ? AND customer_id = ?", msgID, customerUUID).First(&message).Error; err != nil {
+	if h.scopeToCustomer(c, customerUUID) == nil {
+		utils.NotFound(c, "Message not found")
+		return
+	}
+
+	message, err := h.messages.Get(c, customerUUID, msgID)
+	if err != nil {
 		utils.NotFound(c, "Message not found")
 		return
 	}
@@ -284,34 +378,34 @@ func (h *MessageHandler) UpdateMessage(c *gin.Context) {
 	}
 
 	var req types.UpdateMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil || req.Starred == nil {
 		utils.BadRequest(c, "Invalid request data", nil)
 		return
 	}
 
-	// Convert string ID to uint
-	var msgID uint
-	if parsedID, err := strconv.ParseUint(messageID, 10, 32); err != nil {
+	// Parse message UUID
+	msgID, err := uuid.Parse(messageID)
+	if err != nil {
 		utils.BadRequest(c, "Invalid message ID format", nil)
 		return
-	} else {
-		msgID = uint(parsedID)
 	}
 
-	// Update the message (only starred field for now)
-	result := h.db.Model(&models.Message{}).
-		Where("id = ? AND customer_id = ?", msgID, customerUUID).
-		Update("starred", req.Starred)
-
-	if result.Error != nil {
-		utils.InternalServerError(c, "Failed to update message", nil)
+	if h.scopeToCustomer(c, customerUUID) == nil {
+		utils.NotFound(c, "Message not found")
 		return
 	}
 
-	if result.RowsAffected == 0 {
+	// Update the message (only starred field for now)
+	err = h.messages.SetStarred(c, customerUUID, msgID, *req.Starred)
+	if errors.Is(err, repository.ErrNotFound) {
 		utils.NotFound(c, "Message not found")
 		return
 	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to update message", nil)
+		return
+	}
+	audit.Record(c, "message.update", "message", msgID, nil, gin.H{"customer_id": customerUUID, "starred": req.Starred})
 
 	utils.Success(c, "Message updated successfully", nil)
 }
@@ -333,30 +427,32 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 		return
 	}
 
-	// Convert string ID to uint
-	var msgID uint
-	if parsedID, err := strconv.ParseUint(messageID, 10, 32); err != nil {
+	// Parse message UUID
+	msgID, err := uuid.Parse(messageID)
+	if err != nil {
 		utils.BadRequest(c, "Invalid message ID format", nil)
 		return
-	} else {
-		msgID = uint(parsedID)
 	}
 
-	// Delete the message
-	result := h.db.Where("id = ? AND customer_id = ?", msgID, customerUUID).
-		Delete(&models.Message{})
-
-	if result.Error != nil {
-		utils.InternalServerError(c, "Failed to delete message", nil)
+	if h.scopeToCustomer(c, customerUUID) == nil {
+		utils.NotFound(c, "Message not found")
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		utils.NotFound(c, "Message not found")
+	// Move the message to trash (messages under legal hold are kept) and
+	// decrement the customer's counter in the same transaction
+	err = h.messages.Trash(c, customerUUID, msgID)
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.NotFound(c, "Message not found or under legal hold")
 		return
 	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to delete message", nil)
+		return
+	}
+	audit.Record(c, "message.delete", "message", msgID, gin.H{"customer_id": customerUUID}, nil)
 
-	utils.Success(c, "Message deleted successfully", nil)
+	utils.Success(c, "Message moved to trash", nil)
 }
 
 // GetMessageStats returns message statistics for a customer
@@ -374,27 +470,18 @@ func (h *MessageHandler) GetMessageStats(c *gin.Context) {
 		return
 	}
 
-	var customer models.Customer
-	if err := h.db.Where("id = ? AND is_active = true", customerUUID).First(&customer).Error; err != nil {
+	customer := h.scopeToCustomer(c, customerUUID)
+	if customer == nil || !customer.IsActive {
 		utils.NotFound(c, "Customer not found")
 		return
 	}
 
-	var stats struct {
-		TotalMessages int64 `json:"total_messages"`
-		StarredCount  int64 `json:"starred_count"`
-		RecentCount   int64 `json:"recent_count"` // Last 24 hours
+	// Recent messages are those of the last 24 hours
+	stats, err := h.messages.Stats(c, customer.ID, h.clock.Now().Add(-24*time.Hour))
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch message statistics", nil)
+		return
 	}
 
-	// Total messages
-	h.db.Model(&models.Message{}).Where("customer_id = ?", customer.ID).Count(&stats.TotalMessages)
-
-	// Starred messages
-	h.db.Model(&models.Message{}).Where("customer_id = ? AND starred = ?", customer.ID, true).Count(&stats.StarredCount)
-
-	// Recent messages (last 24 hours)
-	recentTime := time.Now().Add(-24 * time.Hour)
-	h.db.Model(&models.Message{}).Where("customer_id = ? AND timestamp >= ?", customer.ID, recentTime).Count(&stats.RecentCount)
-
 	utils.Success(c, "Message statistics retrieved", stats)
-}
\ No newline at end of file
+}
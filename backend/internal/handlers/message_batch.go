@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/models"
+	"message-backend/internal/repository"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+// maxBatchMessages caps how many messages a single batch upload may carry
+const maxBatchMessages = 500
+
+// batchIdempotencyPrefix namespaces Idempotency-Key values used on the batch endpoint
+const batchIdempotencyPrefix = "messages.batch"
+
+// Batch item statuses
+const (
+	BatchStatusCreated   = "created"
+	BatchStatusDuplicate = "duplicate"
+	BatchStatusFailed    = "failed"
+)
+
+// errIdempotencyKeyInUse is returned when a concurrent request stored the same key first
+var errIdempotencyKeyInUse = errors.New("idempotency key already in use")
+
+// CreateMessagesBatch stores several messages from Android app in a single transaction
+func (h *MessageHandler) CreateMessagesBatch(c *gin.Context) {
+	var req types.BatchCreateMessageRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	if len(req.Messages) > maxBatchMessages {
+		utils.BadRequest(c, fmt.Sprintf("A batch can contain at most %d messages", maxBatchMessages), nil)
+		return
+	}
+
+	// Items may belong to customers of different organizations. Each message is
+	// stamped with its customer's, and afterInsert narrows the scope per message.
+	tenancy.SetAll(c)
+
+	idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	idempotencyScope := batchIdempotencyScope(req.Messages)
+	var requestHash string
+	if idempotencyKey != "" {
+		rawBody, _ := c.Get(gin.BodyBytesKey)
+		body, _ := rawBody.([]byte)
+		requestHash = models.HashContent(string(body))
+
+		// Replay the stored response if this key was already processed
+		var stored models.IdempotencyKey
+		if err := h.db.WithContext(c).Where("scope = ? AND key = ?", idempotencyScope, idempotencyKey).First(&stored).Error; err == nil {
+			if stored.IsExpired() {
+				h.db.WithContext(c).Delete(&stored)
+			} else if stored.RequestHash != requestHash {
+				utils.ErrorResponse(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request", nil)
+				return
+			} else {
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(stored.ResponseStatus, "application/json; charset=utf-8", []byte(stored.ResponseBody))
+				return
+			}
+		}
+	}
+
+	var response types.BatchCreateMessageResponse
+	var responseBody []byte
+	var createdPerOrganization map[uuid.UUID]int
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		response, createdPerOrganization, err = h.storeMessageBatch(tx, req.Messages)
+		if err != nil {
+			return err
+		}
+
+		responseBody, err = json.Marshal(utils.Response{
+			Status:  "success",
+			Message: "Batch processed successfully",
+			Data:    response,
+		})
+		if err != nil {
+			return err
+		}
+
+		if idempotencyKey == "" {
+			return nil
+		}
+
+		// Store the key in the same transaction so a retry either sees the full result or nothing
+		record := &models.IdempotencyKey{
+			Key:            idempotencyKey,
+			Scope:          idempotencyScope,
+			RequestHash:    requestHash,
+			ResponseStatus: http.StatusOK,
+			ResponseBody:   string(responseBody),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errIdempotencyKeyInUse
+		}
+		return nil
+	})
+
+	if errors.Is(err, errIdempotencyKeyInUse) {
+		utils.Conflict(c, "A request with this Idempotency-Key is already being processed")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to store messages", nil)
+		return
+	}
+
+	for organizationID, count := range createdPerOrganization {
+		h.metrics.MessagesIngested.Add(float64(count), organizationID.String())
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", responseBody)
+}
+
+// batchIdempotencyScope scopes a batch's Idempotency-Key to the customers it
+// uploads for, so devices that happen to pick the same key neither collide nor
+// replay each other's responses. Knowing a customer's ID is what lets a device
+// act for it.
+func batchIdempotencyScope(items []types.BatchMessageItem) string {
+	seen := make(map[string]bool, len(items))
+	var customerIDs []string
+	for _, item := range items {
+		id := strings.ToLower(strings.TrimSpace(item.CustomerID))
+		if !seen[id] {
+			seen[id] = true
+			customerIDs = append(customerIDs, id)
+		}
+	}
+	sort.Strings(customerIDs)
+	return batchIdempotencyPrefix + ":" + models.HashContent(strings.Join(customerIDs, ","))
+}
+
+// storeMessageBatch inserts every valid item of a batch and bumps the message
+// counters of the affected customers, all inside the given transaction. It
+// also returns how many messages each organization gained.
+func (h *MessageHandler) storeMessageBatch(tx *gorm.DB, items []types.BatchMessageItem) (types.BatchCreateMessageResponse, map[uuid.UUID]int, error) {
+	response := types.BatchCreateMessageResponse{
+		Results: make([]types.BatchMessageResult, 0, len(items)),
+	}
+
+	now := h.clock.Now()
+	activeCustomers := make(map[uuid.UUID]*models.Customer)
+	createdPerCustomer := make(map[uuid.UUID]int)
+	createdPerOrganization := make(map[uuid.UUID]int)
+
+	for i, item := range items {
+		result := types.BatchMessageResult{Index: i, ClientID: item.ClientID}
+
+		message, reason, err := batchItemToMessage(tx, item, activeCustomers, now)
+		if err != nil {
+			return response, nil, err
+		}
+		if reason != "" {
+			result.Status = BatchStatusFailed
+			result.Error = reason
+			response.Failed++
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		customer := activeCustomers[message.CustomerID]
+		matches := evaluateRules(tx.Statement.Context, h.rules, message, customer)
+
+		created, err := repository.InsertMessage(tx, message)
+		if err != nil {
+			return response, nil, err
+		}
+
+		if created {
+			result.Status = BatchStatusCreated
+			result.MessageID = message.ID.String()
+			createdPerCustomer[message.CustomerID]++
+			createdPerOrganization[message.OrganizationID]++
+			response.Created++
+			if err := h.afterInsert(tx, message, customer, matches); err != nil {
+				return response, nil, err
+			}
+		} else {
+			existing, err := repository.FindDuplicateMessage(tx, message)
+			if err != nil {
+				return response, nil, err
+			}
+			result.Status = BatchStatusDuplicate
+			result.MessageID = existing.ID.String()
+			response.Duplicate++
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	// Update customers' message count and last active
+	for customerID, count := range createdPerCustomer {
+		if err := models.AddCustomerMessages(tx, customerID, count, now); err != nil {
+			return response, nil, err
+		}
+	}
+
+	return response, createdPerOrganization, nil
+}
+
+// batchItemToMessage validates a batch item and builds the message to insert,
+// received now unless the item says when. A non-empty reason means the item is
+// rejected without failing the whole batch.
+func batchItemToMessage(tx *gorm.DB, item types.BatchMessageItem, activeCustomers map[uuid.UUID]*models.Customer, now time.Time) (*models.Message, string, error) {
+	if strings.TrimSpace(item.Content) == "" {
+		return nil, "content is required", nil
+	}
+	// Retries are recognized by client ID, or by content and timestamp; a
+	// timestamp assigned here would differ on every retry
+	if item.ClientID == "" && item.Timestamp.IsZero() {
+		return nil, "timestamp or client_id is required", nil
+	}
+	if len(item.ClientID) > 100 {
+		return nil, "client_id must be at most 100 characters", nil
+	}
+	if len(item.Sender) > 50 {
+		return nil, "sender must be at most 50 characters", nil
+	}
+
+	customerID, err := uuid.Parse(item.CustomerID)
+	if err != nil {
+		return nil, "invalid customer ID format", nil
+	}
+
+	// A nil entry caches a missing or inactive customer
+	customer, checked := activeCustomers[customerID]
+	if !checked {
+		var found []models.Customer
+		if err := tx.Where("id = ? AND is_active = true", customerID).Limit(1).Find(&found).Error; err != nil {
+			return nil, "", err
+		}
+		if len(found) > 0 {
+			customer = &found[0]
+		}
+		activeCustomers[customerID] = customer
+	}
+	if customer == nil {
+		return nil, "customer not found or inactive", nil
+	}
+
+	timestamp := item.Timestamp
+	if timestamp.IsZero() {
+		timestamp = now
+	}
+	return &models.Message{
+		OrganizationID: customer.OrganizationID,
+		CustomerID:     customerID,
+		ClientID:       optionalString(item.ClientID),
+		Sender:         item.Sender,
+		Content:        item.Content,
+		Timestamp:      timestamp,
+	}, "", nil
+}
+
+// optionalString converts an empty string to nil
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+// searchHeadlineOptions controls how ts_headline builds highlighted snippets
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" ... \""
+
+// messageSearchSpec pages search results by relevance or time
+var messageSearchSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"rank":      {Column: "results.rank", Type: listquery.Number, Sortable: true},
+		"timestamp": {Column: "results.timestamp", Type: listquery.Time, Sortable: true},
+	},
+	DefaultSort:  "-rank",
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	IDColumn:     "results.id",
+}
+
+// SearchMessages runs a ranked full-text search over message content (admin only)
+//
+// Query syntax: plain words are ANDed, "quoted phrases" must appear in order,
+// a trailing * matches a prefix (e.g. debit*), a leading - excludes a word and
+// OR between two terms matches either of them.
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	tsQuery := buildTSQuery(c.Query("q"))
+	if tsQuery == "" {
+		utils.BadRequest(c, "Search query is required", nil)
+		return
+	}
+
+	query := h.db.WithContext(c).Table("messages AS m").
+		Joins("CROSS JOIN to_tsquery('english', ?) AS q", tsQuery).
+		Joins("LEFT JOIN customers AS c ON c.id = m.customer_id").
+		Where("m.search_vector @@ q AND m.deleted_at IS NULL").
+		Scopes(tenancy.Filter("m.organization_id"))
+
+	if customerIDStr := c.Query("customer_id"); customerIDStr != "" {
+		customerID, err := uuid.Parse(customerIDStr)
+		if err != nil {
+			utils.BadRequest(c, "Invalid customer ID format", nil)
+			return
+		}
+		query = query.Where("m.customer_id = ?", customerID)
+	}
+
+	if sender := strings.TrimSpace(c.Query("sender")); sender != "" {
+		query = query.Where("m.sender ILIKE ?", "%"+sender+"%")
+	}
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := parseSearchDate(fromStr, false)
+		if err != nil {
+			utils.BadRequest(c, "Invalid from date. Use YYYY-MM-DD or RFC3339", nil)
+			return
+		}
+		query = query.Where("m.timestamp >= ?", from)
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := parseSearchDate(toStr, true)
+		if err != nil {
+			utils.BadRequest(c, "Invalid to date. Use YYYY-MM-DD or RFC3339", nil)
+			return
+		}
+		query = query.Where("m.timestamp < ?", to)
+	}
+
+	if starredStr := c.Query("starred"); starredStr != "" {
+		starred, err := strconv.ParseBool(starredStr)
+		if err != nil {
+			utils.BadRequest(c, "Invalid starred value", nil)
+			return
+		}
+		query = query.Where("m.starred = ?", starred)
+	}
+
+	// Rank in a subquery so pages can continue after the last rank seen
+	ranked := h.db.WithContext(c).Table("(?) AS results", query.Select(
+		"m.id, m.customer_id, c.full_name, c.name, c.phone_number, m.sender, m.content, m.timestamp, m.starred, "+
+			"ts_rank_cd(m.search_vector, q) AS rank")).
+		Select("results.*, ts_headline('english', results.content, to_tsquery('english', ?), ?) AS snippet",
+			tsQuery, searchHeadlineOptions)
+
+	var rows []struct {
+		ID          uuid.UUID
+		CustomerID  uuid.UUID
+		FullName    string
+		Name        string
+		PhoneNumber string
+		Sender      string
+		Content     string
+		Snippet     string
+		Rank        float64
+		Timestamp   time.Time
+		Starred     bool
+	}
+	page, ok := listPage(c, messageSearchSpec, ranked, &rows)
+	if !ok {
+		return
+	}
+
+	results := make([]types.MessageSearchResult, 0, len(rows))
+	for _, row := range rows {
+		customerName := row.PhoneNumber
+		if row.FullName != "" {
+			customerName = row.FullName
+		} else if row.Name != "" {
+			customerName = row.Name
+		}
+
+		results = append(results, types.MessageSearchResult{
+			ID:           row.ID.String(),
+			CustomerID:   row.CustomerID.String(),
+			CustomerName: customerName,
+			Sender:       row.Sender,
+			Content:      row.Content,
+			Snippet:      row.Snippet,
+			Rank:         row.Rank,
+			Timestamp:    row.Timestamp,
+			Starred:      row.Starred,
+		})
+	}
+
+	utils.Success(c, "Search completed successfully", gin.H{
+		"results":    results,
+		"query":      c.Query("q"),
+		"pagination": page,
+	})
+}
+
+// buildTSQuery converts the user facing search syntax into a to_tsquery
+// expression. Every word is reduced to letters and digits so the result is
+// always valid tsquery syntax.
+func buildTSQuery(input string) string {
+	var terms []string
+	pendingOr := false
+
+	for _, token := range splitSearchTokens(input) {
+		if token == "OR" {
+			pendingOr = len(terms) > 0
+			continue
+		}
+
+		term := ""
+		switch {
+		case strings.HasPrefix(token, "\""):
+			term = phraseTerm(strings.Trim(token, "\""))
+		case strings.HasPrefix(token, "-"):
+			if word := phraseTerm(strings.TrimPrefix(token, "-")); word != "" {
+				term = "!(" + word + ")"
+			}
+		case strings.HasSuffix(token, "*"):
+			if word := phraseTerm(strings.TrimSuffix(token, "*")); word != "" {
+				term = word + ":*"
+			}
+		default:
+			term = phraseTerm(token)
+		}
+		if term == "" {
+			continue
+		}
+
+		if pendingOr {
+			terms[len(terms)-1] = "(" + terms[len(terms)-1] + " | " + term + ")"
+			pendingOr = false
+			continue
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// splitSearchTokens splits on whitespace while keeping "quoted phrases" together
+func splitSearchTokens(input string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			if inQuotes {
+				current.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				current.WriteRune(r)
+			}
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// phraseTerm joins the alphanumeric words of text with the tsquery followed-by operator
+func phraseTerm(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, " <-> ")
+}
+
+// parseSearchDate accepts YYYY-MM-DD or RFC3339. A bare date used as an upper
+// bound is moved to the end of that day.
+func parseSearchDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type OrganizationHandler struct {
+	db   *gorm.DB
+	orgs *tenancy.Directory
+}
+
+func NewOrganizationHandler(a *app.App) *OrganizationHandler {
+	return &OrganizationHandler{
+		db:   a.DB,
+		orgs: a.Orgs,
+	}
+}
+
+// organizationSlugPattern matches valid organization slugs
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// organizationListSpec is the list query language of the organizations collection
+var organizationListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"name":       {Column: "name", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"slug":       {Column: "slug", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"is_active":  {Column: "is_active", Type: listquery.Bool, Ops: listquery.Flag},
+		"created_at": {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+	},
+	DefaultSort:  "name",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetCurrentOrganization returns the organization the request is scoped to
+func (h *OrganizationHandler) GetCurrentOrganization(c *gin.Context) {
+	org, ok := h.currentOrganization(c)
+	if !ok {
+		return
+	}
+	utils.Success(c, "Organization retrieved successfully", org)
+}
+
+// UpdateCurrentSettings changes the settings of the organization the request is scoped to
+func (h *OrganizationHandler) UpdateCurrentSettings(c *gin.Context) {
+	org, ok := h.currentOrganization(c)
+	if !ok {
+		return
+	}
+
+	var req types.OrganizationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	before := *org
+	if !applyOrganizationSettings(c, &org.Settings, &req) {
+		return
+	}
+	h.save(c, "organization.settings_update", &before, org)
+}
+
+// GetOrganizations lists every organization
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	var orgs []models.Organization
+	page, ok := listPage(c, organizationListSpec, h.db.WithContext(c).Model(&models.Organization{}), &orgs)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Organizations retrieved successfully", gin.H{
+		"organizations": orgs,
+		"pagination":    page,
+	})
+}
+
+// GetOrganization returns one organization
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+	utils.Success(c, "Organization retrieved successfully", org)
+}
+
+// CreateOrganization adds an organization. Its first staff can then be created
+// by a cross-tenant user acting in it through the X-Organization-ID header.
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req types.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	org := &models.Organization{
+		Name:     strings.TrimSpace(req.Name),
+		Slug:     strings.ToLower(strings.TrimSpace(req.Slug)),
+		IsActive: true,
+	}
+	if !organizationSlugPattern.MatchString(org.Slug) || org.Slug == tenancy.AllOrganizations {
+		utils.BadRequest(c, "Organization slugs may only contain lowercase letters, digits and dashes, and can't be \"all\"", nil)
+		return
+	}
+	if _, err := uuid.Parse(org.Slug); err == nil {
+		utils.BadRequest(c, "Organization slugs can't be UUIDs", nil)
+		return
+	}
+	if req.Settings != nil && !applyOrganizationSettings(c, &org.Settings, req.Settings) {
+		return
+	}
+
+	var existing models.Organization
+	if err := h.db.WithContext(c).Where("slug = ?", org.Slug).First(&existing).Error; err == nil {
+		utils.Conflict(c, "Organization slug already exists")
+		return
+	}
+
+	if err := h.db.WithContext(c).Create(org).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create organization", err)
+		return
+	}
+	h.orgs.Invalidate()
+	audit.Record(c, "organization.create", "organization", org.ID, nil, org)
+
+	utils.Created(c, "Organization created successfully", org)
+}
+
+// UpdateOrganization renames, deactivates or reconfigures an organization. Staff
+// of a deactivated organization can no longer sign in or use their tokens.
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	org, ok := h.findOrganization(c)
+	if !ok {
+		return
+	}
+
+	var req types.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	before := *org
+	if req.Name != nil {
+		org.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.IsActive != nil {
+		if !*req.IsActive && h.isOwnOrganization(c, org) {
+			utils.Forbidden(c, "Cannot deactivate your own organization")
+			return
+		}
+		org.IsActive = *req.IsActive
+	}
+	if req.Settings != nil && !applyOrganizationSettings(c, &org.Settings, req.Settings) {
+		return
+	}
+	h.save(c, "organization.update", &before, org)
+}
+
+// save stores an organization change, refreshes the directory and writes the response
+func (h *OrganizationHandler) save(c *gin.Context, action string, before, org *models.Organization) {
+	org.UpdatedAt = time.Now()
+	if err := h.db.WithContext(c).Save(org).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update organization", err)
+		return
+	}
+	h.orgs.Invalidate()
+	audit.Record(c, action, "organization", org.ID, before, org)
+
+	utils.Success(c, "Organization updated successfully", org)
+}
+
+// currentOrganization loads the organization the request is scoped to
+func (h *OrganizationHandler) currentOrganization(c *gin.Context) (*models.Organization, bool) {
+	organizationID, ok := scopedOrganizationID(c)
+	if !ok {
+		return nil, false
+	}
+
+	var org models.Organization
+	if err := h.db.WithContext(c).First(&org, "id = ?", organizationID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch organization", err)
+		return nil, false
+	}
+	return &org, true
+}
+
+func (h *OrganizationHandler) findOrganization(c *gin.Context) (*models.Organization, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid organization ID format", nil)
+		return nil, false
+	}
+
+	var org models.Organization
+	err = h.db.WithContext(c).First(&org, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, "Organization not found")
+		return nil, false
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch organization", err)
+		return nil, false
+	}
+	return &org, true
+}
+
+// isOwnOrganization reports whether the current user belongs to org
+func (h *OrganizationHandler) isOwnOrganization(c *gin.Context, org *models.Organization) bool {
+	user, ok := currentUser(c)
+	return ok && user.OrganizationID == org.ID
+}
+
+// applyOrganizationSettings copies the provided settings onto settings, writing
+// the error response and reporting false when one is invalid
+func applyOrganizationSettings(c *gin.Context, settings *models.OrganizationSettings, req *types.OrganizationSettingsRequest) bool {
+	if req.Timezone != nil && *req.Timezone != "" {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			utils.BadRequest(c, "Unknown timezone: "+*req.Timezone, nil)
+			return false
+		}
+	}
+
+	if req.SignalsEnabled != nil {
+		settings.SignalsEnabled = req.SignalsEnabled
+	}
+	if req.HomeCurrency != nil {
+		settings.HomeCurrency = strings.ToUpper(*req.HomeCurrency)
+	}
+	if req.Timezone != nil {
+		settings.Timezone = *req.Timezone
+	}
+	if req.AllowSignup != nil {
+		settings.AllowSignup = *req.AllowSignup
+	}
+	return true
+}
+
+// scopedOrganizationID returns the one organization the request is scoped to.
+// The cross-tenant view has none, so callers must pick one with X-Organization-ID.
+func scopedOrganizationID(c *gin.Context) (uuid.UUID, bool) {
+	scope, ok := tenancy.FromContext(c)
+	if !ok || scope.All {
+		utils.BadRequest(c, "Pick an organization with the "+tenancy.Header+" header", nil)
+		return uuid.Nil, false
+	}
+	return scope.OrganizationID, true
+}
+
+// requestOrganization resolves the organization a public request acts in: the
+// one named by the X-Organization-ID header, or the default organization. It
+// writes the error response itself and reports false on failure.
+func requestOrganization(c *gin.Context, orgs *tenancy.Directory) (*models.Organization, bool) {
+	ref := strings.TrimSpace(c.GetHeader(tenancy.Header))
+	if ref == "" {
+		ref = models.DefaultOrganizationSlug
+	}
+
+	org, err := orgs.Lookup(c, ref)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load organization", nil)
+		return nil, false
+	}
+	if org == nil || !org.IsActive {
+		utils.NotFound(c, "Organization not found")
+		return nil, false
+	}
+	return org, true
+}
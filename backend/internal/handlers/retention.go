@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/retention"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/timeline"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type RetentionHandler struct {
+	db     *gorm.DB
+	purger *retention.Purger
+}
+
+func NewRetentionHandler(a *app.App) *RetentionHandler {
+	cfg := a.Config
+	db := a.DB
+	return &RetentionHandler{
+		db:     db,
+		purger: retention.NewPurger(db, cfg.RetentionBatchSize),
+	}
+}
+
+// retentionPolicyListSpec is the list query language of the retention policies collection
+var retentionPolicyListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"retain_days": {Column: "retain_days", Type: listquery.Number, Sortable: true, Ops: listquery.Comparable},
+		"name":        {Column: "name", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"created_at":  {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"is_active":   {Column: "is_active", Type: listquery.Bool, Ops: listquery.Flag},
+	},
+	DefaultSort:  "retain_days",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetRetentionPolicies lists all retention policies (admin only)
+func (h *RetentionHandler) GetRetentionPolicies(c *gin.Context) {
+	var policies []models.RetentionPolicy
+	page, ok := listPage(c, retentionPolicyListSpec, h.db.WithContext(c).Model(&models.RetentionPolicy{}), &policies)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Retention policies retrieved successfully", gin.H{
+		"policies":   policies,
+		"pagination": page,
+	})
+}
+
+// CreateRetentionPolicy adds a retention policy (admin only)
+func (h *RetentionHandler) CreateRetentionPolicy(c *gin.Context) {
+	var req types.CreateRetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	organizationID, ok := scopedOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	policy := &models.RetentionPolicy{
+		OrganizationID: organizationID,
+		Name:           req.Name,
+		Description:    req.Description,
+		ContentPattern: req.ContentPattern,
+		SenderPattern:  req.SenderPattern,
+		RetainDays:     req.RetainDays,
+		IsActive:       true,
+	}
+	if req.IsActive != nil {
+		policy.IsActive = *req.IsActive
+	}
+	if user, ok := currentUser(c); ok {
+		policy.CreatedBy = &user.ID
+	}
+
+	if err := h.validatePatterns(policy); err != nil {
+		utils.BadRequest(c, "Invalid pattern", err)
+		return
+	}
+
+	var existing models.RetentionPolicy
+	if err := h.db.WithContext(c).Where("name = ?", policy.Name).First(&existing).Error; err == nil {
+		utils.Conflict(c, "Retention policy with this name already exists")
+		return
+	}
+
+	if err := h.db.WithContext(c).Create(policy).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create retention policy", err)
+		return
+	}
+
+	utils.Created(c, "Retention policy created successfully", policy)
+}
+
+// UpdateRetentionPolicy changes a retention policy (admin only)
+func (h *RetentionHandler) UpdateRetentionPolicy(c *gin.Context) {
+	policy, ok := h.findPolicy(c)
+	if !ok {
+		return
+	}
+
+	var req types.UpdateRetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+	before := *policy
+
+	if req.Name != nil {
+		policy.Name = *req.Name
+	}
+	if req.Description != nil {
+		policy.Description = *req.Description
+	}
+	if req.ContentPattern != nil {
+		policy.ContentPattern = *req.ContentPattern
+	}
+	if req.SenderPattern != nil {
+		policy.SenderPattern = *req.SenderPattern
+	}
+	if req.RetainDays != nil {
+		policy.RetainDays = *req.RetainDays
+	}
+	if req.IsActive != nil {
+		policy.IsActive = *req.IsActive
+	}
+
+	if err := h.validatePatterns(policy); err != nil {
+		utils.BadRequest(c, "Invalid pattern", err)
+		return
+	}
+
+	if err := h.db.WithContext(c).Save(policy).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update retention policy", err)
+		return
+	}
+	audit.Record(c, "retention_policy.update", "retention_policy", policy.ID, before, policy)
+
+	utils.Success(c, "Retention policy updated successfully", policy)
+}
+
+// DeleteRetentionPolicy removes a retention policy (admin only)
+func (h *RetentionHandler) DeleteRetentionPolicy(c *gin.Context) {
+	policy, ok := h.findPolicy(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.WithContext(c).Delete(policy).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete retention policy", err)
+		return
+	}
+
+	utils.Success(c, "Retention policy deleted successfully", nil)
+}
+
+// GetRetentionReport shows what the active policies would purge from the organization, without deleting anything (admin only)
+func (h *RetentionHandler) GetRetentionReport(c *gin.Context) {
+	report, err := h.purger.DryRun(tenancy.RequestContext(c))
+	if err != nil {
+		utils.InternalServerError(c, "Failed to build retention report", err)
+		return
+	}
+
+	utils.Success(c, "Retention report generated successfully", report)
+}
+
+// RunRetentionPurge applies the active policies to the organization's messages immediately (super admin only)
+func (h *RetentionHandler) RunRetentionPurge(c *gin.Context) {
+	report, err := h.purger.Purge(tenancy.RequestContext(c))
+	if err != nil {
+		utils.InternalServerError(c, "Retention purge failed", err)
+		return
+	}
+
+	utils.Success(c, "Retention purge completed", report)
+}
+
+// SetCustomerLegalHold places or releases a legal hold on a customer (admin only)
+func (h *RetentionHandler) SetCustomerLegalHold(c *gin.Context) {
+	user, _ := currentUser(c)
+	h.setLegalHold(c, &models.Customer{}, "Customer", func(tx *gorm.DB, id uuid.UUID, hold bool) error {
+		eventType, summary := models.CustomerEventLegalHoldReleased, "Legal hold released"
+		if hold {
+			eventType, summary = models.CustomerEventLegalHoldPlaced, "Placed on legal hold"
+		}
+		return timeline.Record(tx, id, eventType, timeline.Staff(user), summary, nil)
+	})
+}
+
+// SetMessageLegalHold places or releases a legal hold on a message (admin only)
+func (h *RetentionHandler) SetMessageLegalHold(c *gin.Context) {
+	h.setLegalHold(c, &models.Message{}, "Message", nil)
+}
+
+// setLegalHold updates the legal_hold flag of the model row named by :id,
+// calling onChange in the same transaction when it is set
+func (h *RetentionHandler) setLegalHold(c *gin.Context, model interface{}, name string, onChange func(tx *gorm.DB, id uuid.UUID, hold bool) error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid "+name+" ID format", nil)
+		return
+	}
+
+	var req types.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	err = h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(model).Where("id = ?", id).Update("legal_hold", *req.LegalHold)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if onChange != nil {
+			return onChange(tx, id, *req.LegalHold)
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, name+" not found")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to update legal hold", err)
+		return
+	}
+
+	audit.Record(c, strings.ToLower(name)+".legal_hold", strings.ToLower(name), id, nil, gin.H{"legal_hold": *req.LegalHold})
+
+	message := name + " legal hold released"
+	if *req.LegalHold {
+		message = name + " placed on legal hold"
+	}
+	utils.Success(c, message, gin.H{"id": id, "legal_hold": *req.LegalHold})
+}
+
+// validatePatterns lets Postgres compile the policy regexes so bad patterns fail here, not in the scheduler
+func (h *RetentionHandler) validatePatterns(policy *models.RetentionPolicy) error {
+	for _, pattern := range []string{policy.ContentPattern, policy.SenderPattern} {
+		if pattern == "" {
+			continue
+		}
+		var matched bool
+		if err := h.db.Raw("SELECT '' ~* ?", pattern).Scan(&matched).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPolicy loads the policy named by the :id route param, writing the error response if missing
+func (h *RetentionHandler) findPolicy(c *gin.Context) (*models.RetentionPolicy, bool) {
+	policyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid policy ID format", nil)
+		return nil, false
+	}
+
+	var policy models.RetentionPolicy
+	if err := h.db.WithContext(c).Where("id = ?", policyID).First(&policy).Error; err != nil {
+		utils.NotFound(c, "Retention policy not found")
+		return nil, false
+	}
+	return &policy, true
+}
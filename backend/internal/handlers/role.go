@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type RoleHandler struct {
+	db     *gorm.DB
+	policy *rbac.Policy
+}
+
+func NewRoleHandler(a *app.App) *RoleHandler {
+	return &RoleHandler{
+		db:     a.DB,
+		policy: a.Policy,
+	}
+}
+
+// roleNamePattern matches valid role names
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// roleListSpec is the list query language of the roles collection
+var roleListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"name":       {Column: "roles.name", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"created_at": {Column: "roles.created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"is_system":  {Column: "roles.is_system", Type: listquery.Bool, Ops: listquery.Flag},
+	},
+	DefaultSort:  "name",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+	IDColumn:     "roles.id",
+}
+
+// GetPermissions lists every grantable permission
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	utils.Success(c, "Permissions retrieved successfully", gin.H{"permissions": models.PermissionCatalog})
+}
+
+// GetRoles lists roles with the number of users holding each
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	var roles []types.RoleWithUsage
+	query := h.db.WithContext(c).Model(&models.Role{}).
+		Select("roles.*, COUNT(users.id) AS user_count").
+		Joins("LEFT JOIN users ON users.role = roles.name AND users.deleted_at IS NULL").
+		Group("roles.id")
+	page, ok := listPage(c, roleListSpec, query, &roles)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Roles retrieved successfully", gin.H{
+		"roles":      roles,
+		"pagination": page,
+	})
+}
+
+// GetRole returns one role
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	utils.Success(c, "Role retrieved successfully", role)
+}
+
+// CreateRole adds a role. Nobody can grant a permission they don't hold themselves.
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req types.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	permissions, ok := h.checkPermissions(c, user, req.Permissions)
+	if !ok {
+		return
+	}
+
+	role := &models.Role{
+		Name:        strings.ToLower(strings.TrimSpace(req.Name)),
+		Description: req.Description,
+		Permissions: permissions,
+		UpdatedBy:   &user.ID,
+	}
+
+	if !roleNamePattern.MatchString(role.Name) {
+		utils.BadRequest(c, "Role names may only contain lowercase letters, digits and underscores", nil)
+		return
+	}
+
+	var existing models.Role
+	if err := h.db.WithContext(c).Where("name = ?", role.Name).First(&existing).Error; err == nil {
+		utils.Conflict(c, "Role already exists")
+		return
+	}
+
+	if err := h.db.WithContext(c).Create(role).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create role", err)
+		return
+	}
+	h.policy.Invalidate()
+	audit.Record(c, "role.create", "role", role.ID, nil, role)
+
+	utils.Created(c, "Role created successfully", role)
+}
+
+// UpdateRole changes a role's description or permissions. super_admin is fixed.
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	if role.Name == models.RoleSuperAdmin {
+		utils.Forbidden(c, "The super_admin role always has every permission")
+		return
+	}
+	if role.Name == user.Role {
+		utils.Forbidden(c, "Cannot modify your own role")
+		return
+	}
+
+	var req types.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	before := *role
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		permissions, ok := h.checkPermissions(c, user, req.Permissions)
+		if !ok {
+			return
+		}
+		role.Permissions = permissions
+	}
+	role.UpdatedBy = &user.ID
+
+	if err := h.db.WithContext(c).Save(role).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update role", err)
+		return
+	}
+	h.policy.Invalidate()
+	audit.Record(c, "role.update", "role", role.ID, before, role)
+
+	utils.Success(c, "Role updated successfully", role)
+}
+
+// DeleteRole removes a custom role that no user holds
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	if role.IsSystem {
+		utils.Forbidden(c, "System roles cannot be deleted")
+		return
+	}
+
+	// Roles are shared, so count holders in every organization
+	var holders int64
+	if err := h.db.WithContext(tenancy.System(c)).Unscoped().Model(&models.User{}).Where("role = ?", role.Name).Count(&holders).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete role", err)
+		return
+	}
+	if holders > 0 {
+		utils.Conflict(c, "Role is still assigned to users, including trashed ones")
+		return
+	}
+
+	if err := h.db.WithContext(c).Delete(role).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete role", err)
+		return
+	}
+	h.policy.Invalidate()
+	audit.Record(c, "role.delete", "role", role.ID, role, nil)
+
+	utils.Success(c, "Role deleted successfully", gin.H{"id": role.ID})
+}
+
+// checkPermissions validates and de-duplicates a permission list and makes sure
+// the user holds every permission in it
+func (h *RoleHandler) checkPermissions(c *gin.Context, user *models.User, requested []string) ([]string, bool) {
+	seen := make(map[string]bool, len(requested))
+	permissions := make([]string, 0, len(requested))
+	for _, permission := range requested {
+		permission = strings.TrimSpace(permission)
+		if !models.IsValidPermission(permission) {
+			utils.BadRequest(c, "Unknown permission: "+permission, nil)
+			return nil, false
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+
+	if !h.policy.CanGrant(user.Role, permissions) {
+		utils.Forbidden(c, "Cannot grant permissions you don't have")
+		return nil, false
+	}
+	return permissions, true
+}
+
+func (h *RoleHandler) findRole(c *gin.Context) (*models.Role, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid role ID format", nil)
+		return nil, false
+	}
+
+	var role models.Role
+	err = h.db.WithContext(c).First(&role, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, "Role not found")
+		return nil, false
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch role", err)
+		return nil, false
+	}
+	return &role, true
+}
+
+// hasPermission reports whether the current user's role grants permission
+func hasPermission(c *gin.Context, policy *rbac.Policy, permission string) bool {
+	user, ok := currentUser(c)
+	return ok && policy.Allows(user.Role, permission)
+}
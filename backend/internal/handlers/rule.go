@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/app"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/rules"
+	"message-backend/internal/smsparse"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type RuleHandler struct {
+	db     *gorm.DB
+	engine *rules.Engine
+}
+
+func NewRuleHandler(a *app.App) *RuleHandler {
+	return &RuleHandler{
+		db:     a.DB,
+		engine: a.Rules,
+	}
+}
+
+// ruleListSpec is the list query language of the rules collection
+var ruleListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"priority":   {Column: "priority", Type: listquery.Number, Sortable: true, Ops: listquery.Comparable},
+		"name":       {Column: "name", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"updated_at": {Column: "updated_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"is_active":  {Column: "is_active", Type: listquery.Bool, Ops: listquery.Flag},
+	},
+	DefaultSort:  "priority",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// ruleVersionListSpec is the list query language of a rule's version history
+var ruleVersionListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"version":    {Column: "version", Type: listquery.Number, Sortable: true, Ops: listquery.Comparable},
+		"created_at": {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+	},
+	DefaultSort:  "-version",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetRules lists message rules, in evaluation order by default (admin only)
+func (h *RuleHandler) GetRules(c *gin.Context) {
+	var list []models.Rule
+	page, ok := listPage(c, ruleListSpec, h.db.WithContext(c).Model(&models.Rule{}), &list)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Rules retrieved successfully", gin.H{
+		"rules":      list,
+		"pagination": page,
+	})
+}
+
+// GetRule returns a single rule (admin only)
+func (h *RuleHandler) GetRule(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Rule retrieved successfully", rule)
+}
+
+// CreateRule adds a message rule, stored as version 1 (admin only)
+func (h *RuleHandler) CreateRule(c *gin.Context) {
+	var req types.CreateRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	organizationID, ok := scopedOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	rule := &models.Rule{
+		OrganizationID: organizationID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Priority:       100,
+		StopProcessing: req.StopProcessing,
+		IsActive:       true,
+		Version:        1,
+		Conditions:     req.Conditions,
+		Actions:        req.Actions,
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if user, ok := currentUser(c); ok {
+		rule.UpdatedBy = &user.ID
+	}
+
+	if err := rules.Validate(rule); err != nil {
+		utils.BadRequest(c, "Invalid rule", err)
+		return
+	}
+
+	var existing models.Rule
+	if err := h.db.WithContext(c).Where("name = ?", rule.Name).First(&existing).Error; err == nil {
+		utils.Conflict(c, "Rule with this name already exists")
+		return
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		return tx.Create(rule.Snapshot()).Error
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to create rule", err)
+		return
+	}
+	h.engine.Invalidate(rule.OrganizationID)
+
+	utils.Created(c, "Rule created successfully", rule)
+}
+
+// UpdateRule changes a rule and records the result as a new version (admin only)
+func (h *RuleHandler) UpdateRule(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	var req types.UpdateRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	if req.Name != nil {
+		var existing models.Rule
+		if err := h.db.WithContext(c).Where("name = ? AND id != ?", *req.Name, rule.ID).First(&existing).Error; err == nil {
+			utils.Conflict(c, "Rule with this name already exists")
+			return
+		}
+		rule.Name = *req.Name
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.StopProcessing != nil {
+		rule.StopProcessing = *req.StopProcessing
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if req.Conditions != nil {
+		rule.Conditions = *req.Conditions
+	}
+	if req.Actions != nil {
+		rule.Actions = req.Actions
+	}
+
+	if err := rules.Validate(rule); err != nil {
+		utils.BadRequest(c, "Invalid rule", err)
+		return
+	}
+
+	if !h.saveVersion(c, rule) {
+		return
+	}
+
+	utils.Success(c, "Rule updated successfully", rule)
+}
+
+// DeleteRule removes a rule. Its version history is kept. (admin only)
+func (h *RuleHandler) DeleteRule(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.WithContext(c).Delete(rule).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete rule", err)
+		return
+	}
+	h.engine.Invalidate(rule.OrganizationID)
+
+	utils.Success(c, "Rule deleted successfully", nil)
+}
+
+// GetRuleVersions lists every stored version of a rule, newest first (admin only)
+func (h *RuleHandler) GetRuleVersions(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	var versions []models.RuleVersion
+	query := h.db.WithContext(c).Model(&models.RuleVersion{}).Where("rule_id = ?", rule.ID)
+	page, ok := listPage(c, ruleVersionListSpec, query, &versions)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Rule versions retrieved successfully", gin.H{
+		"versions":   versions,
+		"pagination": page,
+	})
+}
+
+// RestoreRuleVersion rolls a rule back to an earlier version, stored as a new version (admin only)
+func (h *RuleHandler) RestoreRuleVersion(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	var version models.RuleVersion
+	if err := h.db.WithContext(c).Where("rule_id = ? AND version = ?", rule.ID, c.Param("version")).First(&version).Error; err != nil {
+		utils.NotFound(c, "Rule version not found")
+		return
+	}
+
+	rule.Name = version.Name
+	rule.Description = version.Description
+	rule.Priority = version.Priority
+	rule.StopProcessing = version.StopProcessing
+	rule.IsActive = version.IsActive
+	rule.Conditions = version.Conditions
+	rule.Actions = version.Actions
+
+	var existing models.Rule
+	if err := h.db.WithContext(c).Where("name = ? AND id != ?", rule.Name, rule.ID).First(&existing).Error; err == nil {
+		utils.Conflict(c, "Another rule now uses this version's name")
+		return
+	}
+
+	if !h.saveVersion(c, rule) {
+		return
+	}
+
+	utils.Success(c, "Rule version restored successfully", rule)
+}
+
+// TestRule evaluates a stored rule, an unsaved definition or all active rules
+// against a sample message without storing anything (admin only)
+func (h *RuleHandler) TestRule(c *gin.Context) {
+	var req types.TestRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	// Rules are tested against the organization the request acts in
+	organizationID, ok := scopedOrganizationID(c)
+	if !ok {
+		return
+	}
+
+	sample := rules.Sample{OrganizationID: organizationID, Sender: req.Sample.Sender, Content: req.Sample.Content}
+	if req.Sample.CustomerID != "" {
+		customerID, err := uuid.Parse(req.Sample.CustomerID)
+		if err != nil {
+			utils.BadRequest(c, "Invalid customer ID format", nil)
+			return
+		}
+		var customer models.Customer
+		if err := h.db.WithContext(c).First(&customer, "id = ?", customerID).Error; err != nil {
+			utils.NotFound(c, "Customer not found")
+			return
+		}
+		sample.Customer = &customer
+	}
+
+	var candidates []*rules.Compiled
+	switch {
+	case req.RuleID != "":
+		ruleID, err := uuid.Parse(req.RuleID)
+		if err != nil {
+			utils.BadRequest(c, "Invalid rule ID format", nil)
+			return
+		}
+		var rule models.Rule
+		if err := h.db.WithContext(c).First(&rule, "id = ?", ruleID).Error; err != nil {
+			utils.NotFound(c, "Rule not found")
+			return
+		}
+		compiled, err := rules.Compile(rule)
+		if err != nil {
+			utils.BadRequest(c, "Invalid rule", err)
+			return
+		}
+		candidates = append(candidates, compiled)
+	case req.Conditions != nil:
+		rule := models.Rule{OrganizationID: organizationID, Name: "draft", Conditions: *req.Conditions, Actions: req.Actions}
+		if err := rules.Validate(&rule); err != nil {
+			utils.BadRequest(c, "Invalid rule", err)
+			return
+		}
+		compiled, _ := rules.Compile(rule)
+		candidates = append(candidates, compiled)
+	default:
+		active, err := h.engine.Active(c, organizationID)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to load rules", err)
+			return
+		}
+		candidates = active
+	}
+
+	matches := rules.EvaluateAll(candidates, sample)
+
+	// Show what the matched actions would do to the message
+	preview := &models.Message{Sender: sample.Sender, Content: sample.Content}
+	rules.Apply(preview, matches)
+	if preview.Priority == "" {
+		preview.Priority = models.PriorityNormal
+	}
+
+	response := gin.H{
+		"matched":  len(matches) > 0,
+		"matches":  matches,
+		"starred":  preview.Starred,
+		"priority": preview.Priority,
+	}
+	if amount, ok := smsparse.ParseAmount(sample.Content); ok {
+		response["parsed_amount"] = amount
+	}
+
+	utils.Success(c, "Rules evaluated successfully", response)
+}
+
+// saveVersion bumps a rule's version and stores it with its snapshot
+func (h *RuleHandler) saveVersion(c *gin.Context, rule *models.Rule) bool {
+	rule.UpdatedBy = nil
+	if user, ok := currentUser(c); ok {
+		rule.UpdatedBy = &user.ID
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// Lock the rule so concurrent edits get distinct versions
+		var current models.Rule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("version").First(&current, "id = ?", rule.ID).Error; err != nil {
+			return err
+		}
+		rule.Version = current.Version + 1
+		if err := tx.Save(rule).Error; err != nil {
+			return err
+		}
+		return tx.Create(rule.Snapshot()).Error
+	})
+	if err != nil {
+		utils.InternalServerError(c, "Failed to save rule", err)
+		return false
+	}
+	h.engine.Invalidate(rule.OrganizationID)
+
+	return true
+}
+
+// findRule loads the rule named by the :id route parameter
+func (h *RuleHandler) findRule(c *gin.Context) (*models.Rule, bool) {
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid rule ID format", nil)
+		return nil, false
+	}
+
+	var rule models.Rule
+	if err := h.db.WithContext(c).First(&rule, "id = ?", ruleID).Error; err != nil {
+		utils.NotFound(c, "Rule not found")
+		return nil, false
+	}
+	return &rule, true
+}
+
+// evaluateRules runs the active rules of the message's organization for an
+// incoming message and applies the field changes. A rule loading failure never
+// blocks ingestion.
+func evaluateRules(ctx context.Context, engine *rules.Engine, message *models.Message, customer *models.Customer) []rules.Match {
+	if engine == nil {
+		return nil
+	}
+	matches, err := engine.Evaluate(ctx, rules.Sample{
+		OrganizationID: message.OrganizationID,
+		Sender:         message.Sender,
+		Content:        message.Content,
+		Customer:       customer,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to evaluate message rules", slog.Any("error", err))
+		return nil
+	}
+	rules.Apply(message, matches)
+	return matches
+}
@@ -1,16 +1,61 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"log/slog"
 
-	"message-backend/internal/config"
-	"message-backend/internal/database"
+	"message-backend/internal/accounts"
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
 	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
 	"message-backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// MinSeedKeyLength is the shortest SUPER_ADMIN_SEED_KEY the seed routes accept
+const MinSeedKeyLength = 32
+
+// SeedKeyUsable reports whether key is strong enough to expose the seed routes with
+func SeedKeyUsable(key string) bool {
+	return len(key) >= MinSeedKeyLength
+}
+
+// SeedHandler serves the seed routes that bootstrap and recover super admins
+type SeedHandler struct {
+	db     *gorm.DB
+	mailer utils.Mailer
+	key    string // SUPER_ADMIN_SEED_KEY
+}
+
+func NewSeedHandler(a *app.App) *SeedHandler {
+	return &SeedHandler{
+		db:     a.DB,
+		mailer: a.Mailer,
+		key:    a.Config.SuperAdminSeedKey,
+	}
+}
+
+// checkSeedKey compares a request's secret key with SUPER_ADMIN_SEED_KEY in
+// constant time. It writes the error response itself and reports false on mismatch.
+func (h *SeedHandler) checkSeedKey(c *gin.Context, key string) bool {
+	expected := h.key
+	// Hashing first keeps the comparison from leaking the key's length
+	given, want := sha256.Sum256([]byte(key)), sha256.Sum256([]byte(expected))
+	if !SeedKeyUsable(expected) || subtle.ConstantTimeCompare(given[:], want[:]) != 1 {
+		utils.Forbidden(c, "Invalid secret key")
+		return false
+	}
+	audit.SetActor(c, models.AuditActorSeedKey)
+	return true
+}
+
 // SeedSuperAdminRequest represents the seed request
 type SeedSuperAdminRequest struct {
 	SecretKey string `json:"secret_key" binding:"required"`
@@ -20,7 +65,7 @@ type SeedSuperAdminRequest struct {
 }
 
 // RecoverSuperAdmin sends existing super admin credentials via email
-func RecoverSuperAdmin(c *gin.Context) {
+func (h *SeedHandler) RecoverSuperAdmin(c *gin.Context) {
 	var req struct {
 		SecretKey string `json:"secret_key" binding:"required"`
 		Username  string `json:"username"`
@@ -39,13 +84,11 @@ func RecoverSuperAdmin(c *gin.Context) {
 	}
 
 	// Verify secret key
-	cfg := config.LoadConfig()
-	if req.SecretKey != cfg.SuperAdminSeedKey {
-		utils.Forbidden(c, "Invalid secret key")
+	if !h.checkSeedKey(c, req.SecretKey) {
 		return
 	}
 
-	db := database.GetDB()
+	db := h.db.WithContext(tenancy.System(c))
 
 	// Find the user by username OR email
 	var user models.User
@@ -61,14 +104,18 @@ func RecoverSuperAdmin(c *gin.Context) {
 		return
 	}
 
-	// Offload email sending to goroutine for faster response
+	audit.Record(c, "seed.recover_super_admin", "user", user.ID, nil, nil)
+
+	// Offload email sending to goroutine for faster response. The send stays
+	// part of the request's trace but isn't cancelled when the response goes out.
+	ctx := context.WithoutCancel(c.Request.Context())
 	go func(u models.User) {
 		// Send credentials to the user's email (in background)
-		if err := utils.SendSuperAdminCredentials(u.Username, u.Email, u.GetRawPassword()); err != nil {
+		if err := h.mailer.SendSuperAdminCredentials(ctx, u.Username, u.Email, u.GetRawPassword()); err != nil {
 			// Log the error but don't affect the HTTP response
-			fmt.Printf("⚠️ Failed to send recovery email to %s: %v\n", u.Email, err)
+			slog.ErrorContext(ctx, "Failed to send recovery email", slog.String("user_id", u.ID.String()), slog.Any("error", err))
 		} else {
-			fmt.Printf("✅ Recovery email sent to %s\n", u.Email)
+			slog.InfoContext(ctx, "Recovery email sent", slog.String("user_id", u.ID.String()))
 		}
 	}(user) // Pass user by value to avoid race conditions
 
@@ -85,8 +132,8 @@ func RecoverSuperAdmin(c *gin.Context) {
 	})
 }
 
-// SeedSuperAdmin creates a super admin user via hidden route
-func SeedSuperAdmin(c *gin.Context) {
+// SeedSuperAdmin creates the first super admin via the seed routes
+func (h *SeedHandler) SeedSuperAdmin(c *gin.Context) {
 	var req SeedSuperAdminRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -95,42 +142,26 @@ func SeedSuperAdmin(c *gin.Context) {
 	}
 
 	// Verify secret key
-	cfg := config.LoadConfig()
-	if req.SecretKey != cfg.SuperAdminSeedKey {
-		utils.Forbidden(c, "Invalid secret key")
+	if !h.checkSeedKey(c, req.SecretKey) {
 		return
 	}
 
-	db := database.GetDB()
+	db := h.db.WithContext(tenancy.System(c))
 
-	// Check if super admin already exists
-	var existingSuperAdmin models.User
-	result := db.Where("role = ?", models.RoleSuperAdmin).First(&existingSuperAdmin)
-	if result.Error == nil {
+	superAdmin, err := accounts.CreateSuperAdmin(db, req.Username, req.Email, req.Password)
+	if errors.Is(err, accounts.ErrSuperAdminExists) {
 		utils.Conflict(c, "Super admin already exists")
 		return
 	}
-
-	// Create super admin - no approval needed for seed super admins
-	superAdmin := &models.User{
-		Username:   req.Username,
-		Email:      req.Email,
-		Role:       models.RoleSuperAdmin,
-		RawPass:    req.Password,
-		IsActive:   true,
-		IsApproved: true, // Super admins are auto-approved
-		// ApprovedBy and ApprovedAt remain nil for seed super admins
-	}
-
-	if err := superAdmin.SetPassword(req.Password); err != nil {
-		utils.InternalServerError(c, fmt.Sprintf("Failed to set password: %v", err), err)
+	if errors.Is(err, accounts.ErrUserExists) {
+		utils.Conflict(c, "Username or email already exists")
 		return
 	}
-
-	if err := db.Create(superAdmin).Error; err != nil {
+	if err != nil {
 		utils.InternalServerError(c, fmt.Sprintf("Failed to create super admin: %v", err), err)
 		return
 	}
+	audit.Record(c, "seed.create_super_admin", "user", superAdmin.ID, nil, superAdmin)
 
 	utils.Created(c, "Super admin created successfully", gin.H{
 		"admin": gin.H{
@@ -139,15 +170,15 @@ func SeedSuperAdmin(c *gin.Context) {
 			"email":    superAdmin.Email,
 			"role":     superAdmin.Role,
 		},
-		"warning": "Remember to change the SUPER_ADMIN_SEED_KEY in production!",
+		"warning": "Disable the seed routes now that a super admin exists",
 	})
 }
 
 // ResetSuperAdmin allows resetting super admin (with secret key)
-func ResetSuperAdmin(c *gin.Context) {
+func (h *SeedHandler) ResetSuperAdmin(c *gin.Context) {
 	var req struct {
 		SecretKey string `json:"secret_key" binding:"required"`
-		UserID    uint   `json:"user_id" binding:"required"`
+		UserID    string `json:"user_id" binding:"required,uuid"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -155,25 +186,28 @@ func ResetSuperAdmin(c *gin.Context) {
 		return
 	}
 
-	cfg := config.LoadConfig()
-	if req.SecretKey != cfg.SuperAdminSeedKey {
-		utils.Forbidden(c, "Invalid secret key")
+	if !h.checkSeedKey(c, req.SecretKey) {
 		return
 	}
 
-	db := database.GetDB()
+	db := h.db.WithContext(tenancy.System(c))
 
-	var user models.User
-	if err := db.First(&user, req.UserID).Error; err != nil {
+	user, err := accounts.FindUser(db, req.UserID)
+	if errors.Is(err, accounts.ErrUserNotFound) {
 		utils.NotFound(c, "User not found")
 		return
 	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch user", err)
+		return
+	}
 
-	user.Role = models.RoleSuperAdmin
-	if err := db.Save(&user).Error; err != nil {
+	before := *user
+	if err := accounts.Promote(db, user, models.RoleSuperAdmin); err != nil {
 		utils.InternalServerError(c, "Failed to promote user to super admin", err)
 		return
 	}
+	audit.Record(c, "seed.reset_admin", "user", user.ID, before, user)
 
 	utils.Success(c, "User promoted to super admin successfully", gin.H{
 		"admin": gin.H{
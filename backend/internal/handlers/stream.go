@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+
+	"message-backend/internal/app"
+	"message-backend/internal/clock"
+	"message-backend/internal/config"
+	"message-backend/internal/realtime"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/utils"
+)
+
+// streamHeartbeatInterval keeps idle connections alive through proxies
+const streamHeartbeatInterval = 15 * time.Second
+
+// streamReplayLimit caps how many missed messages are replayed on reconnect
+const streamReplayLimit = 500
+
+type StreamHandler struct {
+	broker *realtime.Broker
+	clock  clock.Clock
+	cfg    *config.Config
+}
+
+func NewStreamHandler(a *app.App) *StreamHandler {
+	return &StreamHandler{
+		broker: a.Broker,
+		clock:  a.Clock,
+		cfg:    a.Config,
+	}
+}
+
+// wsFrame is the envelope of every WebSocket frame
+type wsFrame struct {
+	Type  string          `json:"type"` // message or heartbeat
+	Event *realtime.Event `json:"event,omitempty"`
+	Time  time.Time       `json:"time"`
+}
+
+// StreamMessages pushes new messages to the client as Server-Sent Events
+func (h *StreamHandler) StreamMessages(c *gin.Context) {
+	customerIDs, err := parseCustomerFilter(c)
+	if err != nil {
+		utils.BadRequest(c, "Invalid customer ID format", nil)
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	// Subscribe before replaying so nothing committed in between is lost
+	scope, _ := tenancy.FromContext(c)
+	sub := h.broker.Subscribe(scope, customerIDs)
+	defer h.broker.Unsubscribe(sub)
+
+	var backlog []realtime.Event
+	if lastEventID != "" {
+		backlog, err = h.broker.Replay(tenancy.RequestContext(c), lastEventID, customerIDs, streamReplayLimit)
+		if err != nil {
+			utils.BadRequest(c, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, event := range backlog {
+		c.Render(-1, sse.Event{Id: event.ID, Event: "message", Data: event})
+		lastEventID = event.ID
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(c.Writer, ": heartbeat %d\n\n", h.clock.Now().Unix())
+			c.Writer.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if lastEventID != "" && !realtime.After(event.ID, lastEventID) {
+				continue // Already sent during replay
+			}
+			c.Render(-1, sse.Event{Id: event.ID, Event: "message", Data: event})
+			c.Writer.Flush()
+			lastEventID = event.ID
+		}
+	}
+}
+
+// StreamMessagesWebSocket pushes new messages to the client over a WebSocket
+func (h *StreamHandler) StreamMessagesWebSocket(c *gin.Context) {
+	customerIDs, err := parseCustomerFilter(c)
+	if err != nil {
+		utils.BadRequest(c, "Invalid customer ID format", nil)
+		return
+	}
+
+	lastEventID := c.Query("last_event_id")
+	if lastEventID != "" {
+		if _, _, err := realtime.ParseEventID(lastEventID); err != nil {
+			utils.BadRequest(c, "Invalid last_event_id", err)
+			return
+		}
+	}
+
+	scope, _ := tenancy.FromContext(c)
+	server := websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.serveWebSocket(ws, scope, customerIDs, lastEventID)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkOrigin refuses WebSocket handshakes from browser origins outside
+// CORS_ALLOWED_ORIGINS, so other sites can't open a stream in a user's browser.
+// Clients that send no Origin, such as the Android app, aren't browsers.
+func (h *StreamHandler) checkOrigin(handshake *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(handshake, req)
+	if err != nil {
+		return err
+	}
+	handshake.Origin = origin
+	if origin != nil && !h.cfg.OriginAllowed(origin.String()) {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+	return nil
+}
+
+// serveWebSocket writes events to an upgraded connection until either side closes it
+func (h *StreamHandler) serveWebSocket(ws *websocket.Conn, scope tenancy.Scope, customerIDs []uuid.UUID, lastEventID string) {
+	defer ws.Close()
+	ctx := tenancy.WithScope(ws.Request().Context(), scope)
+
+	// The connection outlives the server's read and write timeouts
+	ws.SetDeadline(time.Time{})
+
+	sub := h.broker.Subscribe(scope, customerIDs)
+	defer h.broker.Unsubscribe(sub)
+
+	// Detect the client going away; incoming frames are otherwise ignored
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	if lastEventID != "" {
+		backlog, err := h.broker.Replay(ctx, lastEventID, customerIDs, streamReplayLimit)
+		if err != nil {
+			return
+		}
+		for i := range backlog {
+			if websocket.JSON.Send(ws, wsFrame{Type: "message", Event: &backlog[i], Time: h.clock.Now()}) != nil {
+				return
+			}
+			lastEventID = backlog[i].ID
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if websocket.JSON.Send(ws, wsFrame{Type: "heartbeat", Time: h.clock.Now()}) != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if lastEventID != "" && !realtime.After(event.ID, lastEventID) {
+				continue
+			}
+			if websocket.JSON.Send(ws, wsFrame{Type: "message", Event: &event, Time: h.clock.Now()}) != nil {
+				return
+			}
+			lastEventID = event.ID
+		}
+	}
+}
+
+// parseCustomerFilter reads customer_id query params, repeated or comma separated
+func parseCustomerFilter(c *gin.Context) ([]uuid.UUID, error) {
+	var customerIDs []uuid.UUID
+	for _, value := range c.QueryArray("customer_id") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				return nil, err
+			}
+			customerIDs = append(customerIDs, id)
+		}
+	}
+	return customerIDs, nil
+}
@@ -2,14 +2,15 @@ package handlers
 
 import (
 	"net/http"
-	"message-backend/internal/database"
+	"message-backend/internal/app"
 	"message-backend/internal/models"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-type TestHandler struct{}
+type TestHandler struct{ db *gorm.DB }
 
-func NewTestHandler() *TestHandler { return &TestHandler{} }
+func NewTestHandler(a *app.App) *TestHandler { return &TestHandler{db: a.DB} }
 
 // TestEndpoint is a sandbox for testing any code
 func (h *TestHandler) TestEndpoint(c *gin.Context) {
@@ -17,7 +18,7 @@ func (h *TestHandler) TestEndpoint(c *gin.Context) {
 	// Paste Your test code here for testing
 	// =========================
 	var updatedUser models.User
-	err := database.GetDB().Where("is_approved = ? AND role = ?", false, models.RoleUser).Find(&updatedUser).Error
+	err := h.db.Where("is_approved = ? AND role = ?", false, models.RoleUser).Find(&updatedUser).Error
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
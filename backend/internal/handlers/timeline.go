@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/timeline"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+)
+
+type TimelineHandler struct {
+	db *gorm.DB
+}
+
+func NewTimelineHandler(a *app.App) *TimelineHandler {
+	return &TimelineHandler{
+		db: a.DB,
+	}
+}
+
+// timelineListSpec is the list query language of customer timelines,
+// e.g. ?type[in]=limit_changed,note_added or ?type[ne]=message_received
+var timelineListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"occurred_at": {Column: "occurred_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"type":        {Column: "type", Type: listquery.String, Ops: listquery.Equality},
+		"actor_type":  {Column: "actor_type", Type: listquery.String, Ops: listquery.Equality},
+		"actor_id":    {Column: "actor_id", Type: listquery.UUID, Ops: listquery.Equality},
+	},
+	DefaultSort:  "-occurred_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetCustomerTimeline returns a page of a customer's activity, newest first (admin only)
+func (h *TimelineHandler) GetCustomerTimeline(c *gin.Context) {
+	customer, ok := h.findCustomer(c)
+	if !ok {
+		return
+	}
+
+	var events []models.CustomerEvent
+	base := h.db.WithContext(c).Model(&models.CustomerEvent{}).Where("customer_id = ?", customer.ID)
+	page, ok := listPage(c, timelineListSpec, base, &events)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Timeline retrieved successfully", gin.H{
+		"events":     events,
+		"pagination": page,
+	})
+}
+
+// AddCustomerNote adds a staff note to a customer's timeline (admin only)
+func (h *TimelineHandler) AddCustomerNote(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		utils.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	customer, ok := h.findCustomer(c)
+	if !ok {
+		return
+	}
+
+	var req types.CreateCustomerNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		utils.BadRequest(c, "Note body is required", nil)
+		return
+	}
+
+	event, err := timeline.AddNote(h.db.WithContext(c), customer.ID, user, body)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to add note", err)
+		return
+	}
+
+	utils.Created(c, "Note added successfully", event)
+}
+
+// findCustomer loads the customer named by :id, including trashed customers so
+// their history stays readable
+func (h *TimelineHandler) findCustomer(c *gin.Context) (*models.Customer, bool) {
+	customerID, ok := parseCustomerID(c)
+	if !ok {
+		return nil, false
+	}
+
+	var customer models.Customer
+	err := h.db.WithContext(c).Unscoped().First(&customer, "id = ?", customerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, "Customer not found")
+		return nil, false
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch customer", err)
+		return nil, false
+	}
+	return &customer, true
+}
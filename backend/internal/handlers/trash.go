@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/timeline"
+	"message-backend/internal/utils"
+)
+
+// errLegalHold blocks purging rows that are under legal hold
+var errLegalHold = errors.New("under legal hold")
+
+type TrashHandler struct {
+	db     *gorm.DB
+	policy *rbac.Policy
+}
+
+func NewTrashHandler(a *app.App) *TrashHandler {
+	return &TrashHandler{
+		db:     a.DB,
+		policy: a.Policy,
+	}
+}
+
+// GetTrashedMessages lists soft-deleted messages (admin only)
+func (h *TrashHandler) GetTrashedMessages(c *gin.Context) {
+	var messages []models.Message
+	h.listTrash(c, h.db.WithContext(c).Model(&models.Message{}), &messages, "messages")
+}
+
+// GetTrashedCustomers lists soft-deleted customers (admin only)
+func (h *TrashHandler) GetTrashedCustomers(c *gin.Context) {
+	var customers []models.Customer
+	page, ok := h.trashPage(c, h.db.WithContext(c).Model(&models.Customer{}), &customers)
+	if !ok {
+		return
+	}
+	maskCustomers(c, h.policy, customers)
+
+	utils.Success(c, "Trash retrieved successfully", gin.H{
+		"customers":  customers,
+		"pagination": page,
+	})
+}
+
+// GetTrashedUsers lists soft-deleted users (admin only)
+func (h *TrashHandler) GetTrashedUsers(c *gin.Context) {
+	query := h.db.WithContext(c).Model(&models.User{})
+	if user, ok := currentUser(c); !ok || !user.IsSuperAdmin() {
+		query = query.Where("role != ?", models.RoleSuperAdmin)
+	}
+
+	var users []models.User
+	h.listTrash(c, query, &users, "users")
+}
+
+// RestoreMessage takes a message out of trash (admin only)
+func (h *TrashHandler) RestoreMessage(c *gin.Context) {
+	id, ok := parseTrashID(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var message models.Message
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&message).Error; err != nil {
+			return err
+		}
+
+		// The owning customer has to be restored first
+		var customer models.Customer
+		if err := tx.Where("id = ?", message.CustomerID).First(&customer).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&message).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&customer).UpdateColumn("message_count", gorm.Expr("message_count + 1")).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, "Message not found in trash, or its customer is still in trash")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to restore message", err)
+		return
+	}
+
+	utils.Success(c, "Message restored successfully", gin.H{"id": id})
+}
+
+// RestoreCustomer takes a customer and the messages trashed with them out of trash (admin only)
+func (h *TrashHandler) RestoreCustomer(c *gin.Context) {
+	id, ok := parseTrashID(c)
+	if !ok {
+		return
+	}
+
+	user, _ := currentUser(c)
+
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		var customer models.Customer
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&customer).Error; err != nil {
+			return err
+		}
+		if err := restoreCustomer(tx, &customer); err != nil {
+			return err
+		}
+		return timeline.Record(tx, customer.ID, models.CustomerEventRestored, timeline.Staff(user), "Customer restored from trash", nil)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, "Customer not found in trash")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to restore customer", err)
+		return
+	}
+
+	utils.Success(c, "Customer restored successfully", gin.H{"id": id})
+}
+
+// RestoreUser takes a user out of trash (admin only)
+func (h *TrashHandler) RestoreUser(c *gin.Context) {
+	id, ok := parseTrashID(c)
+	if !ok {
+		return
+	}
+
+	query := h.db.WithContext(c).Unscoped().Model(&models.User{}).Where("id = ? AND deleted_at IS NOT NULL", id)
+	if user, ok := currentUser(c); !ok || !user.IsSuperAdmin() {
+		query = query.Where("role != ?", models.RoleSuperAdmin)
+	}
+
+	result := query.UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		utils.InternalServerError(c, "Failed to restore user", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFound(c, "User not found in trash")
+		return
+	}
+
+	utils.Success(c, "User restored successfully", gin.H{"id": id})
+}
+
+// PurgeMessage permanently deletes a trashed message (super admin only)
+func (h *TrashHandler) PurgeMessage(c *gin.Context) {
+	id, ok := parseTrashID(c)
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	h.purge(c, &message, id, "Message", func() bool { return message.LegalHold })
+}
+
+// PurgeCustomer permanently deletes a trashed customer and all their messages (super admin only)
+func (h *TrashHandler) PurgeCustomer(c *gin.Context) {
+	id, ok := parseTrashID(c)
+	if !ok {
+		return
+	}
+
+	var customer models.Customer
+	h.purge(c, &customer, id, "Customer", func() bool {
+		if customer.LegalHold {
+			return true
+		}
+		var held int64
+		h.db.WithContext(c).Unscoped().Model(&models.Message{}).Where("customer_id = ? AND legal_hold = ?", id, true).Count(&held)
+		return held > 0
+	})
+}
+
+// PurgeUser permanently deletes a trashed user (super admin only)
+func (h *TrashHandler) PurgeUser(c *gin.Context) {
+	id, ok := parseTrashID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	var scopes []func(*gorm.DB) *gorm.DB
+	if current, ok := currentUser(c); !ok || !current.IsSuperAdmin() {
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB { return db.Where("role != ?", models.RoleSuperAdmin) })
+	}
+	h.purge(c, &user, id, "User", func() bool { return false }, scopes...)
+}
+
+// purge hard-deletes a row that is already in trash. scopes narrow the rows
+// the caller may purge; anything else is reported as not in trash.
+func (h *TrashHandler) purge(c *gin.Context, model interface{}, id uuid.UUID, name string, onHold func() bool, scopes ...func(*gorm.DB) *gorm.DB) {
+	err := h.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Scopes(scopes...).Where("id = ? AND deleted_at IS NOT NULL", id).First(model).Error; err != nil {
+			return err
+		}
+		if onHold() {
+			return errLegalHold
+		}
+		return tx.Unscoped().Delete(model).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFound(c, name+" not found in trash")
+		return
+	}
+	if errors.Is(err, errLegalHold) {
+		utils.Conflict(c, name+" is under legal hold and cannot be purged")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(c, "Failed to purge "+name, err)
+		return
+	}
+
+	utils.Success(c, name+" permanently deleted", gin.H{"id": id})
+}
+
+// trashListSpec is the list query language of trash collections
+var trashListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"deleted_at": {Column: "deleted_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"created_at": {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+	},
+	DefaultSort:  "-deleted_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// listTrash writes a page of soft-deleted rows, most recently deleted first
+func (h *TrashHandler) listTrash(c *gin.Context, query *gorm.DB, dest interface{}, key string) {
+	page, ok := h.trashPage(c, query, dest)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Trash retrieved successfully", gin.H{
+		key:          dest,
+		"pagination": page,
+	})
+}
+
+// trashPage fills dest with one page of trashed rows
+func (h *TrashHandler) trashPage(c *gin.Context, query *gorm.DB, dest interface{}) (listquery.Page, bool) {
+	return listPage(c, trashListSpec, query.Unscoped().Where("deleted_at IS NOT NULL"), dest)
+}
+
+// restoreCustomer brings back a trashed customer with the messages trashed
+// along with them, which share their deleted_at, and recounts their messages
+func restoreCustomer(tx *gorm.DB, customer *models.Customer) error {
+	err := tx.Unscoped().Model(&models.Message{}).
+		Where("customer_id = ? AND deleted_at = ?", customer.ID, customer.DeletedAt.Time).
+		UpdateColumn("deleted_at", nil).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Model(customer).UpdateColumns(map[string]interface{}{
+		"deleted_at":    nil,
+		"message_count": gorm.Expr("(SELECT COUNT(*) FROM messages WHERE customer_id = ? AND deleted_at IS NULL)", customer.ID),
+	}).Error
+}
+
+// parseTrashID reads the :id route param, writing the error response if invalid
+func parseTrashID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid ID format", nil)
+		return uuid.Nil, false
+	}
+	return id, true
+}
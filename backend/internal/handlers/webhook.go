@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/types"
+	"message-backend/internal/utils"
+	"message-backend/internal/webhooks"
+)
+
+type WebhookHandler struct {
+	db *gorm.DB
+}
+
+func NewWebhookHandler(a *app.App) *WebhookHandler {
+	return &WebhookHandler{
+		db: a.DB,
+	}
+}
+
+// CreateWebhook registers a new webhook endpoint (admin only)
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req types.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+
+	events, ok := normalizeWebhookEvents(req.Events)
+	if !ok {
+		utils.BadRequest(c, "Unknown event. Valid events: "+strings.Join(models.WebhookEvents, ", "), nil)
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		utils.InternalServerError(c, "Failed to generate webhook secret", err)
+		return
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:         req.URL,
+		Description: req.Description,
+		Secret:      secret,
+		Events:      strings.Join(events, ","),
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		endpoint.IsActive = *req.IsActive
+	}
+	if user, ok := currentUser(c); ok {
+		endpoint.CreatedBy = &user.ID
+	}
+
+	if err := h.db.WithContext(c).Create(endpoint).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create webhook", err)
+		return
+	}
+
+	utils.Created(c, "Webhook created successfully", gin.H{
+		"webhook": endpoint,
+		"secret":  secret, // Only returned here and on rotation
+	})
+}
+
+// webhookListSpec is the list query language of the webhooks collection
+var webhookListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"created_at": {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"url":        {Column: "url", Type: listquery.String, Sortable: true, Ops: listquery.Text},
+		"is_active":  {Column: "is_active", Type: listquery.Bool, Ops: listquery.Flag},
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// webhookDeliveryListSpec is the list query language of an endpoint's delivery log
+var webhookDeliveryListSpec = &listquery.Spec{
+	Fields: map[string]listquery.Field{
+		"created_at":      {Column: "created_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"next_attempt_at": {Column: "next_attempt_at", Type: listquery.Time, Sortable: true, Ops: listquery.Comparable},
+		"status":          {Column: "status", Type: listquery.String, Ops: listquery.Equality},
+		"event":           {Column: "event", Type: listquery.String, Ops: listquery.Equality},
+		"attempts":        {Column: "attempts", Type: listquery.Number, Ops: listquery.Comparable},
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// GetWebhooks lists registered webhook endpoints (admin only)
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	var endpoints []models.WebhookEndpoint
+	page, ok := listPage(c, webhookListSpec, h.db.WithContext(c).Model(&models.WebhookEndpoint{}), &endpoints)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Webhooks retrieved successfully", gin.H{
+		"webhooks":   endpoints,
+		"events":     models.WebhookEvents,
+		"pagination": page,
+	})
+}
+
+// GetWebhook returns a single webhook endpoint (admin only)
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	endpoint, ok := h.findEndpoint(c)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Webhook retrieved successfully", endpoint)
+}
+
+// UpdateWebhook changes URL, events or active state of an endpoint (admin only)
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	endpoint, ok := h.findEndpoint(c)
+	if !ok {
+		return
+	}
+
+	var req types.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data", err)
+		return
+	}
+	before := *endpoint
+
+	if req.URL != "" {
+		endpoint.URL = req.URL
+	}
+	if req.Description != nil {
+		endpoint.Description = *req.Description
+	}
+	if req.Events != nil {
+		events, ok := normalizeWebhookEvents(req.Events)
+		if !ok || len(events) == 0 {
+			utils.BadRequest(c, "Unknown event. Valid events: "+strings.Join(models.WebhookEvents, ", "), nil)
+			return
+		}
+		endpoint.Events = strings.Join(events, ",")
+	}
+	if req.IsActive != nil {
+		endpoint.IsActive = *req.IsActive
+	}
+
+	if err := h.db.WithContext(c).Save(endpoint).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update webhook", err)
+		return
+	}
+	audit.Record(c, "webhook.update", "webhook", endpoint.ID, before, endpoint)
+
+	utils.Success(c, "Webhook updated successfully", endpoint)
+}
+
+// RotateWebhookSecret replaces the signing secret of an endpoint (admin only)
+func (h *WebhookHandler) RotateWebhookSecret(c *gin.Context) {
+	endpoint, ok := h.findEndpoint(c)
+	if !ok {
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		utils.InternalServerError(c, "Failed to generate webhook secret", err)
+		return
+	}
+
+	if err := h.db.WithContext(c).Model(endpoint).Update("secret", secret).Error; err != nil {
+		utils.InternalServerError(c, "Failed to rotate webhook secret", err)
+		return
+	}
+
+	utils.Success(c, "Webhook secret rotated successfully", gin.H{
+		"webhook": endpoint,
+		"secret":  secret,
+	})
+}
+
+// DeleteWebhook removes an endpoint and its delivery log (admin only)
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	endpoint, ok := h.findEndpoint(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.WithContext(c).Delete(endpoint).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete webhook", err)
+		return
+	}
+
+	utils.Success(c, "Webhook deleted successfully", nil)
+}
+
+// GetWebhookDeliveries returns the delivery log of an endpoint (admin only)
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	endpoint, ok := h.findEndpoint(c)
+	if !ok {
+		return
+	}
+
+	query := h.db.WithContext(c).Model(&models.WebhookDelivery{}).Where("endpoint_id = ?", endpoint.ID)
+
+	var deliveries []models.WebhookDelivery
+	page, ok := listPage(c, webhookDeliveryListSpec, query, &deliveries)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "Webhook deliveries retrieved successfully", gin.H{
+		"deliveries": deliveries,
+		"pagination": page,
+	})
+}
+
+// RedeliverWebhook queues a fresh copy of a past delivery (admin only)
+func (h *WebhookHandler) RedeliverWebhook(c *gin.Context) {
+	deliveryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid delivery ID format", nil)
+		return
+	}
+
+	// Deliveries belong to the organization of their endpoint
+	var original models.WebhookDelivery
+	endpoints := h.db.WithContext(c).Model(&models.WebhookEndpoint{}).Select("id")
+	if err := h.db.WithContext(c).Where("id = ? AND endpoint_id IN (?)", deliveryID, endpoints).First(&original).Error; err != nil {
+		utils.NotFound(c, "Delivery not found")
+		return
+	}
+
+	delivery := &models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryStatusPending,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  &original.ID,
+	}
+
+	if err := h.db.WithContext(c).Create(delivery).Error; err != nil {
+		utils.InternalServerError(c, "Failed to queue redelivery", err)
+		return
+	}
+
+	utils.Created(c, "Redelivery queued successfully", delivery)
+}
+
+// findEndpoint loads the endpoint named by the :id route param, writing the error response if missing
+func (h *WebhookHandler) findEndpoint(c *gin.Context) (*models.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid webhook ID format", nil)
+		return nil, false
+	}
+
+	var endpoint models.WebhookEndpoint
+	if err := h.db.WithContext(c).Where("id = ?", endpointID).First(&endpoint).Error; err != nil {
+		utils.NotFound(c, "Webhook not found")
+		return nil, false
+	}
+	return &endpoint, true
+}
+
+// normalizeWebhookEvents validates and de-duplicates event names
+func normalizeWebhookEvents(events []string) ([]string, bool) {
+	seen := make(map[string]bool, len(events))
+	normalized := make([]string, 0, len(events))
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !models.IsValidWebhookEvent(event) {
+			return nil, false
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, true
+}
+
+// currentUser returns the authenticated user set by the JWT middleware
+func currentUser(c *gin.Context) (*models.User, bool) {
+	value, exists := c.Get("user")
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*models.User)
+	return user, ok
+}
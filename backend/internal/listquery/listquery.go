@@ -0,0 +1,448 @@
+// Package listquery implements the list query language shared by collection
+// endpoints: keyset pagination with opaque cursors, validated sort fields and
+// filter operators.
+//
+//	?limit=50                    page size, capped per endpoint
+//	?cursor=<next_cursor>        continue after the last page
+//	?sort=-created_at            sort field, "-" for descending
+//	?status=open                 shorthand for status[eq]=open
+//	?created_at[gte]=2024-01-01  eq, ne, gt, gte, lt, lte, in (comma separated), contains
+//	?include_total=true          also count every matching row
+package listquery
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Field types decide how filter and cursor values are parsed
+type FieldType int
+
+const (
+	String FieldType = iota
+	Number
+	Time
+	Bool
+	UUID
+)
+
+// Filter operators
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpIn       = "in"
+	OpContains = "contains"
+)
+
+// Common operator sets
+var (
+	Equality   = []string{OpEq, OpNe, OpIn}
+	Comparable = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte}
+	Text       = []string{OpEq, OpNe, OpIn, OpContains}
+	Flag       = []string{OpEq}
+)
+
+// reserved query parameters are never read as filters
+var reserved = map[string]bool{"limit": true, "cursor": true, "sort": true, "include_total": true}
+
+// Field is a sortable and/or filterable attribute of a collection.
+// Sortable columns must be NOT NULL for keyset pagination to be correct.
+type Field struct {
+	Column   string
+	Type     FieldType
+	Sortable bool
+	Ops      []string // Allowed filter operators, none means not filterable
+}
+
+// Spec describes what a collection endpoint accepts
+type Spec struct {
+	Fields       map[string]Field
+	DefaultSort  string // e.g. "-created_at"
+	DefaultLimit int
+	MaxLimit     int
+	IDColumn     string // Tiebreaker for equal sort values, defaults to "id"
+}
+
+// Error is a client error in the list query
+type Error struct {
+	Param   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+// Page is the pagination envelope returned with every collection
+type Page struct {
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Total      *int64 `json:"total,omitempty"` // Only with include_total=true
+}
+
+type filter struct {
+	name  string
+	field Field
+	op    string
+	value interface{}
+	text  string // The raw value of contains filters
+}
+
+// cursor is the position after the last row of a page
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Query is a parsed, validated list request
+type Query struct {
+	spec      *Spec
+	sortKey   string
+	sortField Field
+	desc      bool
+	filters   []filter
+	after     *cursor
+	Limit     int
+	WithTotal bool
+}
+
+// Parse validates query parameters against a spec
+func Parse(spec *Spec, values url.Values) (*Query, error) {
+	q := &Query{spec: spec, Limit: spec.DefaultLimit}
+
+	if limitStr := values.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return nil, &Error{"limit", "must be a positive integer"}
+		}
+		q.Limit = limit
+	}
+	if q.Limit > spec.MaxLimit {
+		q.Limit = spec.MaxLimit
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = spec.DefaultSort
+	}
+	q.desc = strings.HasPrefix(sortParam, "-")
+	q.sortKey = strings.TrimPrefix(sortParam, "-")
+	field, ok := spec.Fields[q.sortKey]
+	if !ok || !field.Sortable {
+		return nil, &Error{"sort", fmt.Sprintf("cannot sort by %q, use one of %s", q.sortKey, strings.Join(spec.sortable(), ", "))}
+	}
+	q.sortField = field
+
+	for key, vals := range values {
+		if reserved[key] || len(vals) == 0 {
+			continue
+		}
+		name, op := key, OpEq
+		if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:i], key[i+1:len(key)-1]
+		} else if _, known := spec.Fields[name]; !known {
+			// Plain parameters the endpoint handles itself
+			continue
+		}
+
+		field, ok := spec.Fields[name]
+		if !ok {
+			return nil, &Error{key, "unknown filter field"}
+		}
+		if !contains(field.Ops, op) {
+			return nil, &Error{key, fmt.Sprintf("operator %q is not supported on %s", op, name)}
+		}
+
+		f := filter{name: name, field: field, op: op}
+		switch op {
+		case OpIn:
+			var list []interface{}
+			for _, raw := range strings.Split(vals[0], ",") {
+				value, err := parseValue(field.Type, strings.TrimSpace(raw))
+				if err != nil {
+					return nil, &Error{key, err.Error()}
+				}
+				list = append(list, value)
+			}
+			f.value = list
+		case OpContains:
+			f.value = "%" + escapeLike(vals[0]) + "%"
+			f.text = vals[0]
+		default:
+			value, err := parseValue(field.Type, vals[0])
+			if err != nil {
+				return nil, &Error{key, err.Error()}
+			}
+			f.value = value
+		}
+		q.filters = append(q.filters, f)
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		c, err := decodeCursor(raw)
+		if err != nil || c.Sort != sortParam {
+			return nil, &Error{"cursor", "invalid or does not match the sort order"}
+		}
+		q.after = c
+	}
+
+	q.WithTotal, _ = strconv.ParseBool(values.Get("include_total"))
+	return q, nil
+}
+
+// Filter applies only the filters, for counting
+func (q *Query) Filter(db *gorm.DB) *gorm.DB {
+	for _, f := range q.filters {
+		col := f.field.Column
+		switch f.op {
+		case OpEq:
+			db = db.Where(col+" = ?", f.value)
+		case OpNe:
+			db = db.Where(col+" <> ?", f.value)
+		case OpGt:
+			db = db.Where(col+" > ?", f.value)
+		case OpGte:
+			db = db.Where(col+" >= ?", f.value)
+		case OpLt:
+			db = db.Where(col+" < ?", f.value)
+		case OpLte:
+			db = db.Where(col+" <= ?", f.value)
+		case OpIn:
+			db = db.Where(col+" IN ?", f.value)
+		case OpContains:
+			db = db.Where(col+"::text ILIKE ?", f.value)
+		}
+	}
+	return db
+}
+
+// Apply adds filters, the cursor position, ordering and the limit. One extra
+// row is fetched to tell whether another page exists.
+func (q *Query) Apply(db *gorm.DB) (*gorm.DB, error) {
+	db = q.Filter(db)
+
+	idColumn := q.spec.idColumn()
+	direction := "ASC"
+	comparison := ">"
+	if q.desc {
+		direction = "DESC"
+		comparison = "<"
+	}
+
+	if q.after != nil {
+		value, err := parseValue(q.sortField.Type, q.after.Value)
+		if err != nil {
+			return nil, &Error{"cursor", "invalid"}
+		}
+		id, err := uuid.Parse(q.after.ID)
+		if err != nil {
+			return nil, &Error{"cursor", "invalid"}
+		}
+		db = db.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", q.sortField.Column, idColumn, comparison), value, id)
+	}
+
+	return db.Order(q.sortField.Column + " " + direction).
+		Order(idColumn + " " + direction).
+		Limit(q.Limit + 1), nil
+}
+
+// Finish trims the extra row from a fetched page and builds its envelope.
+// rows must be a pointer to a slice of structs with the sort field and an id,
+// matched by json tag or field name.
+func (q *Query) Finish(rows interface{}) (Page, error) {
+	page := Page{Limit: q.Limit, Sort: q.sortString()}
+
+	slice := reflect.ValueOf(rows).Elem()
+	if slice.Len() <= q.Limit {
+		return page, nil
+	}
+	slice.Set(slice.Slice(0, q.Limit))
+	page.HasMore = true
+
+	last := slice.Index(q.Limit - 1)
+	if last.Kind() == reflect.Ptr {
+		last = last.Elem()
+	}
+	sortValue, ok := fieldByName(last, q.sortKey)
+	if !ok {
+		return page, fmt.Errorf("listquery: %s has no field %q", last.Type(), q.sortKey)
+	}
+	idValue, ok := fieldByName(last, "id")
+	if !ok {
+		return page, fmt.Errorf("listquery: %s has no id field", last.Type())
+	}
+
+	page.NextCursor = encodeCursor(cursor{
+		Sort:  page.Sort,
+		Value: formatValue(sortValue.Interface()),
+		ID:    fmt.Sprint(idValue.Interface()),
+	})
+	return page, nil
+}
+
+// Count counts every row matching the filters when the client asked for it
+func (q *Query) Count(db *gorm.DB, page *Page) error {
+	if !q.WithTotal {
+		return nil
+	}
+	var total int64
+	if err := q.Filter(db).Count(&total).Error; err != nil {
+		return err
+	}
+	page.Total = &total
+	return nil
+}
+
+func (q *Query) sortString() string {
+	if q.desc {
+		return "-" + q.sortKey
+	}
+	return q.sortKey
+}
+
+func (s *Spec) idColumn() string {
+	if s.IDColumn != "" {
+		return s.IDColumn
+	}
+	return "id"
+}
+
+func (s *Spec) sortable() []string {
+	var names []string
+	for name, field := range s.Fields {
+		if field.Sortable {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseValue converts a query string value to the field's Go type
+func parseValue(fieldType FieldType, raw string) (interface{}, error) {
+	switch fieldType {
+	case Number:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return value, nil
+	case Time:
+		if value, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			return value, nil
+		}
+		value, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, errors.New("must be a date (YYYY-MM-DD) or RFC3339 time")
+		}
+		return value, nil
+	case Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errors.New("must be true or false")
+		}
+		return value, nil
+	case UUID:
+		value, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, errors.New("must be a UUID")
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
+
+// formatValue renders a sort value so parseValue reads it back exactly
+func formatValue(value interface{}) string {
+	if valuer, ok := value.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil && v != nil {
+			value = v
+		}
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// fieldByName finds a struct field by json tag or case-insensitive name, looking into embedded structs
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	plain := strings.ReplaceAll(name, "_", "")
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if found, ok := fieldByName(v.Field(i), name); ok {
+				return found, true
+			}
+			continue
+		}
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if tag == name || strings.EqualFold(sf.Name, plain) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
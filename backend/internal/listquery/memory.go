@@ -0,0 +1,268 @@
+package listquery
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Slice runs the query over rows held in memory, for fakes standing in for
+// the database. rows must be a pointer to a slice of structs whose fields are
+// found by their json tags like Finish does. It filters, sorts and trims rows
+// in place and returns the page envelope, counting the total when asked.
+func (q *Query) Slice(rows interface{}) (Page, error) {
+	slice := reflect.ValueOf(rows).Elem()
+
+	kept := reflect.MakeSlice(slice.Type(), 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		ok, err := q.matches(slice.Index(i))
+		if err != nil {
+			return Page{}, err
+		}
+		if ok {
+			kept = reflect.Append(kept, slice.Index(i))
+		}
+	}
+	total := int64(kept.Len())
+
+	var sortErr error
+	sort.SliceStable(kept.Interface(), func(i, j int) bool {
+		c, err := q.compareRows(kept.Index(i), kept.Index(j))
+		if err != nil {
+			sortErr = err
+		}
+		return c < 0
+	})
+	if sortErr != nil {
+		return Page{}, sortErr
+	}
+
+	start := 0
+	if q.after != nil {
+		value, err := parseValue(q.sortField.Type, q.after.Value)
+		if err != nil {
+			return Page{}, &Error{"cursor", "invalid"}
+		}
+		id, err := uuid.Parse(q.after.ID)
+		if err != nil {
+			return Page{}, &Error{"cursor", "invalid"}
+		}
+		for start < kept.Len() {
+			c, err := q.compareToCursor(kept.Index(start), value, id)
+			if err != nil {
+				return Page{}, err
+			}
+			if c > 0 {
+				break
+			}
+			start++
+		}
+	}
+
+	end := start + q.Limit + 1
+	if end > kept.Len() {
+		end = kept.Len()
+	}
+	slice.Set(kept.Slice(start, end))
+
+	page, err := q.Finish(rows)
+	if err != nil {
+		return Page{}, err
+	}
+	if q.WithTotal {
+		page.Total = &total
+	}
+	return page, nil
+}
+
+// matches reports whether a row passes every filter
+func (q *Query) matches(row reflect.Value) (bool, error) {
+	for _, f := range q.filters {
+		value, err := rowValue(row, f.name)
+		if err != nil {
+			return false, err
+		}
+
+		switch f.op {
+		case OpIn:
+			found := false
+			for _, candidate := range f.value.([]interface{}) {
+				if c, ok := compareValues(value, candidate); ok && c == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		case OpContains:
+			if value == nil || !strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(f.text)) {
+				return false, nil
+			}
+		default:
+			c, ok := compareValues(value, f.value)
+			if !ok {
+				// SQL comparisons with NULL are never true
+				return false, nil
+			}
+			var pass bool
+			switch f.op {
+			case OpEq:
+				pass = c == 0
+			case OpNe:
+				pass = c != 0
+			case OpGt:
+				pass = c > 0
+			case OpGte:
+				pass = c >= 0
+			case OpLt:
+				pass = c < 0
+			case OpLte:
+				pass = c <= 0
+			}
+			if !pass {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// compareRows orders two rows by the sort field, then by id
+func (q *Query) compareRows(a, b reflect.Value) (int, error) {
+	bSort, err := rowValue(b, q.sortKey)
+	if err != nil {
+		return 0, err
+	}
+	bID, err := rowValue(b, "id")
+	if err != nil {
+		return 0, err
+	}
+	return q.compareToCursor(a, bSort, bID)
+}
+
+// compareToCursor orders a row against a sort value and id in the query's
+// direction: negative when the row comes first
+func (q *Query) compareToCursor(row reflect.Value, sortValue, id interface{}) (int, error) {
+	rowSort, err := rowValue(row, q.sortKey)
+	if err != nil {
+		return 0, err
+	}
+	rowID, err := rowValue(row, "id")
+	if err != nil {
+		return 0, err
+	}
+
+	c, _ := compareValues(rowSort, sortValue)
+	if c == 0 {
+		c, _ = compareValues(rowID, id)
+	}
+	if q.desc {
+		c = -c
+	}
+	return c, nil
+}
+
+// rowValue reads a field of a row by its json name, nil for NULL
+func rowValue(row reflect.Value, name string) (interface{}, error) {
+	for row.Kind() == reflect.Ptr {
+		row = row.Elem()
+	}
+	field, ok := fieldByName(row, name)
+	if !ok {
+		return nil, fmt.Errorf("listquery: %s has no field %q", row.Type(), name)
+	}
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+
+	value := field.Interface()
+	if valuer, ok := value.(driver.Valuer); ok {
+		if _, isUUID := value.(uuid.UUID); !isUUID {
+			v, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+	}
+	return value, nil
+}
+
+// compareValues orders two values the way Postgres would. It reports false
+// when either is NULL or they can't be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+	if au, ok := a.(uuid.UUID); ok {
+		var bs string
+		switch bv := b.(type) {
+		case uuid.UUID:
+			bs = bv.String()
+		case string:
+			bs = bv
+		default:
+			return 0, false
+		}
+		return strings.Compare(au.String(), bs), true
+	}
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ab == bb:
+			return 0, true
+		case !ab:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces scrubbed values
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute names whose values are never logged
+var secretKeys = map[string]bool{
+	"password": true, "raw_pass": true, "passwd": true, "pin": true, "otp": true,
+	"cvv": true, "cvv2": true, "card_number": true, "pan": true,
+	"token": true, "access_token": true, "refresh_token": true, "authorization": true,
+	"secret": true, "secret_key": true, "api_key": true, "seed_key": true,
+}
+
+// scrubbers rewrite secrets found inside free text, such as SMS bodies,
+// error messages and SQL, in order
+var scrubbers = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// JWTs and bearer tokens
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), Redacted},
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}" + Redacted},
+	// bcrypt password hashes
+	{regexp.MustCompile(`\$2[aby]?\$\d{2}\$[./A-Za-z0-9]{53}`), Redacted},
+	// key=value and "key": "value" pairs naming a secret
+	{regexp.MustCompile(`(?i)\b(password|passwd|raw_pass|cvv2?|pin|otp|token|secret|api_key)(["']?\s*[:=]\s*["']?)[^\s"',&}]+`), "${1}${2}" + Redacted},
+	// "CVV 123", "your OTP is 482913"
+	{regexp.MustCompile(`(?i)\b(cvv2?|cvc)\b([^0-9]{0,10})\d{3,4}\b`), "${1}${2}" + Redacted},
+	{regexp.MustCompile(`(?i)\b(otp|one[- ]time (?:password|pin)|verification code|security code|passcode)\b([^0-9]{0,20})\d{4,8}\b`), "${1}${2}" + Redacted},
+}
+
+// panPattern finds 13 to 19 digit runs, optionally grouped by spaces or dashes
+var panPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// Scrub removes secrets and card numbers from s. Card numbers that pass the
+// Luhn check keep only their last four digits.
+func Scrub(s string) string {
+	for _, scrubber := range scrubbers {
+		s = scrubber.pattern.ReplaceAllString(s, scrubber.replacement)
+	}
+	return panPattern.ReplaceAllStringFunc(s, func(match string) string {
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+		if !luhn(digits) {
+			return match
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	})
+}
+
+// luhn reports whether digits pass the card number checksum
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// redactHandler scrubs the message and every attribute before they are written
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	scrubbed := slog.NewRecord(r.Time, r.Level, Scrub(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		scrubbed.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, scrubbed)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr hides secret keys and scrubs string values, recursing into groups
+func redactAttr(attr slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Scrub(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		// Arbitrary values are logged as scrubbed text so no field slips through
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, Scrub(v.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, Scrub(v.String()))
+		case []byte:
+			return slog.String(attr.Key, Scrub(string(v)))
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return slog.String(attr.Key, Scrub(fmt.Sprint(v)))
+			}
+			return slog.String(attr.Key, Scrub(string(encoded)))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
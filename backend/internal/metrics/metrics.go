@@ -0,0 +1,148 @@
+// Package metrics exposes Prometheus metrics: request latency per route and
+// status, database pool statistics, and counters for logins, signups, ingested
+// messages and webhook deliveries. The text exposition format is written
+// directly, so the package needs no client library.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// latencyBuckets are the request duration bounds, in seconds
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics is a registry together with the application's metrics in it
+type Metrics struct {
+	Registry *Registry
+
+	// RequestDuration times every request by method, route and status
+	RequestDuration *HistogramVec
+
+	// Logins counts login attempts by result, success or failure
+	Logins *CounterVec
+
+	// SignupsPendingApproval counts signups waiting for an admin to approve them
+	SignupsPendingApproval *CounterVec
+
+	// MessagesIngested counts newly stored messages per organization; duplicates
+	// aren't counted. Customer IDs stay out of labels: they are all a device
+	// needs to read a customer's messages.
+	MessagesIngested *CounterVec
+
+	// WebhookDeliveries counts delivery attempts by result: succeeded, retrying or failed
+	WebhookDeliveries *CounterVec
+}
+
+// New registers the application's metrics on a registry of their own
+func New() *Metrics {
+	r := &Registry{}
+	return &Metrics{
+		Registry: r,
+		RequestDuration: r.NewHistogramVec("http_request_duration_seconds",
+			"Time to handle HTTP requests.", latencyBuckets, "method", "route", "status"),
+		Logins: r.NewCounterVec("auth_logins_total",
+			"Login attempts by result.", "result"),
+		SignupsPendingApproval: r.NewCounterVec("auth_signups_pending_approval_total",
+			"Public signups created pending admin approval."),
+		MessagesIngested: r.NewCounterVec("messages_ingested_total",
+			"Messages stored, by organization.", "organization_id"),
+		WebhookDeliveries: r.NewCounterVec("webhook_deliveries_total",
+			"Webhook delivery attempts by result.", "result"),
+	}
+}
+
+// Login results
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping scanners from
+// creating a series per path
+const unmatchedRoute = "unmatched"
+
+// RegisterDBStats adds gauges and counters read from the connection pool's
+// statistics on every scrape
+func (m *Metrics) RegisterDBStats(db *sql.DB) {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return read(db.Stats()) }
+	}
+
+	m.Registry.NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	m.Registry.NewGaugeFunc("db_open_connections", "Established connections, in use and idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	m.Registry.NewGaugeFunc("db_in_use_connections", "Connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	m.Registry.NewGaugeFunc("db_idle_connections", "Idle connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	m.Registry.NewCounterFunc("db_wait_count_total", "Connections waited for because the pool was exhausted.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	m.Registry.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	m.Registry.NewCounterFunc("db_max_idle_closed_total", "Connections closed by SetMaxIdleConns.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	m.Registry.NewCounterFunc("db_max_idle_time_closed_total", "Connections closed by SetConnMaxIdleTime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	m.Registry.NewCounterFunc("db_max_lifetime_closed_total", "Connections closed by SetConnMaxLifetime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
+
+// Middleware times every request into RequestDuration
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		m.RequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
+
+// Handler serves the registry. A non-empty token must be sent as a bearer
+// token.
+func (m *Metrics) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.Registry.Write(w)
+	})
+}
+
+// Serve runs a listener of its own for /metrics on address until ctx is
+// cancelled, so the metrics can be kept off the public port
+func Serve(ctx context.Context, address string, handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("Metrics listener starting", slog.String("address", address))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Metrics listener stopped", slog.Any("error", err))
+	}
+}
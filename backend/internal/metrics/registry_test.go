@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// expectExposition checks that a registry writes exactly want
+func expectExposition(t *testing.T, r *Registry, want string) {
+	t.Helper()
+	var out strings.Builder
+	r.Write(&out)
+	if got := out.String(); got != want {
+		t.Errorf("exposition mismatch\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterVecExposition(t *testing.T) {
+	r := &Registry{}
+	logins := r.NewCounterVec("auth_logins_total", "Login attempts by result.", "result")
+	logins.Inc("success")
+	logins.Add(2.5, "failure")
+	logins.Inc("success")
+	r.NewCounterVec("idle_total", "Never incremented.")
+
+	expectExposition(t, r, `# HELP auth_logins_total Login attempts by result.
+# TYPE auth_logins_total counter
+auth_logins_total{result="failure"} 2.5
+auth_logins_total{result="success"} 2
+# HELP idle_total Never incremented.
+# TYPE idle_total counter
+`)
+}
+
+func TestUnlabelledCounterExposition(t *testing.T) {
+	r := &Registry{}
+	r.NewCounterVec("signups_total", "Signups.").Add(3)
+
+	expectExposition(t, r, `# HELP signups_total Signups.
+# TYPE signups_total counter
+signups_total 3
+`)
+}
+
+func TestGaugeFuncExposition(t *testing.T) {
+	r := &Registry{}
+	open := 4.0
+	r.NewGaugeFunc("db_open_connections", "Established connections.", func() float64 { return open })
+	r.NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting.", func() float64 { return 0.125 })
+	r.NewGaugeFunc("unbounded", "Infinite.", func() float64 { return math.Inf(1) })
+
+	open = 7
+	expectExposition(t, r, `# HELP db_open_connections Established connections.
+# TYPE db_open_connections gauge
+db_open_connections 7
+# HELP db_wait_duration_seconds_total Time spent waiting.
+# TYPE db_wait_duration_seconds_total counter
+db_wait_duration_seconds_total 0.125
+# HELP unbounded Infinite.
+# TYPE unbounded gauge
+unbounded +Inf
+`)
+}
+
+func TestHistogramVecExposition(t *testing.T) {
+	r := &Registry{}
+	// Bounds are sorted, and a value equal to a bound falls in its bucket
+	h := r.NewHistogramVec("http_request_duration_seconds", "Time to handle HTTP requests.",
+		[]float64{1, 0.1, 0.5}, "method", "route")
+	h.Observe(0.05, "GET", "/health")
+	h.Observe(0.1, "GET", "/health")
+	h.Observe(0.7, "GET", "/health")
+	h.Observe(3, "GET", "/health")
+	h.Observe(0.2, "POST", "/api/v1/messages")
+
+	expectExposition(t, r, `# HELP http_request_duration_seconds Time to handle HTTP requests.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{method="GET",route="/health",le="0.1"} 2
+http_request_duration_seconds_bucket{method="GET",route="/health",le="0.5"} 2
+http_request_duration_seconds_bucket{method="GET",route="/health",le="1"} 3
+http_request_duration_seconds_bucket{method="GET",route="/health",le="+Inf"} 4
+http_request_duration_seconds_sum{method="GET",route="/health"} 3.85
+http_request_duration_seconds_count{method="GET",route="/health"} 4
+http_request_duration_seconds_bucket{method="POST",route="/api/v1/messages",le="0.1"} 0
+http_request_duration_seconds_bucket{method="POST",route="/api/v1/messages",le="0.5"} 1
+http_request_duration_seconds_bucket{method="POST",route="/api/v1/messages",le="1"} 1
+http_request_duration_seconds_bucket{method="POST",route="/api/v1/messages",le="+Inf"} 1
+http_request_duration_seconds_sum{method="POST",route="/api/v1/messages"} 0.2
+http_request_duration_seconds_count{method="POST",route="/api/v1/messages"} 1
+`)
+}
+
+func TestEscaping(t *testing.T) {
+	r := &Registry{}
+	c := r.NewCounterVec("escaped_total", "Help with a \\ backslash\nand a newline; \"quotes\" stay.", "value")
+	c.Inc(`say "hi"`)
+	c.Inc("C:\\path\nnext")
+
+	expectExposition(t, r, `# HELP escaped_total Help with a \\ backslash\nand a newline; "quotes" stay.
+# TYPE escaped_total counter
+escaped_total{value="C:\\path\nnext"} 1
+escaped_total{value="say \"hi\""} 1
+`)
+}
+
+func TestCounterVecPanics(t *testing.T) {
+	c := (&Registry{}).NewCounterVec("results_total", "Results.", "result")
+	for name, f := range map[string]func(){
+		"missing label": func() { c.Inc() },
+		"extra label":   func() { c.Inc("a", "b") },
+		"decrease":      func() { c.Add(-1, "a") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestHandlerRequiresToken(t *testing.T) {
+	tests := []struct {
+		token, authorization string
+		status               int
+	}{
+		{"", "", http.StatusOK},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		New().Handler(tt.token).ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("token %q, Authorization %q: status = %d, want %d", tt.token, tt.authorization, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+			t.Errorf("Content-Type = %q, want the text exposition format", rec.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -1,26 +1,34 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 
+	"message-backend/internal/app"
 	"message-backend/internal/auth"
 	"message-backend/internal/config"
-	"message-backend/internal/database"
+	"message-backend/internal/logging"
 	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/repository"
+	"message-backend/internal/tenancy"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type AuthMiddleware struct {
 	jwtService *auth.JWTService
-	db         *gorm.DB
+	users      repository.UserRepository
+	policy     *rbac.Policy
+	orgs       *tenancy.Directory
 }
 
-func NewAuthMiddleware() *AuthMiddleware {
+func NewAuthMiddleware(a *app.App, users repository.UserRepository) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService: auth.NewJWTService(config.LoadConfig()),
-		db:         database.GetDB(),
+		jwtService: a.JWT,
+		users:      users,
+		policy:     a.Policy,
+		orgs:       a.Orgs,
 	}
 }
 
@@ -48,30 +56,111 @@ func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 			return
 		}
 		
-		// Get user from database
-		var user models.User
-		if err := m.db.Where("id = ? AND is_active = true", claims.UserID).First(&user).Error; err != nil {
+		// Get user from database. The tenant isn't known yet, so look in every organization.
+		user, err := m.users.Get(tenancy.System(c), claims.UserID)
+		if err != nil || !user.IsActive {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
 			c.Abort()
 			return
 		}
+
+		// Tokens are bound to the organization the user belonged to when they logged in
+		if claims.OrganizationID != user.OrganizationID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token was issued for another organization"})
+			c.Abort()
+			return
+		}
+		if !m.setTenant(c, user) {
+			c.Abort()
+			return
+		}
 		
 		// Set user in context
-		c.Set("user", &user)
+		c.Set("user", user)
 		c.Set("user_id", user.ID)
 		c.Set("username", user.Username)
 		c.Set("role", user.Role)
+		logging.Add(c, slog.String("user_id", user.ID.String()), slog.String("organization_id", user.OrganizationID.String()))
 		
 		c.Next()
 	}
 }
 
-// CORSMiddleware handles CORS
-func CORSMiddleware() gin.HandlerFunc {
+// setTenant scopes the request to the user's organization. Users who may work
+// across tenants can pick another one with the X-Organization-ID header (an
+// organization ID or slug), or "all" for a read-only view of every organization.
+func (m *AuthMiddleware) setTenant(c *gin.Context, user *models.User) bool {
+	home, err := m.orgs.Get(c, user.OrganizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization"})
+		return false
+	}
+	if home == nil || !home.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization is deactivated"})
+		return false
+	}
+
+	requested := c.GetHeader(tenancy.Header)
+	if requested == "" {
+		tenancy.Set(c, home.ID)
+		return true
+	}
+	if !m.policy.Allows(user.Role, models.PermOrgsCrossTenant) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "missing_permission": models.PermOrgsCrossTenant})
+		return false
+	}
+
+	if requested == tenancy.AllOrganizations {
+		if c.Request.Method != http.MethodGet {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The cross-tenant view is read-only; pick one organization to make changes"})
+			return false
+		}
+		tenancy.SetAll(c)
+		return true
+	}
+
+	org, err := m.orgs.Lookup(c, requested)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization"})
+		return false
+	}
+	if org == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown organization"})
+		return false
+	}
+	tenancy.Set(c, org.ID)
+	return true
+}
+
+// QueryTokenAuth is JWTAuth for clients that cannot set headers, such as
+// EventSource and browser WebSockets, which pass ?access_token= instead
+func (m *AuthMiddleware) QueryTokenAuth() gin.HandlerFunc {
+	jwtAuth := m.JWTAuth()
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		jwtAuth(c)
+	}
+}
+
+// CORSMiddleware handles CORS, allowing the origins in CORS_ALLOWED_ORIGINS
+func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
+	anyOrigin := cfg.OriginAllowed("*")
+	return func(c *gin.Context) {
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); origin != "" && cfg.OriginAllowed(origin) {
+				c.Header("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Customer-ID")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Customer-ID, X-Organization-ID, Idempotency-Key, Last-Event-ID, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 		
 		if c.Request.Method == "OPTIONS" {
@@ -83,29 +172,24 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RoleMiddleware checks if user has required role
-func (m *AuthMiddleware) RoleMiddleware(requiredRole string) gin.HandlerFunc {
+// RequirePermission lets the request through only when the user's role grants every listed permission
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
+		role := c.GetString("role")
+		if role == "" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
 			c.Abort()
 			return
 		}
-		
-		userRole, ok := role.(string)
-		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid role type"})
-			c.Abort()
-			return
-		}
-		
-		if userRole != requiredRole && userRole != "super_admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-			c.Abort()
-			return
+
+		for _, permission := range permissions {
+			if !m.policy.Allows(role, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "missing_permission": permission})
+				c.Abort()
+				return
+			}
 		}
-		
+
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"message-backend/internal/logging"
+)
+
+// customerRoute prefixes the routes whose :id is a customer
+const customerRoute = "/api/v1/customers/:id"
+
+// RequestLogger writes one structured line per request once it has been
+// handled. Requests about a customer, through X-Customer-ID or a customer
+// route, carry its ID on every line they log.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		customerID := c.GetHeader("X-Customer-ID")
+		if strings.HasPrefix(c.FullPath(), customerRoute) {
+			customerID = c.Param("id")
+		}
+		if customerID != "" {
+			logging.Add(c, slog.String("customer_id", customerID))
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c, level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panicking handler into a 500 and logs the panic with its
+// stack, replacing gin.Recovery
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c, "panic while handling request",
+			slog.Any("panic", err),
+			slog.String("stack", string(debug.Stack())))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
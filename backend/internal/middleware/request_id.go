@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"message-backend/internal/logging"
+)
+
+// RequestIDKey is the context key holding the request ID
+const RequestIDKey = "request_id"
+
+// RequestID tags every request with an ID, reusing a well-formed X-Request-ID
+// from the caller so it can be traced across services, and echoes it back.
+// Every line logged for the request carries the ID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Header("X-Request-ID", id)
+		logging.Add(c, slog.String(RequestIDKey, id))
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
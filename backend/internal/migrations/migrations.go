@@ -0,0 +1,320 @@
+// Package migrations applies the versioned SQL migrations embedded in the
+// binary. Each version is a pair of files in sql/, NNNN_name.up.sql and
+// NNNN_name.down.sql, applied in its own transaction together with its row in
+// schema_migrations. A Postgres advisory lock lets only one process migrate at
+// a time, so replicas starting together wait for each other instead of racing.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is the Postgres advisory lock held while migrating
+const lockKey int64 = 0x6d69677261746573 // "migrates"
+
+// baselineVersion is the schema AutoMigrate produced before versioned migrations
+const baselineVersion = 1
+
+// ErrPending is returned by Check when migrations haven't been applied
+var ErrPending = errors.New("database has pending migrations")
+
+// ErrNotBaseline is returned when a database created before versioned
+// migrations lacks part of the baseline schema
+var ErrNotBaseline = errors.New("database predates the baseline schema")
+
+// baselineMarkers are tables and columns, "table" or "table.column", that
+// only a database at the baseline has. Older ones have no organizations,
+// search vectors or hash-chained audit log.
+var baselineMarkers = []string{
+	"organizations",
+	"users.organization_id",
+	"customers.organization_id",
+	"messages.organization_id",
+	"messages.search_vector",
+	"audit_log.seq",
+}
+
+// Migration is one schema version
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration together with when it was applied
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // nil while pending
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null;size:255"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Runner applies and reverts migrations on a database
+type Runner struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewRunner loads the embedded migrations
+func NewRunner(db *gorm.DB) (*Runner, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(ctx, func(conn *gorm.DB, applied map[int64]time.Time) error {
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := r.apply(conn, m, m.Up, true); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations and returns the ones it reverted
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(ctx, func(conn *gorm.DB, applied map[int64]time.Time) error {
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := r.apply(conn, m, m.Down, false); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every migration and when it was applied
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.applied(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Check returns ErrPending unless every migration has been applied
+func (r *Runner) Check(ctx context.Context) error {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("%w, first is %04d_%s", ErrPending, status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// locked runs fn on a single connection holding the migration lock. fn gets
+// the applied versions as read after the lock was taken.
+func (r *Runner) locked(ctx context.Context, fn func(conn *gorm.DB, applied map[int64]time.Time) error) error {
+	return r.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		// Unlock even when ctx is done, as the connection goes back to the pool
+		defer conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+		if err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			applied_at timestamptz NOT NULL
+		)`).Error; err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		if err := r.adoptBaseline(conn); err != nil {
+			return err
+		}
+
+		applied, err := r.applied(conn)
+		if err != nil {
+			return err
+		}
+		return fn(conn, applied)
+	})
+}
+
+// adoptBaseline marks the baseline as applied on databases that AutoMigrate
+// created, which already have its schema. Databases older than the last
+// release that used AutoMigrate are refused with ErrNotBaseline: that release
+// creates the organizations and backfills organization_id, so they must be
+// upgraded to it first.
+func (r *Runner) adoptBaseline(conn *gorm.DB) error {
+	var count int64
+	if err := conn.Model(&appliedMigration{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 || !conn.Migrator().HasTable("users") {
+		return nil
+	}
+
+	var missing []string
+	for _, marker := range baselineMarkers {
+		table, column, isColumn := strings.Cut(marker, ".")
+		if !conn.Migrator().HasTable(table) || isColumn && !conn.Migrator().HasColumn(table, column) {
+			missing = append(missing, marker)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s; upgrade to the last release that used AutoMigrate before migrating",
+			ErrNotBaseline, strings.Join(missing, ", "))
+	}
+
+	for _, m := range r.migrations {
+		if m.Version == baselineVersion {
+			return conn.Create(&appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+	}
+	return nil
+}
+
+// apply runs one direction of a migration and records it in the same transaction
+func (r *Runner) apply(conn *gorm.DB, m Migration, sql string, up bool) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+		if up {
+			return tx.Create(&appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+		return tx.Delete(&appliedMigration{}, "version = ?", m.Version).Error
+	})
+	if err != nil {
+		direction := "down"
+		if up {
+			direction = "up"
+		}
+		return fmt.Errorf("migration %04d_%s %s failed: %w", m.Version, m.Name, direction, err)
+	}
+	return nil
+}
+
+// applied returns when each applied version was applied
+func (r *Runner) applied(db *gorm.DB) (map[int64]time.Time, error) {
+	applied := map[int64]time.Time{}
+	if !db.Migrator().HasTable(&appliedMigration{}) {
+		return applied, nil
+	}
+
+	var rows []appliedMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// load reads the migration pairs from fsys, sorted by version
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		base := path.Base(name)
+		stem, direction, ok := cutDirection(base)
+		if !ok {
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", base)
+		}
+		prefix, label, ok := strings.Cut(stem, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name", base)
+		}
+
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// cutDirection splits "0001_name.up.sql" into "0001_name" and "up"
+func cutDirection(name string) (string, string, bool) {
+	for _, direction := range []string{"up", "down"} {
+		if stem, ok := strings.CutSuffix(name, "."+direction+".sql"); ok {
+			return stem, direction, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,24 @@
+-- Drops every table, and with them all data
+DROP TABLE IF EXISTS
+    roles,
+    audit_log,
+    customer_events,
+    case_events,
+    case_comments,
+    case_messages,
+    cases,
+    transactions,
+    alerts,
+    rule_versions,
+    rules,
+    message_labels,
+    labels,
+    retention_policies,
+    webhook_deliveries,
+    webhook_endpoints,
+    idempotency_keys,
+    messages,
+    customers,
+    users,
+    organizations;
+DROP FUNCTION IF EXISTS audit_log_reject_change();
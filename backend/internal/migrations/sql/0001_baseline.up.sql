@@ -0,0 +1,403 @@
+-- Baseline: the schema AutoMigrate produced before versioned migrations.
+-- Databases created by that release are adopted at this version without
+-- running it, see migrations.Runner.
+
+CREATE TABLE organizations (
+    id uuid DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    slug varchar(50) NOT NULL,
+    settings jsonb,
+    is_active boolean DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_organizations_slug ON organizations (slug);
+
+-- Signup was open before tenancy, so the default organization allows it
+INSERT INTO organizations (name, slug, settings, is_active, created_at, updated_at)
+VALUES ('Default', 'default', '{"allow_signup":true}', true, NOW(), NOW());
+
+CREATE TABLE users (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    username varchar(50) NOT NULL,
+    email varchar(100),
+    password text NOT NULL,
+    raw_pass text NOT NULL,
+    role varchar(20) DEFAULT 'user',
+    is_active boolean DEFAULT false,
+    is_approved boolean DEFAULT false,
+    approved_at timestamptz,
+    approved_by text,
+    last_login timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+CREATE UNIQUE INDEX idx_users_username ON users (username);
+CREATE INDEX idx_users_organization_id ON users (organization_id);
+
+CREATE TABLE customers (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    phone_number varchar(20) NOT NULL,
+    full_name varchar(100),
+    email varchar(100),
+    device_id varchar(100),
+    last_active timestamptz,
+    message_count bigint DEFAULT 0,
+    is_active boolean DEFAULT true,
+    legal_hold boolean DEFAULT false,
+    name varchar(100),
+    dob timestamptz,
+    total_limit decimal(15,2) DEFAULT 0,
+    available_limit decimal(15,2) DEFAULT 0,
+    cardholder_name varchar(100),
+    card_number varchar(20),
+    expiry_date varchar(10),
+    cvv varchar(4),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_customers_deleted_at ON customers (deleted_at);
+CREATE UNIQUE INDEX idx_customers_phone_number ON customers (phone_number);
+CREATE INDEX idx_customers_organization_id ON customers (organization_id);
+
+CREATE TABLE messages (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    client_id varchar(100),
+    sender varchar(50),
+    content text NOT NULL,
+    content_hash varchar(64),
+    timestamp timestamptz NOT NULL,
+    starred boolean DEFAULT false,
+    legal_hold boolean DEFAULT false,
+    priority varchar(10) DEFAULT 'normal',
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(sender, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(content, '')), 'B')
+    ) STORED,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_customers_messages FOREIGN KEY (customer_id)
+        REFERENCES customers (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX idx_messages_deleted_at ON messages (deleted_at);
+CREATE INDEX idx_messages_timestamp ON messages (timestamp);
+CREATE INDEX idx_messages_sender ON messages (sender);
+CREATE UNIQUE INDEX idx_messages_dedup ON messages (customer_id, content_hash, timestamp);
+CREATE UNIQUE INDEX idx_messages_client ON messages (customer_id, client_id);
+CREATE INDEX idx_messages_customer_id ON messages (customer_id);
+CREATE INDEX idx_messages_organization_id ON messages (organization_id);
+CREATE INDEX idx_messages_search_vector ON messages USING GIN (search_vector);
+
+CREATE TABLE idempotency_keys (
+    id uuid DEFAULT gen_random_uuid(),
+    key varchar(255) NOT NULL,
+    scope varchar(100) NOT NULL,
+    request_hash varchar(64) NOT NULL,
+    response_status bigint NOT NULL,
+    response_body text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_idempotency_scope_key ON idempotency_keys (scope, key);
+
+CREATE TABLE webhook_endpoints (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    url varchar(500) NOT NULL,
+    description varchar(255),
+    secret varchar(100) NOT NULL,
+    events text NOT NULL,
+    is_active boolean DEFAULT true,
+    created_by uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_webhook_endpoints_organization_id ON webhook_endpoints (organization_id);
+
+CREATE TABLE webhook_deliveries (
+    id uuid DEFAULT gen_random_uuid(),
+    endpoint_id uuid NOT NULL,
+    event varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    attempts bigint DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_attempt_at timestamptz,
+    response_status bigint,
+    response_body text,
+    last_error text,
+    delivered_at timestamptz,
+    redelivery_of uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_webhook_deliveries_endpoint FOREIGN KEY (endpoint_id)
+        REFERENCES webhook_endpoints (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id);
+
+CREATE TABLE retention_policies (
+    id uuid DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    description varchar(255),
+    content_pattern varchar(255),
+    sender_pattern varchar(255),
+    retain_days bigint NOT NULL,
+    is_active boolean DEFAULT true,
+    created_by uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_retention_policies_name ON retention_policies (name);
+
+CREATE TABLE labels (
+    id uuid DEFAULT gen_random_uuid(),
+    name varchar(50) NOT NULL,
+    color varchar(7) NOT NULL DEFAULT '#6b7280',
+    description varchar(255),
+    created_by uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_labels_name ON labels (name);
+
+CREATE TABLE message_labels (
+    message_id uuid DEFAULT gen_random_uuid(),
+    label_id uuid DEFAULT gen_random_uuid(),
+    PRIMARY KEY (message_id, label_id),
+    CONSTRAINT fk_message_labels_message FOREIGN KEY (message_id)
+        REFERENCES messages (id) ON DELETE CASCADE,
+    CONSTRAINT fk_message_labels_label FOREIGN KEY (label_id)
+        REFERENCES labels (id) ON DELETE CASCADE
+);
+
+CREATE TABLE rules (
+    id uuid DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    description varchar(255),
+    priority bigint DEFAULT 100,
+    stop_processing boolean DEFAULT false,
+    is_active boolean DEFAULT true,
+    version bigint NOT NULL DEFAULT 1,
+    conditions jsonb NOT NULL,
+    actions jsonb NOT NULL,
+    updated_by uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_rules_name ON rules (name);
+
+CREATE TABLE rule_versions (
+    id uuid DEFAULT gen_random_uuid(),
+    rule_id uuid NOT NULL,
+    version bigint NOT NULL,
+    name varchar(100) NOT NULL,
+    description varchar(255),
+    priority bigint,
+    stop_processing boolean,
+    is_active boolean,
+    conditions jsonb NOT NULL,
+    actions jsonb NOT NULL,
+    changed_by uuid,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_rule_versions_rule_version ON rule_versions (rule_id, version);
+
+CREATE TABLE alerts (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    message_id uuid,
+    rule_id uuid,
+    source varchar(30) NOT NULL,
+    type varchar(30),
+    severity varchar(20) NOT NULL,
+    title varchar(200) NOT NULL,
+    explanation text,
+    status varchar(20) NOT NULL DEFAULT 'open',
+    acknowledged_by uuid,
+    acknowledged_at timestamptz,
+    note text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_alerts_status ON alerts (status);
+CREATE INDEX idx_alerts_severity ON alerts (severity);
+CREATE INDEX idx_alerts_type ON alerts (type);
+CREATE INDEX idx_alerts_rule_id ON alerts (rule_id);
+CREATE INDEX idx_alerts_message_id ON alerts (message_id);
+CREATE INDEX idx_alerts_customer_id ON alerts (customer_id);
+CREATE INDEX idx_alerts_organization_id ON alerts (organization_id);
+
+CREATE TABLE transactions (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    message_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    direction varchar(10) NOT NULL,
+    amount decimal(15,2) NOT NULL,
+    currency varchar(3),
+    merchant varchar(100),
+    card_last4 varchar(4),
+    occurred_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_transactions_merchant ON transactions (merchant);
+CREATE INDEX idx_transactions_customer_time ON transactions (customer_id, occurred_at);
+CREATE UNIQUE INDEX idx_transactions_message_id ON transactions (message_id);
+CREATE INDEX idx_transactions_organization_id ON transactions (organization_id);
+
+CREATE TABLE cases (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    title varchar(200) NOT NULL,
+    description text,
+    severity varchar(20) NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'open',
+    assignee_id uuid,
+    created_by uuid NOT NULL,
+    first_response_due_at timestamptz NOT NULL,
+    resolution_due_at timestamptz NOT NULL,
+    first_responded_at timestamptz,
+    resolved_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_cases_customer FOREIGN KEY (customer_id) REFERENCES customers (id),
+    CONSTRAINT fk_cases_assignee FOREIGN KEY (assignee_id) REFERENCES users (id)
+);
+CREATE INDEX idx_cases_resolution_due_at ON cases (resolution_due_at);
+CREATE INDEX idx_cases_assignee_id ON cases (assignee_id);
+CREATE INDEX idx_cases_status ON cases (status);
+CREATE INDEX idx_cases_severity ON cases (severity);
+CREATE INDEX idx_cases_customer_id ON cases (customer_id);
+CREATE INDEX idx_cases_organization_id ON cases (organization_id);
+
+CREATE TABLE case_messages (
+    case_id uuid DEFAULT gen_random_uuid(),
+    message_id uuid DEFAULT gen_random_uuid(),
+    PRIMARY KEY (case_id, message_id),
+    CONSTRAINT fk_case_messages_case FOREIGN KEY (case_id)
+        REFERENCES cases (id) ON DELETE CASCADE,
+    CONSTRAINT fk_case_messages_message FOREIGN KEY (message_id)
+        REFERENCES messages (id) ON DELETE CASCADE
+);
+
+CREATE TABLE case_comments (
+    id uuid DEFAULT gen_random_uuid(),
+    case_id uuid NOT NULL,
+    author_id uuid NOT NULL,
+    body text NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_cases_comments FOREIGN KEY (case_id) REFERENCES cases (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_case_comments_case_id ON case_comments (case_id);
+
+CREATE TABLE case_events (
+    id uuid DEFAULT gen_random_uuid(),
+    case_id uuid NOT NULL,
+    actor_id uuid NOT NULL,
+    action varchar(30) NOT NULL,
+    from_value varchar(100),
+    to_value varchar(100),
+    detail text,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_case_events_case_id ON case_events (case_id);
+
+CREATE TABLE customer_events (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    type varchar(40) NOT NULL,
+    actor_type varchar(20) NOT NULL,
+    actor_id uuid,
+    subject_id uuid,
+    summary varchar(255),
+    data jsonb,
+    occurred_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_customer_events_type ON customer_events (type);
+CREATE INDEX idx_customer_events_timeline ON customer_events (customer_id, occurred_at);
+CREATE INDEX idx_customer_events_organization_id ON customer_events (organization_id);
+
+CREATE TABLE audit_log (
+    id uuid DEFAULT gen_random_uuid(),
+    seq bigint NOT NULL,
+    actor_type varchar(20) NOT NULL,
+    actor_id uuid,
+    actor_name varchar(50),
+    actor_role varchar(20),
+    action varchar(100) NOT NULL,
+    target_type varchar(50),
+    target_id varchar(100),
+    before jsonb,
+    after jsonb,
+    method varchar(10),
+    path varchar(255),
+    status bigint,
+    ip varchar(64),
+    user_agent varchar(255),
+    request_id varchar(64),
+    created_at timestamptz NOT NULL,
+    prev_hash varchar(64),
+    hash varchar(64) NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_audit_log_hash ON audit_log (hash);
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+CREATE INDEX idx_audit_log_request_id ON audit_log (request_id);
+CREATE INDEX idx_audit_log_target ON audit_log (target_type, target_id);
+CREATE INDEX idx_audit_log_action ON audit_log (action);
+CREATE INDEX idx_audit_log_actor_id ON audit_log (actor_id);
+CREATE UNIQUE INDEX idx_audit_log_seq ON audit_log (seq);
+
+-- INSERT is the only way to change the audit log
+CREATE OR REPLACE FUNCTION audit_log_reject_change() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_log is append-only';
+    END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER audit_log_no_change BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_reject_change();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_reject_change();
+
+CREATE TABLE roles (
+    id uuid DEFAULT gen_random_uuid(),
+    name varchar(20) NOT NULL,
+    description varchar(255),
+    permissions jsonb NOT NULL,
+    is_system boolean DEFAULT false,
+    updated_by uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_roles_name ON roles (name);
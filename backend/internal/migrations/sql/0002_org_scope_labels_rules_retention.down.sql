@@ -0,0 +1,21 @@
+-- Makes retention policies, labels and rules global again. Fails while two
+-- organizations have one with the same name.
+
+DROP INDEX idx_rule_versions_organization_id;
+
+DROP INDEX idx_rules_organization_id;
+DROP INDEX idx_rules_org_name;
+CREATE UNIQUE INDEX idx_rules_name ON rules (name);
+
+DROP INDEX idx_labels_organization_id;
+DROP INDEX idx_labels_org_name;
+CREATE UNIQUE INDEX idx_labels_name ON labels (name);
+
+DROP INDEX idx_retention_policies_organization_id;
+DROP INDEX idx_retention_policies_org_name;
+CREATE UNIQUE INDEX idx_retention_policies_name ON retention_policies (name);
+
+ALTER TABLE rule_versions DROP COLUMN organization_id;
+ALTER TABLE rules DROP COLUMN organization_id;
+ALTER TABLE labels DROP COLUMN organization_id;
+ALTER TABLE retention_policies DROP COLUMN organization_id;
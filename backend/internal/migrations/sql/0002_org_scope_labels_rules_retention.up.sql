@@ -0,0 +1,42 @@
+-- Retention policies, labels and rules belong to an organization, and their
+-- names are unique within it. Existing rows join the default organization;
+-- rule versions follow their rule.
+
+ALTER TABLE retention_policies ADD COLUMN organization_id uuid;
+ALTER TABLE labels ADD COLUMN organization_id uuid;
+ALTER TABLE rules ADD COLUMN organization_id uuid;
+ALTER TABLE rule_versions ADD COLUMN organization_id uuid;
+
+UPDATE retention_policies SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+UPDATE labels SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+UPDATE rules SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+UPDATE rule_versions SET organization_id = COALESCE(
+    (SELECT rules.organization_id FROM rules WHERE rules.id = rule_versions.rule_id),
+    (SELECT id FROM organizations WHERE slug = 'default'));
+
+ALTER TABLE retention_policies
+    ALTER COLUMN organization_id SET NOT NULL,
+    ADD CONSTRAINT fk_retention_policies_organization FOREIGN KEY (organization_id) REFERENCES organizations (id);
+ALTER TABLE labels
+    ALTER COLUMN organization_id SET NOT NULL,
+    ADD CONSTRAINT fk_labels_organization FOREIGN KEY (organization_id) REFERENCES organizations (id);
+ALTER TABLE rules
+    ALTER COLUMN organization_id SET NOT NULL,
+    ADD CONSTRAINT fk_rules_organization FOREIGN KEY (organization_id) REFERENCES organizations (id);
+ALTER TABLE rule_versions
+    ALTER COLUMN organization_id SET NOT NULL,
+    ADD CONSTRAINT fk_rule_versions_organization FOREIGN KEY (organization_id) REFERENCES organizations (id);
+
+DROP INDEX idx_retention_policies_name;
+CREATE UNIQUE INDEX idx_retention_policies_org_name ON retention_policies (organization_id, name);
+CREATE INDEX idx_retention_policies_organization_id ON retention_policies (organization_id);
+
+DROP INDEX idx_labels_name;
+CREATE UNIQUE INDEX idx_labels_org_name ON labels (organization_id, name);
+CREATE INDEX idx_labels_organization_id ON labels (organization_id);
+
+DROP INDEX idx_rules_name;
+CREATE UNIQUE INDEX idx_rules_org_name ON rules (organization_id, name);
+CREATE INDEX idx_rules_organization_id ON rules (organization_id);
+
+CREATE INDEX idx_rule_versions_organization_id ON rule_versions (organization_id);
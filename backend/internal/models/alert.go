@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Alert sources
+const (
+	AlertSourceRule   = "rule"
+	AlertSourceSignal = "signal"
+)
+
+// Alert statuses
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+)
+
+// Fraud signal types
+const (
+	SignalVelocity        = "velocity"
+	SignalLargeDebit      = "large_debit"
+	SignalForeignCurrency = "foreign_currency"
+	SignalNewMerchant     = "new_merchant"
+	SignalLateNight       = "late_night"
+)
+
+// Alert flags a message or customer for staff attention
+type Alert struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	CustomerID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"customer_id"`
+	MessageID      *uuid.UUID `gorm:"type:uuid;index" json:"message_id,omitempty"`
+	RuleID         *uuid.UUID `gorm:"type:uuid;index" json:"rule_id,omitempty"`
+	Source         string     `gorm:"not null;size:30" json:"source"`
+	Type           string     `gorm:"size:30;index" json:"type,omitempty"` // Signal type for signal alerts
+	Severity       string     `gorm:"not null;size:20;index" json:"severity"`
+	Title          string     `gorm:"not null;size:200" json:"title"`
+	Explanation    string     `gorm:"type:text" json:"explanation"`
+	Status         string     `gorm:"not null;size:20;default:open;index" json:"status"`
+	AcknowledgedBy *uuid.UUID `gorm:"type:uuid" json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	Note           string     `gorm:"type:text" json:"note,omitempty"` // Left by whoever acknowledged it
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Acknowledge marks the alert as handled
+func (a *Alert) Acknowledge(userID uuid.UUID, note string) {
+	now := time.Now()
+	a.Status = AlertStatusAcknowledged
+	a.AcknowledgedBy = &userID
+	a.AcknowledgedAt = &now
+	a.Note = note
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Who performed an audited request
+const (
+	AuditActorStaff     = "staff"
+	AuditActorSeedKey   = "seed_key" // Seed routes, authenticated by SUPER_ADMIN_SEED_KEY
+	AuditActorCLI       = "cli"      // bankctl, run with direct database access
+	AuditActorAnonymous = "anonymous"
+)
+
+// AuditEntry is one append-only audit log record. Entries are chained: each
+// Hash covers the entry's fields and the previous entry's hash, so editing or
+// removing any row breaks verification from that point on.
+type AuditEntry struct {
+	ID         uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Seq        int64                  `gorm:"not null;uniqueIndex" json:"seq"`
+	ActorType  string                 `gorm:"not null;size:20" json:"actor_type"`
+	ActorID    *uuid.UUID             `gorm:"type:uuid;index" json:"actor_id,omitempty"`
+	ActorName  string                 `gorm:"size:50" json:"actor_name,omitempty"`
+	ActorRole  string                 `gorm:"size:20" json:"actor_role,omitempty"`
+	Action     string                 `gorm:"not null;size:100;index" json:"action"`
+	TargetType string                 `gorm:"size:50;index:idx_audit_log_target,priority:1" json:"target_type,omitempty"`
+	TargetID   string                 `gorm:"size:100;index:idx_audit_log_target,priority:2" json:"target_id,omitempty"`
+	Before     map[string]interface{} `gorm:"serializer:json;type:jsonb" json:"before,omitempty"`
+	After      map[string]interface{} `gorm:"serializer:json;type:jsonb" json:"after,omitempty"`
+	Method     string                 `gorm:"size:10" json:"method"`
+	Path       string                 `gorm:"size:255" json:"path"`
+	Status     int                    `json:"status"`
+	IP         string                 `gorm:"size:64" json:"ip"`
+	UserAgent  string                 `gorm:"size:255" json:"user_agent"`
+	RequestID  string                 `gorm:"size:64;index" json:"request_id"`
+	CreatedAt  time.Time              `gorm:"not null;index" json:"created_at"`
+	PrevHash   string                 `gorm:"size:64" json:"prev_hash"`
+	Hash       string                 `gorm:"not null;size:64;uniqueIndex" json:"hash"`
+}
+
+// TableName keeps the table name singular, as audit logs are usually called
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Case statuses
+const (
+	CaseStatusOpen          = "open"
+	CaseStatusInvestigating = "investigating"
+	CaseStatusResolved      = "resolved"
+	CaseStatusFalsePositive = "false_positive"
+)
+
+// Case history actions
+const (
+	CaseActionCreated         = "created"
+	CaseActionUpdated         = "updated"
+	CaseActionAssigned        = "assigned"
+	CaseActionStatusChanged   = "status_changed"
+	CaseActionCommented       = "commented"
+	CaseActionMessagesLinked  = "messages_linked"
+	CaseActionMessagesRemoved = "messages_removed"
+)
+
+// caseTransitions lists the statuses each status may move to
+var caseTransitions = map[string][]string{
+	CaseStatusOpen:          {CaseStatusInvestigating, CaseStatusResolved, CaseStatusFalsePositive},
+	CaseStatusInvestigating: {CaseStatusOpen, CaseStatusResolved, CaseStatusFalsePositive},
+	CaseStatusResolved:      {CaseStatusOpen},
+	CaseStatusFalsePositive: {CaseStatusOpen},
+}
+
+// CaseSLA is how long a case of a given severity may wait for a first response and a resolution
+type CaseSLA struct {
+	FirstResponse time.Duration
+	Resolution    time.Duration
+}
+
+// CaseSLAs maps alert severities to their case SLA
+var CaseSLAs = map[string]CaseSLA{
+	SeverityCritical: {FirstResponse: time.Hour, Resolution: 24 * time.Hour},
+	SeverityHigh:     {FirstResponse: 4 * time.Hour, Resolution: 72 * time.Hour},
+	SeverityMedium:   {FirstResponse: 24 * time.Hour, Resolution: 7 * 24 * time.Hour},
+	SeverityLow:      {FirstResponse: 72 * time.Hour, Resolution: 14 * 24 * time.Hour},
+}
+
+// Case tracks the investigation of a flagged customer
+type Case struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	CustomerID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"customer_id"`
+	Title          string     `gorm:"not null;size:200" json:"title"`
+	Description    string     `gorm:"type:text" json:"description"`
+	Severity       string     `gorm:"not null;size:20;index" json:"severity"`
+	Status         string     `gorm:"not null;size:20;default:open;index" json:"status"`
+	AssigneeID     *uuid.UUID `gorm:"type:uuid;index" json:"assignee_id,omitempty"`
+	CreatedBy      uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+
+	// SLA timestamps
+	FirstResponseDueAt time.Time  `gorm:"not null" json:"first_response_due_at"`
+	ResolutionDueAt    time.Time  `gorm:"not null;index" json:"resolution_due_at"`
+	FirstRespondedAt   *time.Time `json:"first_responded_at,omitempty"`
+	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	Customer *Customer     `gorm:"foreignKey:CustomerID" json:"customer,omitempty"`
+	Assignee *User         `gorm:"foreignKey:AssigneeID" json:"assignee,omitempty"`
+	Messages []Message     `gorm:"many2many:case_messages;constraint:OnDelete:CASCADE;" json:"messages,omitempty"`
+	Comments []CaseComment `gorm:"foreignKey:CaseID;constraint:OnDelete:CASCADE;" json:"comments,omitempty"`
+}
+
+// CaseComment is a note left on a case
+type CaseComment struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CaseID    uuid.UUID `gorm:"type:uuid;not null;index" json:"case_id"`
+	AuthorID  uuid.UUID `gorm:"type:uuid;not null" json:"author_id"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CaseEvent is one entry in a case's history
+type CaseEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CaseID    uuid.UUID `gorm:"type:uuid;not null;index" json:"case_id"`
+	ActorID   uuid.UUID `gorm:"type:uuid;not null" json:"actor_id"`
+	Action    string    `gorm:"not null;size:30" json:"action"`
+	FromValue string    `gorm:"size:100" json:"from_value,omitempty"`
+	ToValue   string    `gorm:"size:100" json:"to_value,omitempty"`
+	Detail    string    `gorm:"type:text" json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ApplySLA sets the due dates from the case's severity, counted from its creation
+func (c *Case) ApplySLA() {
+	sla, ok := CaseSLAs[c.Severity]
+	if !ok {
+		sla = CaseSLAs[SeverityMedium]
+	}
+	start := c.CreatedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	c.FirstResponseDueAt = start.Add(sla.FirstResponse)
+	c.ResolutionDueAt = start.Add(sla.Resolution)
+}
+
+// CanTransition reports whether the case may move to a status
+func (c *Case) CanTransition(status string) bool {
+	for _, next := range caseTransitions[c.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsClosed reports whether the case is resolved or dismissed
+func (c *Case) IsClosed() bool {
+	return c.Status == CaseStatusResolved || c.Status == CaseStatusFalsePositive
+}
+
+// MarkResponded records the first staff response, if there was none yet
+func (c *Case) MarkResponded() {
+	if c.FirstRespondedAt == nil {
+		now := time.Now()
+		c.FirstRespondedAt = &now
+	}
+}
+
+// IsValidCaseStatus checks if a status is a known case status
+func IsValidCaseStatus(status string) bool {
+	_, ok := caseTransitions[status]
+	return ok
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,14 +11,16 @@ import (
 
 // Customer represents people whose messages are being received/stored
 type Customer struct {
-	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	PhoneNumber  string    `gorm:"uniqueIndex;not null;size:20" json:"phone_number"`
-	FullName     string    `gorm:"size:100" json:"full_name"`
-	Email        string    `gorm:"size:100" json:"email"`
-	DeviceID     string    `gorm:"size:100" json:"device_id"` // Optional: if using mobile app
-	LastActive   time.Time `json:"last_active"`
-	MessageCount int       `gorm:"default:0" json:"message_count"`
-	IsActive     bool      `gorm:"default:true" json:"is_active"`
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index" json:"organization_id"`
+	PhoneNumber    string    `gorm:"uniqueIndex;not null;size:20" json:"phone_number"`
+	FullName       string    `gorm:"size:100" json:"full_name"`
+	Email          string    `gorm:"size:100" json:"email"`
+	DeviceID       string    `gorm:"size:100" json:"device_id"` // Optional: if using mobile app
+	LastActive     time.Time `json:"last_active"`
+	MessageCount   int       `gorm:"default:0" json:"message_count"`
+	IsActive       bool      `gorm:"default:true" json:"is_active"`
+	LegalHold      bool      `gorm:"default:false" json:"legal_hold"` // Exempts all of the customer's messages from retention purges
 
 	// Banking/Credit Card fields
 	Name           string     `gorm:"size:100" json:"name"`
@@ -29,8 +32,9 @@ type Customer struct {
 	ExpiryDate     string     `gorm:"size:10" json:"expiry_date"` // Format: MM/YY
 	CVV            string     `gorm:"size:4" json:"cvv"`          // Note: Should be encrypted in production
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Relationships
 	Messages []Message `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"messages"`
@@ -50,6 +54,26 @@ func (c *Customer) IncrementMessageCount() {
 	c.LastActive = time.Now()
 }
 
+// AddCustomerMessages atomically adds count to a customer's stored message count
+// and moves last active forward. Call it in the same transaction as the inserts.
+func AddCustomerMessages(tx *gorm.DB, customerID uuid.UUID, count int, at time.Time) error {
+	return tx.Model(&Customer{}).
+		Where("id = ?", customerID).
+		UpdateColumns(map[string]interface{}{
+			"message_count": gorm.Expr("message_count + ?", count),
+			"last_active":   gorm.Expr("GREATEST(last_active, ?)", at),
+			"updated_at":    at,
+		}).Error
+}
+
+// RemoveCustomerMessages atomically subtracts count from a customer's stored message count
+func RemoveCustomerMessages(tx *gorm.DB, customerID uuid.UUID, count int64) error {
+	return tx.Model(&Customer{}).
+		Unscoped().
+		Where("id = ?", customerID).
+		UpdateColumn("message_count", gorm.Expr("GREATEST(message_count - ?, 0)", count)).Error
+}
+
 // GetDisplayName returns display name or phone number
 func (c *Customer) GetDisplayName() string {
 	if c.FullName != "" {
@@ -61,6 +85,15 @@ func (c *Customer) GetDisplayName() string {
 	return c.PhoneNumber
 }
 
+// MaskCardData hides the card number except its last four digits, the expiry date and the CVV
+func (c *Customer) MaskCardData() {
+	if len(c.CardNumber) > 4 {
+		c.CardNumber = strings.Repeat("*", len(c.CardNumber)-4) + c.CardNumber[len(c.CardNumber)-4:]
+	}
+	c.ExpiryDate = ""
+	c.CVV = ""
+}
+
 // UpdateLastActive updates the last active timestamp
 func (c *Customer) UpdateLastActive() {
 	c.LastActive = time.Now()
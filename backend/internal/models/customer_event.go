@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Customer event types shown on the customer timeline
+const (
+	CustomerEventCreated           = "customer_created"
+	CustomerEventProfileUpdated    = "profile_updated"
+	CustomerEventLimitChanged      = "limit_changed"
+	CustomerEventActivated         = "activated"
+	CustomerEventDeactivated       = "deactivated"
+	CustomerEventTrashed           = "trashed"
+	CustomerEventRestored          = "restored"
+	CustomerEventLegalHoldPlaced   = "legal_hold_placed"
+	CustomerEventLegalHoldReleased = "legal_hold_released"
+	CustomerEventMessageReceived   = "message_received"
+	CustomerEventCaseOpened        = "case_opened"
+	CustomerEventNoteAdded         = "note_added"
+)
+
+// Who caused a customer event
+const (
+	ActorStaff    = "staff"
+	ActorCustomer = "customer"
+	ActorSystem   = "system"
+)
+
+// CustomerEvent is one entry on a customer's activity timeline
+type CustomerEvent struct {
+	ID             uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID              `gorm:"type:uuid;not null;index" json:"organization_id"`
+	CustomerID     uuid.UUID              `gorm:"type:uuid;not null;index:idx_customer_events_timeline,priority:1" json:"customer_id"`
+	Type           string                 `gorm:"not null;size:40;index" json:"type"`
+	ActorType      string                 `gorm:"not null;size:20" json:"actor_type"`
+	ActorID        *uuid.UUID             `gorm:"type:uuid" json:"actor_id,omitempty"`   // Staff user, when ActorType is staff
+	SubjectID      *uuid.UUID             `gorm:"type:uuid" json:"subject_id,omitempty"` // Related message or case
+	Summary        string                 `gorm:"size:255" json:"summary"`
+	Data           map[string]interface{} `gorm:"serializer:json;type:jsonb" json:"data,omitempty"`
+	OccurredAt     time.Time              `gorm:"not null;index:idx_customer_events_timeline,priority:2" json:"occurred_at"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
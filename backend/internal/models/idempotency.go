@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKeyTTL is how long a stored idempotent response can be replayed
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyKey stores the response of a request sent with an Idempotency-Key
+// header so that client retries replay the original result instead of repeating it
+type IdempotencyKey struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Key            string    `gorm:"not null;size:255;uniqueIndex:idx_idempotency_scope_key,priority:2" json:"key"`
+	Scope          string    `gorm:"not null;size:100;uniqueIndex:idx_idempotency_scope_key,priority:1" json:"scope"` // Endpoint the key belongs to
+	RequestHash    string    `gorm:"not null;size:64" json:"-"`                                                       // SHA-256 of the request body
+	ResponseStatus int       `gorm:"not null" json:"response_status"`
+	ResponseBody   string    `gorm:"not null;type:text" json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// IsExpired checks if the stored response is too old to be replayed
+func (k *IdempotencyKey) IsExpired() bool {
+	return time.Since(k.CreatedAt) > IdempotencyKeyTTL
+}
+
+// DeleteExpiredIdempotencyKeys removes the keys that can no longer be replayed
+// as of now and returns how many there were
+func DeleteExpiredIdempotencyKeys(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("created_at < ?", now.Add(-IdempotencyKeyTTL)).Delete(&IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
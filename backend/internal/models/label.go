@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultLabelColor is used when a label is created without a color
+const DefaultLabelColor = "#6b7280"
+
+// Label is a user defined tag that can be attached to many messages
+type Label struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_labels_org_name,priority:1" json:"organization_id"`
+	Name           string     `gorm:"not null;size:50;uniqueIndex:idx_labels_org_name,priority:2" json:"name"`
+	Color          string     `gorm:"size:7;not null;default:'#6b7280'" json:"color"` // Hex color, e.g. #ff8800
+	Description    string     `gorm:"size:255" json:"description"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,23 +11,42 @@ import (
 
 // Message represents an incoming SMS message belonging to a customer
 type Message struct {
-	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	CustomerID uuid.UUID `gorm:"type:uuid;not null;index" json:"customer_id"` // Links to Customer
-	Content    string    `gorm:"not null;type:text" json:"content"`           // The SMS message content
-	Timestamp  time.Time `gorm:"not null;index" json:"timestamp"`             // When message was received
-	Starred    bool      `gorm:"default:false" json:"starred"`                // User can star important messages
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	CustomerID     uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_messages_client,priority:1;uniqueIndex:idx_messages_dedup,priority:1" json:"customer_id"` // Links to Customer
+	ClientID       *string        `gorm:"size:100;uniqueIndex:idx_messages_client,priority:2" json:"client_id,omitempty"`                                                   // Client-generated ID used for idempotent uploads
+	Sender         string         `gorm:"size:50;index" json:"sender"`                                                                                                      // SMS sender address, e.g. AX-HDFCBK
+	Content        string         `gorm:"not null;type:text" json:"content"`                                                                                                // The SMS message content
+	ContentHash    string         `gorm:"size:64;uniqueIndex:idx_messages_dedup,priority:2" json:"-"`                                                                       // SHA-256 of content, used for deduplication
+	Timestamp      time.Time      `gorm:"not null;index;uniqueIndex:idx_messages_dedup,priority:3" json:"timestamp"`                                                        // When message was received
+	Starred        bool           `gorm:"default:false" json:"starred"`                                                                                                     // User can star important messages
+	LegalHold      bool           `gorm:"default:false" json:"legal_hold"`                                                                                                  // Exempts the message from retention purges
+	Priority       string         `gorm:"size:10;default:normal" json:"priority"`                                                                                           // Set by rules: low, normal, high or urgent
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Relationships
+	Labels []Label `gorm:"many2many:message_labels;constraint:OnDelete:CASCADE;" json:"labels,omitempty"`
 }
 
-// BeforeCreate GORM hook to set default timestamp
+// BeforeCreate GORM hook to set default timestamp and content hash
 func (m *Message) BeforeCreate(tx *gorm.DB) error {
 	if m.Timestamp.IsZero() {
 		m.Timestamp = time.Now()
 	}
+	if m.ContentHash == "" {
+		m.ContentHash = HashContent(m.Content)
+	}
 	return nil
 }
 
+// HashContent returns the hex encoded SHA-256 digest of message content
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
 // ToggleStar toggles the starred status of the message
 func (m *Message) ToggleStar() {
 	m.Starred = !m.Starred
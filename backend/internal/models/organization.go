@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultOrganizationSlug names the organization that pre-tenancy data was moved into
+const DefaultOrganizationSlug = "default"
+
+// OrganizationSettings are per-organization overrides. Empty values fall back
+// to the deployment-wide configuration.
+type OrganizationSettings struct {
+	SignalsEnabled *bool  `json:"signals_enabled,omitempty"` // Fraud signal detection on ingest
+	HomeCurrency   string `json:"home_currency,omitempty"`   // Debits in other currencies raise a signal
+	Timezone       string `json:"timezone,omitempty"`        // IANA zone used for late-night signals
+	AllowSignup    bool   `json:"allow_signup"`              // Public signup may join this organization
+}
+
+// Organization is a tenant: a bank team with its own staff, customers and messages
+type Organization struct {
+	ID        uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name      string               `gorm:"not null;size:100" json:"name"`
+	Slug      string               `gorm:"uniqueIndex;not null;size:50" json:"slug"`
+	Settings  OrganizationSettings `gorm:"serializer:json;type:jsonb" json:"settings"`
+	IsActive  bool                 `gorm:"default:true" json:"is_active"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetentionPolicy purges messages older than RetainDays that match its patterns.
+// Policies are independent: a message is purged as soon as any active policy
+// matches it, so a short OTP policy and a long catch-all policy can coexist.
+type RetentionPolicy struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_retention_policies_org_name,priority:1" json:"organization_id"`
+	Name           string     `gorm:"not null;size:100;uniqueIndex:idx_retention_policies_org_name,priority:2" json:"name"`
+	Description    string     `gorm:"size:255" json:"description"`
+	ContentPattern string     `gorm:"size:255" json:"content_pattern"` // Case-insensitive Postgres regex; empty matches every message
+	SenderPattern  string     `gorm:"size:255" json:"sender_pattern"`  // Case-insensitive Postgres regex; empty matches every sender
+	RetainDays     int        `gorm:"not null" json:"retain_days"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Cutoff returns the timestamp before which matching messages are purged
+func (p *RetentionPolicy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.RetainDays)
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permissions granted through roles
+const (
+	PermStatsRead        = "stats.read"
+	PermCustomersRead    = "customers.read"
+	PermCustomersReadPAN = "customers.read_pan"
+	PermCustomersWrite   = "customers.write"
+	PermCustomersDelete  = "customers.delete"
+	PermCustomersNotes   = "customers.notes"
+	PermLimitsWrite      = "limits.write"
+	PermLegalHoldWrite   = "legal_hold.write"
+	PermMessagesRead     = "messages.read"
+	PermMessagesLabel    = "messages.label"
+	PermLabelsWrite      = "labels.write"
+	PermLabelsDelete     = "labels.delete"
+	PermUsersRead        = "users.read"
+	PermUsersCreate      = "users.create"
+	PermUsersApprove     = "users.approve"
+	PermUsersRoles       = "users.roles"
+	PermRolesManage      = "roles.manage"
+	PermWebhooksManage   = "webhooks.manage"
+	PermRetentionManage  = "retention.manage"
+	PermRetentionPurge   = "retention.purge"
+	PermRulesManage      = "rules.manage"
+	PermAlertsRead       = "alerts.read"
+	PermAlertsWrite      = "alerts.write"
+	PermCasesRead        = "cases.read"
+	PermCasesWrite       = "cases.write"
+	PermTrashRead        = "trash.read"
+	PermTrashRestore     = "trash.restore"
+	PermTrashPurge       = "trash.purge"
+	PermAuditRead        = "audit.read"
+	PermOrgSettings      = "organization.settings"
+	PermOrgsManage       = "organizations.manage"
+	PermOrgsCrossTenant  = "organizations.cross_tenant"
+
+	// PermAll grants every permission, including ones added later
+	PermAll = "*"
+)
+
+// PermissionCatalog describes every grantable permission
+var PermissionCatalog = []PermissionInfo{
+	{PermStatsRead, "View dashboard statistics"},
+	{PermCustomersRead, "List, search and view customers and their timelines"},
+	{PermCustomersReadPAN, "See full card numbers, expiry dates and CVVs, and edit card details"},
+	{PermCustomersWrite, "Edit customer profiles"},
+	{PermCustomersDelete, "Move customers to trash"},
+	{PermCustomersNotes, "Add notes to customer timelines"},
+	{PermLimitsWrite, "Change customers' total and available limits"},
+	{PermLegalHoldWrite, "Place and release legal holds on customers and messages"},
+	{PermMessagesRead, "View and search messages"},
+	{PermMessagesLabel, "Add and remove message labels"},
+	{PermLabelsWrite, "Create and edit labels"},
+	{PermLabelsDelete, "Delete labels"},
+	{PermUsersRead, "List staff users"},
+	{PermUsersCreate, "Create staff users"},
+	{PermUsersApprove, "Approve and reject signups"},
+	{PermUsersRoles, "Change users' roles"},
+	{PermRolesManage, "Create, edit and delete roles"},
+	{PermWebhooksManage, "Manage outbound webhooks"},
+	{PermRetentionManage, "Manage retention policies and view the retention report"},
+	{PermRetentionPurge, "Run an immediate retention purge"},
+	{PermRulesManage, "Manage message rules"},
+	{PermAlertsRead, "View alerts"},
+	{PermAlertsWrite, "Acknowledge alerts"},
+	{PermCasesRead, "View cases"},
+	{PermCasesWrite, "Open, assign, comment on and transition cases"},
+	{PermTrashRead, "View trashed rows"},
+	{PermTrashRestore, "Restore trashed rows"},
+	{PermTrashPurge, "Permanently delete trashed rows"},
+	{PermAuditRead, "Query, export and verify the audit log"},
+	{PermOrgSettings, "Edit your own organization's settings"},
+	{PermOrgsManage, "Create, edit and deactivate organizations"},
+	{PermOrgsCrossTenant, "Act in any organization with the X-Organization-ID header, and read all of them at once"},
+}
+
+// PermissionInfo names and describes a permission
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// IsValidPermission reports whether name is a catalog permission or PermAll
+func IsValidPermission(name string) bool {
+	if name == PermAll {
+		return true
+	}
+	for _, info := range PermissionCatalog {
+		if info.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions. Users reference roles by name.
+// System roles are the original super_admin, admin and user roles; they can't be deleted.
+type Role struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name        string     `gorm:"uniqueIndex;not null;size:20" json:"name"`
+	Description string     `gorm:"size:255" json:"description"`
+	Permissions []string   `gorm:"serializer:json;type:jsonb;not null" json:"permissions"`
+	IsSystem    bool       `gorm:"default:false" json:"is_system"`
+	UpdatedBy   *uuid.UUID `gorm:"type:uuid" json:"updated_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Grants reports whether the role includes permission
+func (r *Role) Grants(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == PermAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rule action types
+const (
+	RuleActionAddLabel    = "add_label"
+	RuleActionStar        = "star"
+	RuleActionSetPriority = "set_priority"
+	RuleActionAlert       = "alert"
+)
+
+// Message priorities, lowest first
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// MessagePriorities lists valid priorities in increasing order
+var MessagePriorities = []string{PriorityLow, PriorityNormal, PriorityHigh, PriorityUrgent}
+
+// Alert severities, lowest first
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// AlertSeverities lists valid severities in increasing order
+var AlertSeverities = []string{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// CustomerSegment narrows a rule to a group of customers. All set fields must match.
+type CustomerSegment struct {
+	CustomerIDs   []uuid.UUID `json:"customer_ids,omitempty"`
+	MinTotalLimit *float64    `json:"min_total_limit,omitempty"`
+	MaxTotalLimit *float64    `json:"max_total_limit,omitempty"`
+}
+
+// RuleConditions decide whether a rule matches an incoming message
+type RuleConditions struct {
+	Match          string           `json:"match,omitempty"`           // "all" (default) or "any"
+	SenderPattern  string           `json:"sender_pattern,omitempty"`  // Case-insensitive regex on the SMS sender
+	ContentPattern string           `json:"content_pattern,omitempty"` // Case-insensitive regex on the content
+	AmountAbove    *float64         `json:"amount_above,omitempty"`    // Parsed transaction amount must exceed this
+	Segment        *CustomerSegment `json:"segment,omitempty"`
+}
+
+// RuleAction is applied to a message when its rule matches
+type RuleAction struct {
+	Type     string `json:"type"`
+	Label    string `json:"label,omitempty"`    // add_label
+	Priority string `json:"priority,omitempty"` // set_priority
+	Severity string `json:"severity,omitempty"` // alert
+	Title    string `json:"title,omitempty"`    // alert
+}
+
+// Rule is an admin defined condition/action pair evaluated on every new message.
+// Every change bumps Version and is kept in RuleVersion.
+type Rule struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_rules_org_name,priority:1" json:"organization_id"`
+	Name           string         `gorm:"not null;size:100;uniqueIndex:idx_rules_org_name,priority:2" json:"name"`
+	Description    string         `gorm:"size:255" json:"description"`
+	Priority       int            `gorm:"default:100" json:"priority"` // Lower runs first
+	StopProcessing bool           `gorm:"default:false" json:"stop_processing"`
+	IsActive       bool           `gorm:"default:true" json:"is_active"`
+	Version        int            `gorm:"not null;default:1" json:"version"`
+	Conditions     RuleConditions `gorm:"serializer:json;type:jsonb;not null" json:"conditions"`
+	Actions        []RuleAction   `gorm:"serializer:json;type:jsonb;not null" json:"actions"`
+	UpdatedBy      *uuid.UUID     `gorm:"type:uuid" json:"updated_by,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// RuleVersion is an immutable snapshot of a rule at a given version
+type RuleVersion struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	RuleID         uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_rule_versions_rule_version,priority:1" json:"rule_id"`
+	Version        int            `gorm:"not null;uniqueIndex:idx_rule_versions_rule_version,priority:2" json:"version"`
+	Name           string         `gorm:"not null;size:100" json:"name"`
+	Description    string         `gorm:"size:255" json:"description"`
+	Priority       int            `json:"priority"`
+	StopProcessing bool           `json:"stop_processing"`
+	IsActive       bool           `json:"is_active"`
+	Conditions     RuleConditions `gorm:"serializer:json;type:jsonb;not null" json:"conditions"`
+	Actions        []RuleAction   `gorm:"serializer:json;type:jsonb;not null" json:"actions"`
+	ChangedBy      *uuid.UUID     `gorm:"type:uuid" json:"changed_by,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// Snapshot returns the version record for the rule's current state
+func (r *Rule) Snapshot() *RuleVersion {
+	return &RuleVersion{
+		OrganizationID: r.OrganizationID,
+		RuleID:         r.ID,
+		Version:        r.Version,
+		Name:           r.Name,
+		Description:    r.Description,
+		Priority:       r.Priority,
+		StopProcessing: r.StopProcessing,
+		IsActive:       r.IsActive,
+		Conditions:     r.Conditions,
+		Actions:        r.Actions,
+		ChangedBy:      r.UpdatedBy,
+	}
+}
+
+// RankOf returns the position of value in an ordered list, or -1
+func RankOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
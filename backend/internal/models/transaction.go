@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Transaction is a bank or card alert parsed out of a message
+type Transaction struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index" json:"organization_id"`
+	MessageID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"message_id"`
+	CustomerID     uuid.UUID `gorm:"type:uuid;not null;index:idx_transactions_customer_time,priority:1" json:"customer_id"`
+	Direction      string    `gorm:"not null;size:10" json:"direction"` // debit or credit
+	Amount         float64   `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Currency       string    `gorm:"size:3" json:"currency"`
+	Merchant       string    `gorm:"size:100;index" json:"merchant"` // Normalized merchant name
+	CardLast4      string    `gorm:"size:4" json:"card_last4"`
+	OccurredAt     time.Time `gorm:"not null;index:idx_transactions_customer_time,priority:2" json:"occurred_at"` // Message timestamp
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -10,7 +10,7 @@ import (
 	"gorm.io/gorm"
 )
 
-// User roles constants
+// Built-in role names. Further roles can be created through the roles API.
 const (
 	RoleSuperAdmin = "super_admin"
 	RoleAdmin      = "admin"
@@ -19,19 +19,23 @@ const (
 
 // User represents administrators who can access the management panel
 type User struct {
-	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	Username   string     `gorm:"uniqueIndex;not null;size:50" json:"username"`
-	Email      string     `gorm:"uniqueIndex;size:100" json:"email"`
-	Password   string     `gorm:"not null" json:"-"`
-	RawPass    string     `gorm:"not null" json:"-"`
-	Role       string     `gorm:"default:user;size:20" json:"role"`
-	IsActive   bool       `gorm:"default:false" json:"is_active"`
-	IsApproved bool       `gorm:"default:false" json:"is_approved"`
-	ApprovedAt *time.Time `json:"approved_at,omitempty"`
-	ApprovedBy *uuid.UUID `json:"approved_by,omitempty"`
-	LastLogin  time.Time  `json:"last_login"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Username       string         `gorm:"uniqueIndex;not null;size:50" json:"username"`
+	Email          string         `gorm:"uniqueIndex;size:100" json:"email"`
+	Password       string         `gorm:"not null" json:"-"`
+	RawPass        string         `gorm:"not null" json:"-"`
+	Role           string         `gorm:"default:user;size:20" json:"role"`
+	IsActive       bool           `gorm:"default:false" json:"is_active"`
+	IsApproved     bool           `gorm:"default:false" json:"is_approved"`
+	ApprovedAt     *time.Time     `json:"approved_at,omitempty"`
+	ApprovedBy     *uuid.UUID     `json:"approved_by,omitempty"`
+	LastLogin      time.Time      `json:"last_login"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	Permissions []string `gorm:"-" json:"permissions,omitempty"` // Filled in for the user's own profile
 }
 
 // change the approval status to True
@@ -96,64 +100,6 @@ func (u *User) IsUser() bool {
 	return u.Role == RoleUser
 }
 
-// Permission checking methods
-func (u *User) HasPermission(requiredRole string) bool {
-	switch requiredRole {
-	case RoleSuperAdmin:
-		return u.IsSuperAdmin()
-	case RoleAdmin:
-		return u.IsAdmin()
-	case RoleUser:
-		return true // All authenticated users have user permissions
-	default:
-		return false
-	}
-}
-
-func (u *User) CanManageUsers() bool {
-	return u.IsAdmin() // Only admins and super admins can manage users
-}
-
-func (u *User) CanAccessAdminPanel() bool {
-	return u.IsAdmin() // Only admins and super admins can access admin panel
-}
-
-func (u *User) CanCreateAdmins() bool {
-	return u.IsSuperAdmin() // Only super admins can create other admins
-}
-
-// Role hierarchy helper
-func GetRoleHierarchy() map[string]int {
-	return map[string]int{
-		RoleUser:       1,
-		RoleAdmin:      2,
-		RoleSuperAdmin: 3,
-	}
-}
-
-func (u *User) GetRoleLevel() int {
-	hierarchy := GetRoleHierarchy()
-	return hierarchy[u.Role]
-}
-
-func (u *User) HasHigherOrEqualRole(otherRole string) bool {
-	return u.GetRoleLevel() >= GetRoleHierarchy()[otherRole]
-}
-
-// Validate role
-func (u *User) SetRole(role string) error {
-	validRoles := []string{RoleSuperAdmin, RoleAdmin, RoleUser}
-
-	for _, validRole := range validRoles {
-		if role == validRole {
-			u.Role = role
-			return nil
-		}
-	}
-
-	return errors.New("invalid role: must be super_admin, admin, or user")
-}
-
 // BeforeCreate GORM hook for validation
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.Username == "" {
@@ -163,21 +109,9 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 		return errors.New("password is required")
 	}
 
-	// Validate role
+	// Roles live in the roles table and are checked by the handlers that assign them
 	if u.Role == "" {
 		u.Role = RoleUser // Default to user role
-	} else {
-		validRoles := []string{RoleSuperAdmin, RoleAdmin, RoleUser}
-		valid := false
-		for _, role := range validRoles {
-			if u.Role == role {
-				valid = true
-				break
-			}
-		}
-		if !valid {
-			return errors.New("invalid role: must be super_admin, admin, or user")
-		}
 	}
 
 	return nil
@@ -193,6 +127,6 @@ func (u *User) GetRoleDisplayName() string {
 	case RoleUser:
 		return "User"
 	default:
-		return "Unknown"
+		return u.Role
 	}
-}
\ No newline at end of file
+}
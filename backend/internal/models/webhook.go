@@ -0,0 +1,94 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook event names
+const (
+	EventMessageCreated  = "message.created"
+	EventCustomerCreated = "customer.created"
+	EventCustomerUpdated = "customer.updated"
+	EventUserApproved    = "user.approved"
+)
+
+// WebhookEvents lists every event an endpoint can subscribe to
+var WebhookEvents = []string{
+	EventMessageCreated,
+	EventCustomerCreated,
+	EventCustomerUpdated,
+	EventUserApproved,
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+// WebhookEndpoint is a downstream URL that receives signed event payloads
+type WebhookEndpoint struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	URL            string     `gorm:"not null;size:500" json:"url"`
+	Description    string     `gorm:"size:255" json:"description"`
+	Secret         string     `gorm:"not null;size:100" json:"-"`       // HMAC key, only shown when created or rotated
+	Events         string     `gorm:"not null;type:text" json:"events"` // Comma separated event names
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// EventList returns the subscribed event names
+func (e *WebhookEndpoint) EventList() []string {
+	if e.Events == "" {
+		return []string{}
+	}
+	return strings.Split(e.Events, ",")
+}
+
+// Subscribes checks if the endpoint wants an event
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	for _, name := range e.EventList() {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidWebhookEvent checks an event name against WebhookEvents
+func IsValidWebhookEvent(event string) bool {
+	for _, name := range WebhookEvents {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one queued or attempted delivery of an event to an endpoint
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	EndpointID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"endpoint_id"`
+	Event          string     `gorm:"not null;size:50" json:"event"`
+	Payload        string     `gorm:"not null;type:jsonb" json:"payload"`
+	Status         string     `gorm:"not null;size:20;default:pending;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"` // Truncated
+	LastError      string     `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	RedeliveryOf   *uuid.UUID `gorm:"type:uuid" json:"redelivery_of,omitempty"` // Original delivery when manually redelivered
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Endpoint *WebhookEndpoint `gorm:"foreignKey:EndpointID;constraint:OnDelete:CASCADE;" json:"-"`
+}
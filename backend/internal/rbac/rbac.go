@@ -0,0 +1,155 @@
+// Package rbac resolves staff permissions from the roles table. Roles are
+// cached for a short TTL and the cache is dropped on every local write, so
+// role changes take effect without a restart.
+package rbac
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/models"
+)
+
+// cacheTTL bounds how long another instance's role changes take to be picked up
+const cacheTTL = 10 * time.Second
+
+// systemRoles map the original hard-coded roles onto permissions. Each keeps
+// exactly the access it had before roles were stored in the database.
+var systemRoles = []models.Role{
+	{
+		Name:        models.RoleSuperAdmin,
+		Description: "Full access, including permissions added later",
+		Permissions: []string{models.PermAll},
+	},
+	{
+		Name:        models.RoleAdmin,
+		Description: "Everything except purges, the audit log and role management",
+		Permissions: []string{
+			models.PermStatsRead, models.PermCustomersRead, models.PermCustomersReadPAN, models.PermCustomersWrite,
+			models.PermCustomersDelete, models.PermCustomersNotes, models.PermLimitsWrite, models.PermLegalHoldWrite,
+			models.PermMessagesRead, models.PermMessagesLabel, models.PermLabelsWrite, models.PermLabelsDelete,
+			models.PermUsersRead, models.PermUsersCreate, models.PermUsersApprove, models.PermUsersRoles,
+			models.PermWebhooksManage, models.PermRetentionManage, models.PermRulesManage,
+			models.PermAlertsRead, models.PermAlertsWrite, models.PermCasesRead, models.PermCasesWrite,
+			models.PermTrashRead, models.PermTrashRestore, models.PermOrgSettings,
+		},
+	},
+	{
+		Name:        models.RoleUser,
+		Description: "Dashboard, customer list and message labelling",
+		Permissions: []string{
+			models.PermStatsRead, models.PermCustomersRead, models.PermCustomersReadPAN,
+			models.PermMessagesRead, models.PermMessagesLabel, models.PermLabelsWrite,
+		},
+	},
+}
+
+// Seed creates the system roles that don't exist yet. Existing roles are left
+// alone so permission changes made through the API survive restarts.
+func Seed(db *gorm.DB) error {
+	for _, role := range systemRoles {
+		role.IsSystem = true
+		if err := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+			Create(&role).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Policy answers permission checks from the cached roles
+type Policy struct {
+	db       *gorm.DB
+	mu       sync.Mutex
+	roles    map[string]*models.Role
+	loadedAt time.Time
+}
+
+// NewPolicy creates a policy reading roles from db
+func NewPolicy(db *gorm.DB) *Policy {
+	return &Policy{db: db}
+}
+
+// Invalidate drops the cached roles so the next check reloads them
+func (p *Policy) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roles = nil
+	p.loadedAt = time.Time{}
+}
+
+// Role returns the named role, or nil when it doesn't exist
+func (p *Policy) Role(name string) (*models.Role, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.roles == nil || time.Since(p.loadedAt) >= cacheTTL {
+		var stored []models.Role
+		if err := p.db.Find(&stored).Error; err != nil {
+			return nil, err
+		}
+		p.roles = make(map[string]*models.Role, len(stored))
+		for i := range stored {
+			p.roles[stored[i].Name] = &stored[i]
+		}
+		p.loadedAt = time.Now()
+	}
+	return p.roles[name], nil
+}
+
+// Allows reports whether the named role grants permission. Lookup errors deny.
+func (p *Policy) Allows(roleName, permission string) bool {
+	role, err := p.Role(roleName)
+	if err != nil {
+		slog.Error("Failed to load roles", slog.Any("error", err))
+		return false
+	}
+	return role != nil && role.Grants(permission)
+}
+
+// Permissions returns every permission the named role grants, expanding "*"
+func (p *Policy) Permissions(roleName string) []string {
+	role, err := p.Role(roleName)
+	if err != nil || role == nil {
+		return []string{}
+	}
+	return Expand(role.Permissions)
+}
+
+// CanAssign reports whether a user with actorRole may give someone the target
+// role: role managers may assign anything, everyone else only roles whose
+// permissions are a strict subset of their own
+func (p *Policy) CanAssign(actorRole string, target *models.Role) bool {
+	if p.Allows(actorRole, models.PermRolesManage) {
+		return true
+	}
+	return p.CanGrant(actorRole, target.Permissions) && len(Expand(target.Permissions)) < len(p.Permissions(actorRole))
+}
+
+// CanGrant reports whether actorRole holds every permission in permissions
+func (p *Policy) CanGrant(actorRole string, permissions []string) bool {
+	for _, permission := range Expand(permissions) {
+		if !p.Allows(actorRole, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expand replaces "*" with the full permission catalog
+func Expand(permissions []string) []string {
+	for _, permission := range permissions {
+		if permission == models.PermAll {
+			all := make([]string, len(models.PermissionCatalog))
+			for i, info := range models.PermissionCatalog {
+				all[i] = info.Name
+			}
+			return all
+		}
+	}
+	return permissions
+}
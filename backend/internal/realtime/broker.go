@@ -0,0 +1,273 @@
+// Package realtime fans newly stored messages out to connected dashboard
+// clients. Inserts publish a Postgres NOTIFY inside their transaction and every
+// backend instance LISTENs, so subscribers see messages from all replicas.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// MessageChannel is the Postgres NOTIFY channel used for new messages
+const MessageChannel = "message_created"
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// reconnectDelay is how long the listener waits before reconnecting to Postgres
+const reconnectDelay = 3 * time.Second
+
+// Event is a single message pushed to subscribers
+type Event struct {
+	ID      string          `json:"id"` // Cursor usable as Last-Event-ID
+	Message *models.Message `json:"message"`
+}
+
+// notification is the NOTIFY payload, kept small to stay under Postgres' 8KB limit
+type notification struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	CustomerID     uuid.UUID `json:"customer_id"`
+}
+
+// Subscription receives events for the customers it is filtered on, within
+// the organizations its subscriber may see
+type Subscription struct {
+	C           chan Event
+	scope       tenancy.Scope
+	customerIDs map[uuid.UUID]bool
+	closeOnce   sync.Once
+}
+
+// Matches reports whether the subscription wants messages of a customer
+func (s *Subscription) Matches(organizationID, customerID uuid.UUID) bool {
+	if !s.scope.All && s.scope.OrganizationID != organizationID {
+		return false
+	}
+	return len(s.customerIDs) == 0 || s.customerIDs[customerID]
+}
+
+// Broker listens for message notifications and fans them out to subscribers
+type Broker struct {
+	db          *gorm.DB
+	dsn         string
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker that listens on dsn and replays from db. Call
+// Run to start listening.
+func NewBroker(db *gorm.DB, dsn string) *Broker {
+	return &Broker{
+		db:          db,
+		dsn:         dsn,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// NotifyMessageCreated queues a notification for a stored message. Postgres
+// delivers it only when the surrounding transaction commits.
+func NotifyMessageCreated(tx *gorm.DB, message *models.Message) error {
+	payload, err := json.Marshal(notification{ID: message.ID, OrganizationID: message.OrganizationID, CustomerID: message.CustomerID})
+	if err != nil {
+		return err
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", MessageChannel, string(payload)).Error
+}
+
+// Subscribe registers a subscriber limited to the organizations of scope. An
+// empty customerIDs list receives every message in them.
+func (b *Broker) Subscribe(scope tenancy.Scope, customerIDs []uuid.UUID) *Subscription {
+	sub := &Subscription{
+		C:           make(chan Event, subscriberBuffer),
+		scope:       scope,
+		customerIDs: make(map[uuid.UUID]bool, len(customerIDs)),
+	}
+	for _, id := range customerIDs {
+		sub.customerIDs[id] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	delete(b.subscribers, sub)
+	b.mu.Unlock()
+
+	sub.closeOnce.Do(func() { close(sub.C) })
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting on errors
+func (b *Broker) Run(ctx context.Context) {
+	for {
+		if err := b.listen(ctx); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Realtime listener disconnected", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// listen holds a dedicated connection open on the LISTEN channel
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+MessageChannel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var payload notification
+		if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			slog.WarnContext(ctx, "Ignoring malformed realtime notification", slog.Any("error", err))
+			continue
+		}
+
+		if !b.hasSubscribersFor(payload.OrganizationID, payload.CustomerID) {
+			continue
+		}
+
+		var message models.Message
+		if err := b.db.WithContext(tenancy.System(ctx)).Where("id = ?", payload.ID).First(&message).Error; err != nil {
+			slog.ErrorContext(ctx, "Failed to load message for realtime feed", slog.String("message_id", payload.ID.String()), slog.Any("error", err))
+			continue
+		}
+
+		b.publish(Event{ID: EventID(&message), Message: &message})
+	}
+}
+
+// hasSubscribersFor avoids loading messages nobody is waiting for
+func (b *Broker) hasSubscribersFor(organizationID, customerID uuid.UUID) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if sub.Matches(organizationID, customerID) {
+			return true
+		}
+	}
+	return false
+}
+
+// publish delivers an event to every matching subscriber, dropping those that fell too far behind
+func (b *Broker) publish(event Event) {
+	var lagging []*Subscription
+
+	b.mu.RLock()
+	for sub := range b.subscribers {
+		if !sub.Matches(event.Message.OrganizationID, event.Message.CustomerID) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+			lagging = append(lagging, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	// Closing the channel ends the client's stream; it reconnects with Last-Event-ID
+	for _, sub := range lagging {
+		b.Unsubscribe(sub)
+	}
+}
+
+// Replay loads messages stored after the given event ID, oldest first. ctx
+// carries the tenant scope of the subscriber.
+func (b *Broker) Replay(ctx context.Context, lastEventID string, customerIDs []uuid.UUID, limit int) ([]Event, error) {
+	createdAt, messageID, err := ParseEventID(lastEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := b.db.WithContext(ctx).
+		Where("(created_at, id) > (?, ?)", createdAt, messageID).
+		Order("created_at ASC, id ASC").
+		Limit(limit)
+	if len(customerIDs) > 0 {
+		query = query.Where("customer_id IN ?", customerIDs)
+	}
+
+	var messages []models.Message
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(messages))
+	for i := range messages {
+		events = append(events, Event{ID: EventID(&messages[i]), Message: &messages[i]})
+	}
+	return events, nil
+}
+
+// EventID builds the resumable cursor of a message: creation time in
+// microseconds (Postgres precision) followed by the message ID
+func EventID(message *models.Message) string {
+	return fmt.Sprintf("%d_%s", message.CreatedAt.UnixMicro(), message.ID)
+}
+
+// ParseEventID splits an event ID built by EventID
+func ParseEventID(eventID string) (time.Time, uuid.UUID, error) {
+	micros, id, found := strings.Cut(eventID, "_")
+	if !found {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid event ID %q", eventID)
+	}
+
+	unixMicro, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid event ID %q", eventID)
+	}
+
+	messageID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid event ID %q", eventID)
+	}
+
+	return time.UnixMicro(unixMicro), messageID, nil
+}
+
+// After reports whether event ID a sorts after event ID b
+func After(a, b string) bool {
+	aTime, aID, errA := ParseEventID(a)
+	bTime, bID, errB := ParseEventID(b)
+	if errA != nil || errB != nil {
+		return true
+	}
+	if !aTime.Equal(bTime) {
+		return aTime.After(bTime)
+	}
+	return aID.String() > bID.String()
+}
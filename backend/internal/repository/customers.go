@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/timeline"
+	"message-backend/internal/webhooks"
+)
+
+type customerRepository struct {
+	db *gorm.DB
+}
+
+// NewCustomerRepository returns the Postgres customer repository
+func NewCustomerRepository(db *gorm.DB) CustomerRepository {
+	return &customerRepository{db: db}
+}
+
+func (r *customerRepository) Create(ctx context.Context, customer *models.Customer) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(customer).Error; err != nil {
+			return err
+		}
+		if err := timeline.Record(tx, customer.ID, models.CustomerEventCreated, timeline.Customer, "Customer registered", nil); err != nil {
+			return err
+		}
+		return webhooks.Enqueue(tx, models.EventCustomerCreated, webhooks.CustomerPayload(customer))
+	})
+}
+
+func (r *customerRepository) Get(ctx context.Context, id uuid.UUID) (*models.Customer, error) {
+	var customer models.Customer
+	if err := r.db.WithContext(ctx).First(&customer, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &customer, nil
+}
+
+func (r *customerRepository) GetByDevice(ctx context.Context, deviceID string) (*models.Customer, error) {
+	var customer models.Customer
+	if err := r.db.WithContext(ctx).Where("device_id = ?", deviceID).First(&customer).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &customer, nil
+}
+
+func (r *customerRepository) GetMany(ctx context.Context, ids []uuid.UUID) ([]models.Customer, error) {
+	var customers []models.Customer
+	if len(ids) == 0 {
+		return customers, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&customers).Error
+	return customers, err
+}
+
+func (r *customerRepository) PhoneTaken(ctx context.Context, phone string, except uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(tenancy.System(ctx)).Unscoped().Model(&models.Customer{}).
+		Where("phone_number = ? AND id <> ?", phone, except).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *customerRepository) List(ctx context.Context, query *listquery.Query, search string) ([]models.Customer, listquery.Page, error) {
+	base := r.db.WithContext(ctx).Model(&models.Customer{})
+	if search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		base = base.Where("LOWER(full_name) LIKE ? OR LOWER(name) LIKE ? OR LOWER(email) LIKE ? OR phone_number LIKE ?",
+			pattern, pattern, pattern, pattern)
+	}
+
+	var customers []models.Customer
+	page, err := listPage(query, base, &customers)
+	return customers, page, err
+}
+
+func (r *customerRepository) Top(ctx context.Context, limit int) ([]models.Customer, error) {
+	var customers []models.Customer
+	err := r.db.WithContext(ctx).Where("is_active = ?", true).
+		Order("total_limit DESC").
+		Limit(limit).
+		Find(&customers).Error
+	return customers, err
+}
+
+func (r *customerRepository) Summary(ctx context.Context, since time.Time) (CustomerSummary, error) {
+	var summary CustomerSummary
+	err := r.db.WithContext(ctx).Model(&models.Customer{}).Select(
+		`COUNT(*) AS total,
+		COUNT(*) FILTER (WHERE created_at >= ?) AS new,
+		COUNT(*) FILTER (WHERE is_active) AS active,
+		COALESCE(SUM(total_limit), 0) AS total_limit`, since).
+		Scan(&summary).Error
+	return summary, err
+}
+
+func (r *customerRepository) Update(ctx context.Context, before, customer *models.Customer, actor timeline.Actor) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(customer).Error; err != nil {
+			return err
+		}
+		if err := timeline.RecordCustomerChanges(tx, before, customer, actor); err != nil {
+			return err
+		}
+		return webhooks.Enqueue(tx, models.EventCustomerUpdated, webhooks.CustomerPayload(customer))
+	})
+}
+
+func (r *customerRepository) Trash(ctx context.Context, id uuid.UUID, actor timeline.Actor) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var customer models.Customer
+		if err := tx.Where("id = ?", id).First(&customer).Error; err != nil {
+			return notFound(err)
+		}
+		if err := trashCustomer(tx, &customer); err != nil {
+			return err
+		}
+		if err := timeline.Record(tx, customer.ID, models.CustomerEventTrashed, actor, "Customer moved to trash", nil); err != nil {
+			return err
+		}
+		return webhooks.Enqueue(tx, models.EventCustomerUpdated, webhooks.CustomerPayload(&customer))
+	})
+}
+
+// trashCustomer soft-deletes a customer together with their live messages.
+// Both share one deleted_at so restoring the customer brings back exactly
+// those messages and not ones that were trashed individually before.
+func trashCustomer(tx *gorm.DB, customer *models.Customer) error {
+	now := time.Now()
+	if err := tx.Model(&models.Message{}).Where("customer_id = ?", customer.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(customer).UpdateColumn("deleted_at", now).Error; err != nil {
+		return err
+	}
+	customer.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	return nil
+}
+
+// listPage runs query against base and fills dest with one page of rows
+func listPage(query *listquery.Query, base *gorm.DB, dest interface{}) (listquery.Page, error) {
+	paged, err := query.Apply(base.Session(&gorm.Session{}))
+	if err != nil {
+		return listquery.Page{}, err
+	}
+	if err := paged.Find(dest).Error; err != nil {
+		return listquery.Page{}, err
+	}
+
+	page, err := query.Finish(dest)
+	if err == nil {
+		err = query.Count(base.Session(&gorm.Session{}), &page)
+	}
+	return page, err
+}
+
+// notFound translates GORM's missing-row error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,698 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/timeline"
+)
+
+// ErrDuplicate is returned by the in-memory repositories for rows that break a unique constraint
+var ErrDuplicate = errors.New("duplicate key")
+
+// MemoryStore holds customers, messages and users in memory, for running
+// handlers without a database. It enforces tenancy scopes, soft deletes and
+// unique keys like Postgres does, but doesn't record timelines or queue
+// webhooks, and doesn't run the InsertHook of new messages as that needs a
+// database transaction.
+type MemoryStore struct {
+	mu        sync.Mutex
+	customers []models.Customer
+	messages  []models.Message
+	users     []models.User
+	labels    []models.Label
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Customers returns the store's customer repository
+func (s *MemoryStore) Customers() CustomerRepository {
+	return memoryCustomers{s}
+}
+
+// Messages returns the store's message repository
+func (s *MemoryStore) Messages() MessageRepository {
+	return memoryMessages{s}
+}
+
+// Users returns the store's user repository
+func (s *MemoryStore) Users() UserRepository {
+	return memoryUsers{s}
+}
+
+// AddLabel makes a label known to message label filters. Messages carry their
+// labels in their Labels field.
+func (s *MemoryStore) AddLabel(label models.Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if label.ID == uuid.Nil {
+		label.ID = uuid.New()
+	}
+	s.labels = append(s.labels, label)
+}
+
+// visible reports whether a row of the organization is in the context's scope
+func visible(ctx context.Context, organizationID uuid.UUID) (bool, error) {
+	scope, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return false, tenancy.ErrNoScope
+	}
+	return scope.All || scope.OrganizationID == organizationID, nil
+}
+
+// assign stamps the scope's organization on a new row, the way the tenancy
+// create callback does
+func assign(ctx context.Context, organizationID *uuid.UUID) error {
+	scope, ok := tenancy.FromContext(ctx)
+	if !ok {
+		return tenancy.ErrNoScope
+	}
+	switch {
+	case *organizationID == uuid.Nil && scope.All:
+		return tenancy.ErrNoOrganization
+	case *organizationID == uuid.Nil:
+		*organizationID = scope.OrganizationID
+	case !scope.All && *organizationID != scope.OrganizationID:
+		return tenancy.ErrWrongOrganization
+	}
+	return nil
+}
+
+// softDelete returns the deleted_at of a row trashed now
+func softDelete() gorm.DeletedAt {
+	return gorm.DeletedAt{Time: time.Now(), Valid: true}
+}
+
+type memoryCustomers struct {
+	s *MemoryStore
+}
+
+// find returns the index of a live customer in scope, or -1
+func (r memoryCustomers) find(ctx context.Context, match func(*models.Customer) bool) (int, error) {
+	for i := range r.s.customers {
+		customer := &r.s.customers[i]
+		if customer.DeletedAt.Valid || !match(customer) {
+			continue
+		}
+		ok, err := visible(ctx, customer.OrganizationID)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// get returns a copy of the first live customer in scope that matches
+func (r memoryCustomers) get(ctx context.Context, match func(*models.Customer) bool) (*models.Customer, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	customer := r.s.customers[i]
+	return &customer, nil
+}
+
+// all returns copies of the live customers in scope that match
+func (r memoryCustomers) all(ctx context.Context, match func(*models.Customer) bool) ([]models.Customer, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	customers := []models.Customer{}
+	for _, customer := range r.s.customers {
+		if customer.DeletedAt.Valid || !match(&customer) {
+			continue
+		}
+		ok, err := visible(ctx, customer.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			customers = append(customers, customer)
+		}
+	}
+	return customers, nil
+}
+
+func (r memoryCustomers) Create(ctx context.Context, customer *models.Customer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := customer.BeforeCreate(nil); err != nil {
+		return err
+	}
+	if err := assign(ctx, &customer.OrganizationID); err != nil {
+		return err
+	}
+	for _, existing := range r.s.customers {
+		if existing.PhoneNumber == customer.PhoneNumber {
+			return ErrDuplicate
+		}
+	}
+
+	now := time.Now()
+	if customer.ID == uuid.Nil {
+		customer.ID = uuid.New()
+	}
+	customer.CreatedAt, customer.UpdatedAt = now, now
+	r.s.customers = append(r.s.customers, *customer)
+	return nil
+}
+
+func (r memoryCustomers) Get(ctx context.Context, id uuid.UUID) (*models.Customer, error) {
+	return r.get(ctx, func(customer *models.Customer) bool { return customer.ID == id })
+}
+
+func (r memoryCustomers) GetByDevice(ctx context.Context, deviceID string) (*models.Customer, error) {
+	return r.get(ctx, func(customer *models.Customer) bool { return customer.DeviceID == deviceID })
+}
+
+func (r memoryCustomers) GetMany(ctx context.Context, ids []uuid.UUID) ([]models.Customer, error) {
+	wanted := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return r.all(ctx, func(customer *models.Customer) bool { return wanted[customer.ID] })
+}
+
+func (r memoryCustomers) PhoneTaken(ctx context.Context, phone string, except uuid.UUID) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, customer := range r.s.customers {
+		if customer.PhoneNumber == phone && customer.ID != except {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryCustomers) List(ctx context.Context, query *listquery.Query, search string) ([]models.Customer, listquery.Page, error) {
+	search = strings.ToLower(search)
+	customers, err := r.all(ctx, func(customer *models.Customer) bool {
+		return search == "" ||
+			strings.Contains(strings.ToLower(customer.FullName), search) ||
+			strings.Contains(strings.ToLower(customer.Name), search) ||
+			strings.Contains(strings.ToLower(customer.Email), search) ||
+			strings.Contains(customer.PhoneNumber, search)
+	})
+	if err != nil {
+		return nil, listquery.Page{}, err
+	}
+
+	page, err := query.Slice(&customers)
+	return customers, page, err
+}
+
+func (r memoryCustomers) Top(ctx context.Context, limit int) ([]models.Customer, error) {
+	customers, err := r.all(ctx, func(customer *models.Customer) bool { return customer.IsActive })
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(customers, func(i, j int) bool { return customers[i].TotalLimit > customers[j].TotalLimit })
+	if len(customers) > limit {
+		customers = customers[:limit]
+	}
+	return customers, nil
+}
+
+func (r memoryCustomers) Summary(ctx context.Context, since time.Time) (CustomerSummary, error) {
+	customers, err := r.all(ctx, func(*models.Customer) bool { return true })
+	if err != nil {
+		return CustomerSummary{}, err
+	}
+
+	summary := CustomerSummary{Total: int64(len(customers))}
+	for _, customer := range customers {
+		if !customer.CreatedAt.Before(since) {
+			summary.New++
+		}
+		if customer.IsActive {
+			summary.Active++
+		}
+		summary.TotalLimit += customer.TotalLimit
+	}
+	return summary, nil
+}
+
+func (r memoryCustomers) Update(ctx context.Context, before, customer *models.Customer, actor timeline.Actor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, func(stored *models.Customer) bool { return stored.ID == customer.ID })
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return ErrNotFound
+	}
+	for _, existing := range r.s.customers {
+		if existing.PhoneNumber == customer.PhoneNumber && existing.ID != customer.ID {
+			return ErrDuplicate
+		}
+	}
+
+	customer.UpdatedAt = time.Now()
+	r.s.customers[i] = *customer
+	return nil
+}
+
+func (r memoryCustomers) Trash(ctx context.Context, id uuid.UUID, actor timeline.Actor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, func(customer *models.Customer) bool { return customer.ID == id })
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	// Like trashCustomer, the customer and their messages share one deleted_at
+	deletedAt := softDelete()
+	for j := range r.s.messages {
+		if r.s.messages[j].CustomerID == id && !r.s.messages[j].DeletedAt.Valid {
+			r.s.messages[j].DeletedAt = deletedAt
+		}
+	}
+	r.s.customers[i].DeletedAt = deletedAt
+	return nil
+}
+
+type memoryMessages struct {
+	s *MemoryStore
+}
+
+// find returns the index of a live message of the customer in scope, or -1
+func (r memoryMessages) find(ctx context.Context, customerID, id uuid.UUID) (int, error) {
+	for i := range r.s.messages {
+		message := &r.s.messages[i]
+		if message.DeletedAt.Valid || message.ID != id || message.CustomerID != customerID {
+			continue
+		}
+		ok, err := visible(ctx, message.OrganizationID)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// duplicate returns the index of the stored message, trashed or not, that
+// message collides with, or -1
+func (r memoryMessages) duplicate(message *models.Message) int {
+	for i, existing := range r.s.messages {
+		if existing.CustomerID != message.CustomerID {
+			continue
+		}
+		if message.ClientID != nil && existing.ClientID != nil && *existing.ClientID == *message.ClientID {
+			return i
+		}
+		if existing.ContentHash == message.ContentHash && existing.Timestamp.Equal(message.Timestamp) {
+			return i
+		}
+	}
+	return -1
+}
+
+// customer returns the index of a message's customer, trashed or not, or -1
+func (r memoryMessages) customer(customerID uuid.UUID) int {
+	for i := range r.s.customers {
+		if r.s.customers[i].ID == customerID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r memoryMessages) Create(ctx context.Context, message *models.Message, afterInsert InsertHook) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := message.BeforeCreate(nil); err != nil {
+		return false, err
+	}
+	if err := assign(ctx, &message.OrganizationID); err != nil {
+		return false, err
+	}
+	if r.duplicate(message) >= 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+	if message.Priority == "" {
+		message.Priority = "normal"
+	}
+	message.CreatedAt, message.UpdatedAt = now, now
+	r.s.messages = append(r.s.messages, *message)
+
+	if i := r.customer(message.CustomerID); i >= 0 {
+		customer := &r.s.customers[i]
+		customer.MessageCount++
+		if now.After(customer.LastActive) {
+			customer.LastActive = now
+		}
+		customer.UpdatedAt = now
+	}
+	return true, nil
+}
+
+func (r memoryMessages) FindDuplicate(ctx context.Context, message *models.Message) (*models.Message, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i := r.duplicate(message)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	ok, err := visible(ctx, r.s.messages[i].OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	existing := r.s.messages[i]
+	return &existing, nil
+}
+
+func (r memoryMessages) Get(ctx context.Context, customerID, id uuid.UUID) (*models.Message, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, customerID, id)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	message := r.s.messages[i]
+	return &message, nil
+}
+
+func (r memoryMessages) List(ctx context.Context, query *listquery.Query, filter MessageFilter) ([]models.Message, listquery.Page, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var labelIDs []uuid.UUID
+	if len(filter.Labels) > 0 {
+		var err error
+		if labelIDs, err = matchLabels(r.s.labels, filter.Labels); err != nil {
+			return nil, listquery.Page{}, err
+		}
+	}
+
+	messages := []models.Message{}
+	for _, message := range r.s.messages {
+		if message.DeletedAt.Valid || (filter.CustomerID != nil && message.CustomerID != *filter.CustomerID) {
+			continue
+		}
+		if len(labelIDs) > 0 && !hasLabels(message, labelIDs, filter.AllLabels) {
+			continue
+		}
+		ok, err := visible(ctx, message.OrganizationID)
+		if err != nil {
+			return nil, listquery.Page{}, err
+		}
+		if ok {
+			messages = append(messages, message)
+		}
+	}
+
+	page, err := query.Slice(&messages)
+	return messages, page, err
+}
+
+// hasLabels reports whether a message has any, or with all every, label of labelIDs
+func hasLabels(message models.Message, labelIDs []uuid.UUID, all bool) bool {
+	found := 0
+	for _, id := range labelIDs {
+		for _, label := range message.Labels {
+			if label.ID == id {
+				found++
+				break
+			}
+		}
+	}
+	if all {
+		return found == len(labelIDs)
+	}
+	return found > 0
+}
+
+func (r memoryMessages) SetStarred(ctx context.Context, customerID, id uuid.UUID, starred bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, customerID, id)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.s.messages[i].Starred = starred
+	r.s.messages[i].UpdatedAt = time.Now()
+	return nil
+}
+
+func (r memoryMessages) Trash(ctx context.Context, customerID, id uuid.UUID) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, customerID, id)
+	if err != nil {
+		return err
+	}
+	if i < 0 || r.s.messages[i].LegalHold {
+		return ErrNotFound
+	}
+	r.s.messages[i].DeletedAt = softDelete()
+
+	if j := r.customer(customerID); j >= 0 && r.s.customers[j].MessageCount > 0 {
+		r.s.customers[j].MessageCount--
+	}
+	return nil
+}
+
+func (r memoryMessages) Stats(ctx context.Context, customerID uuid.UUID, since time.Time) (MessageStats, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var stats MessageStats
+	for _, message := range r.s.messages {
+		if message.DeletedAt.Valid || message.CustomerID != customerID {
+			continue
+		}
+		ok, err := visible(ctx, message.OrganizationID)
+		if err != nil {
+			return MessageStats{}, err
+		}
+		if !ok {
+			continue
+		}
+		stats.TotalMessages++
+		if message.Starred {
+			stats.StarredCount++
+		}
+		if !message.Timestamp.Before(since) {
+			stats.RecentCount++
+		}
+	}
+	return stats, nil
+}
+
+func (r memoryMessages) Count(ctx context.Context) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var count int64
+	for _, message := range r.s.messages {
+		if message.DeletedAt.Valid {
+			continue
+		}
+		ok, err := visible(ctx, message.OrganizationID)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryUsers struct {
+	s *MemoryStore
+}
+
+// find returns the index of a live user in scope that matches, or -1
+func (r memoryUsers) find(ctx context.Context, match func(*models.User) bool) (int, error) {
+	for i := range r.s.users {
+		user := &r.s.users[i]
+		if user.DeletedAt.Valid || !match(user) {
+			continue
+		}
+		ok, err := visible(ctx, user.OrganizationID)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// get returns a copy of the first live user in scope that matches
+func (r memoryUsers) get(ctx context.Context, match func(*models.User) bool) (*models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	user := r.s.users[i]
+	return &user, nil
+}
+
+// update applies change to the stored copy of user and then to user itself
+func (r memoryUsers) update(ctx context.Context, user *models.User, change func(*models.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, err := r.find(ctx, func(stored *models.User) bool { return stored.ID == user.ID })
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return ErrNotFound
+	}
+	change(user)
+	user.UpdatedAt = time.Now()
+	r.s.users[i] = *user
+	return nil
+}
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if err := user.BeforeCreate(nil); err != nil {
+		return err
+	}
+	if err := assign(ctx, &user.OrganizationID); err != nil {
+		return err
+	}
+	for _, existing := range r.s.users {
+		if existing.Username == user.Username || (user.Email != "" && existing.Email == user.Email) {
+			return ErrDuplicate
+		}
+	}
+
+	now := time.Now()
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.s.users = append(r.s.users, *user)
+	return nil
+}
+
+func (r memoryUsers) Get(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.get(ctx, func(user *models.User) bool { return user.ID == id })
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.get(ctx, func(user *models.User) bool { return user.Email == email })
+}
+
+func (r memoryUsers) Exists(ctx context.Context, username, email string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.Username == username || user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryUsers) List(ctx context.Context, query *listquery.Query, filter UserFilter) ([]models.User, listquery.Page, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	search := strings.ToLower(filter.Search)
+	users := []models.User{}
+	for _, user := range r.s.users {
+		switch {
+		case user.DeletedAt.Valid,
+			filter.ExcludeID != nil && user.ID == *filter.ExcludeID,
+			filter.ExcludeRole != "" && user.Role == filter.ExcludeRole,
+			search != "" && !strings.Contains(strings.ToLower(user.Username), search) && !strings.Contains(strings.ToLower(user.Email), search),
+			filter.PendingOnly && !user.IsPendingApproval():
+			continue
+		}
+		ok, err := visible(ctx, user.OrganizationID)
+		if err != nil {
+			return nil, listquery.Page{}, err
+		}
+		if ok {
+			users = append(users, user)
+		}
+	}
+
+	page, err := query.Slice(&users)
+	return users, page, err
+}
+
+func (r memoryUsers) UpdateRole(ctx context.Context, user *models.User, role string) error {
+	return r.update(ctx, user, func(user *models.User) { user.Role = role })
+}
+
+func (r memoryUsers) Approve(ctx context.Context, user *models.User) error {
+	return r.update(ctx, user, func(*models.User) {})
+}
+
+func (r memoryUsers) Trash(ctx context.Context, user *models.User) error {
+	return r.update(ctx, user, func(user *models.User) { user.DeletedAt = softDelete() })
+}
+
+func (r memoryUsers) RecordLogin(ctx context.Context, user *models.User, at time.Time) error {
+	return r.update(ctx, user, func(user *models.User) { user.LastLogin = at })
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+)
+
+type messageRepository struct {
+	db *gorm.DB
+}
+
+// NewMessageRepository returns the Postgres message repository
+func NewMessageRepository(db *gorm.DB) MessageRepository {
+	return &messageRepository{db: db}
+}
+
+func (r *messageRepository) Create(ctx context.Context, message *models.Message, afterInsert InsertHook) (bool, error) {
+	var created bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = InsertMessage(tx, message)
+		if err != nil || !created {
+			return err
+		}
+		if err := models.AddCustomerMessages(tx, message.CustomerID, 1, time.Now()); err != nil {
+			return err
+		}
+		if afterInsert == nil {
+			return nil
+		}
+		return afterInsert(tx)
+	})
+	return created, err
+}
+
+func (r *messageRepository) FindDuplicate(ctx context.Context, message *models.Message) (*models.Message, error) {
+	existing, err := FindDuplicateMessage(r.db.WithContext(ctx), message)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return existing, nil
+}
+
+func (r *messageRepository) Get(ctx context.Context, customerID, id uuid.UUID) (*models.Message, error) {
+	var message models.Message
+	if err := r.db.WithContext(ctx).Where("id = ? AND customer_id = ?", id, customerID).First(&message).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &message, nil
+}
+
+func (r *messageRepository) List(ctx context.Context, query *listquery.Query, filter MessageFilter) ([]models.Message, listquery.Page, error) {
+	db := r.db.WithContext(ctx)
+	base := db.Model(&models.Message{})
+	if filter.CustomerID != nil {
+		base = base.Where("customer_id = ?", *filter.CustomerID)
+	}
+
+	if len(filter.Labels) > 0 {
+		labelIDs, err := resolveLabels(db, filter.Labels)
+		if err != nil {
+			return nil, listquery.Page{}, err
+		}
+		if filter.AllLabels {
+			base = base.Where(`(SELECT COUNT(*) FROM message_labels
+				WHERE message_labels.message_id = messages.id AND message_labels.label_id IN ?) = ?`, labelIDs, len(labelIDs))
+		} else {
+			base = base.Where(`EXISTS (SELECT 1 FROM message_labels
+				WHERE message_labels.message_id = messages.id AND message_labels.label_id IN ?)`, labelIDs)
+		}
+	}
+
+	var messages []models.Message
+	page, err := listPage(query, base.Preload("Labels"), &messages)
+	return messages, page, err
+}
+
+func (r *messageRepository) SetStarred(ctx context.Context, customerID, id uuid.UUID, starred bool) error {
+	result := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("id = ? AND customer_id = ?", id, customerID).
+		Update("starred", starred)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *messageRepository) Trash(ctx context.Context, customerID, id uuid.UUID) error {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND customer_id = ? AND legal_hold = ?", id, customerID, false).
+			Delete(&models.Message{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return models.RemoveCustomerMessages(tx, customerID, 1)
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *messageRepository) Stats(ctx context.Context, customerID uuid.UUID, since time.Time) (MessageStats, error) {
+	var stats MessageStats
+	err := r.db.WithContext(ctx).Model(&models.Message{}).Select(
+		`COUNT(*) AS total_messages,
+		COUNT(*) FILTER (WHERE starred) AS starred_count,
+		COUNT(*) FILTER (WHERE timestamp >= ?) AS recent_count`, since).
+		Where("customer_id = ?", customerID).
+		Scan(&stats).Error
+	return stats, err
+}
+
+func (r *messageRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Message{}).Count(&count).Error
+	return count, err
+}
+
+// InsertMessage inserts a message unless it collides with an already stored
+// client ID or content hash and timestamp. It reports whether a row was created.
+func InsertMessage(db *gorm.DB, message *models.Message) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(message)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindDuplicateMessage loads the stored message a skipped insert collided with
+func FindDuplicateMessage(db *gorm.DB, message *models.Message) (*models.Message, error) {
+	// Trashed messages still hold their unique keys, so look there too
+	query := db.Unscoped().Where("customer_id = ?", message.CustomerID)
+	if message.ClientID != nil {
+		query = query.Where("client_id = ? OR (content_hash = ? AND timestamp = ?)",
+			*message.ClientID, message.ContentHash, message.Timestamp)
+	} else {
+		query = query.Where("content_hash = ? AND timestamp = ?", message.ContentHash, message.Timestamp)
+	}
+
+	var existing models.Message
+	if err := query.First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// resolveLabels looks up labels by ID or name and returns their IDs, failing
+// with an UnknownLabelError for the first one that doesn't exist
+func resolveLabels(db *gorm.DB, refs []string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	var names []string
+	for _, ref := range refs {
+		if id, err := uuid.Parse(ref); err == nil {
+			ids = append(ids, id)
+		} else {
+			names = append(names, ref)
+		}
+	}
+
+	var labels []models.Label
+	lookup := db.Model(&models.Label{})
+	switch {
+	case len(ids) > 0 && len(names) > 0:
+		lookup = lookup.Where("id IN ? OR name IN ?", ids, names)
+	case len(ids) > 0:
+		lookup = lookup.Where("id IN ?", ids)
+	default:
+		lookup = lookup.Where("name IN ?", names)
+	}
+	if err := lookup.Find(&labels).Error; err != nil {
+		return nil, err
+	}
+	return matchLabels(labels, refs)
+}
+
+// matchLabels returns the IDs of the labels refs name, failing with an
+// UnknownLabelError for the first ref none of labels matches
+func matchLabels(labels []models.Label, refs []string) ([]uuid.UUID, error) {
+	labelIDs := make([]uuid.UUID, 0, len(refs))
+	seen := make(map[uuid.UUID]bool, len(refs))
+	for _, ref := range refs {
+		id, err := uuid.Parse(ref)
+		isID := err == nil
+
+		var found *models.Label
+		for i := range labels {
+			if (isID && labels[i].ID == id) || (!isID && labels[i].Name == ref) {
+				found = &labels[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, &UnknownLabelError{Ref: ref}
+		}
+		if !seen[found.ID] {
+			seen[found.ID] = true
+			labelIDs = append(labelIDs, found.ID)
+		}
+	}
+	return labelIDs, nil
+}
@@ -0,0 +1,119 @@
+// Package repository puts the customer, message and user queries of the
+// handlers behind interfaces. NewCustomerRepository and friends return the
+// Postgres implementations; NewMemoryStore returns in-memory fakes so
+// handlers can run without a database.
+//
+// Every method takes the request context, which carries the tenancy scope
+// reads and writes are limited to.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/timeline"
+)
+
+// ErrNotFound is returned when the requested row doesn't exist in the scope
+var ErrNotFound = errors.New("record not found")
+
+// UnknownLabelError is returned for message label filters naming a label that doesn't exist
+type UnknownLabelError struct {
+	Ref string
+}
+
+func (e *UnknownLabelError) Error() string {
+	return "unknown label: " + e.Ref
+}
+
+// CustomerRepository stores customers. Writes also record the customer's
+// timeline and queue its webhooks in the same transaction.
+type CustomerRepository interface {
+	Create(ctx context.Context, customer *models.Customer) error
+	Get(ctx context.Context, id uuid.UUID) (*models.Customer, error)
+	GetByDevice(ctx context.Context, deviceID string) (*models.Customer, error)
+	GetMany(ctx context.Context, ids []uuid.UUID) ([]models.Customer, error)
+	// PhoneTaken reports whether another customer, in any organization and
+	// including trashed ones, already has phone
+	PhoneTaken(ctx context.Context, phone string, except uuid.UUID) (bool, error)
+	// List returns a page of customers, narrowed to those whose name, email or phone contain search
+	List(ctx context.Context, query *listquery.Query, search string) ([]models.Customer, listquery.Page, error)
+	// Top returns the active customers with the highest limits
+	Top(ctx context.Context, limit int) ([]models.Customer, error)
+	Summary(ctx context.Context, since time.Time) (CustomerSummary, error)
+	Update(ctx context.Context, before, customer *models.Customer, actor timeline.Actor) error
+	// Trash moves a customer and their messages to trash
+	Trash(ctx context.Context, id uuid.UUID, actor timeline.Actor) error
+}
+
+// CustomerSummary holds the dashboard's customer figures
+type CustomerSummary struct {
+	Total      int64
+	New        int64 // Created since the given time
+	Active     int64
+	TotalLimit float64
+}
+
+// InsertHook runs inside the transaction that stored a new message
+type InsertHook func(tx *gorm.DB) error
+
+// MessageRepository stores messages and keeps their customer's counters in step
+type MessageRepository interface {
+	// Create stores a message unless it duplicates a stored one, bumps the
+	// customer's counters and runs afterInsert, all in one transaction. It
+	// reports false for duplicates, which FindDuplicate then loads.
+	Create(ctx context.Context, message *models.Message, afterInsert InsertHook) (bool, error)
+	FindDuplicate(ctx context.Context, message *models.Message) (*models.Message, error)
+	Get(ctx context.Context, customerID, id uuid.UUID) (*models.Message, error)
+	// List returns a page of messages with their labels
+	List(ctx context.Context, query *listquery.Query, filter MessageFilter) ([]models.Message, listquery.Page, error)
+	SetStarred(ctx context.Context, customerID, id uuid.UUID, starred bool) error
+	// Trash moves a message to trash. Messages under legal hold are reported as not found.
+	Trash(ctx context.Context, customerID, id uuid.UUID) error
+	Stats(ctx context.Context, customerID uuid.UUID, since time.Time) (MessageStats, error)
+	Count(ctx context.Context) (int64, error)
+}
+
+// MessageFilter narrows message lists
+type MessageFilter struct {
+	CustomerID *uuid.UUID
+	Labels     []string // Label IDs or names
+	AllLabels  bool     // Require every label instead of any
+}
+
+// MessageStats are a customer's message counts
+type MessageStats struct {
+	TotalMessages int64 `json:"total_messages"`
+	StarredCount  int64 `json:"starred_count"`
+	RecentCount   int64 `json:"recent_count"` // Since the given time
+}
+
+// UserRepository stores staff accounts
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	Get(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// Exists reports whether a user, in any organization and including
+	// trashed ones, has the username or email
+	Exists(ctx context.Context, username, email string) (bool, error)
+	List(ctx context.Context, query *listquery.Query, filter UserFilter) ([]models.User, listquery.Page, error)
+	UpdateRole(ctx context.Context, user *models.User, role string) error
+	// Approve stores an approval and queues the user.approved webhook
+	Approve(ctx context.Context, user *models.User) error
+	Trash(ctx context.Context, user *models.User) error
+	RecordLogin(ctx context.Context, user *models.User, at time.Time) error
+}
+
+// UserFilter narrows user lists
+type UserFilter struct {
+	ExcludeID   *uuid.UUID
+	ExcludeRole string
+	Search      string // Part of the username or email
+	PendingOnly bool   // Unapproved regular users
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/listquery"
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/webhooks"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns the Postgres user repository
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *userRepository) Get(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) Exists(ctx context.Context, username, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(tenancy.System(ctx)).Unscoped().Model(&models.User{}).
+		Where("username = ? OR email = ?", username, email).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepository) List(ctx context.Context, query *listquery.Query, filter UserFilter) ([]models.User, listquery.Page, error) {
+	base := r.db.WithContext(ctx).Model(&models.User{})
+	if filter.ExcludeID != nil {
+		base = base.Where("id != ?", *filter.ExcludeID)
+	}
+	if filter.ExcludeRole != "" {
+		base = base.Where("role != ?", filter.ExcludeRole)
+	}
+	if filter.Search != "" {
+		base = base.Where("username ILIKE ? OR email ILIKE ?", "%"+filter.Search+"%", "%"+filter.Search+"%")
+	}
+	if filter.PendingOnly {
+		base = base.Where("is_approved = ? AND role = ?", false, models.RoleUser)
+	}
+
+	var users []models.User
+	page, err := listPage(query, base, &users)
+	return users, page, err
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, user *models.User, role string) error {
+	return r.db.WithContext(ctx).Model(user).Update("role", role).Error
+}
+
+func (r *userRepository) Approve(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return webhooks.Enqueue(tx, models.EventUserApproved, webhooks.UserPayload(user))
+	})
+}
+
+func (r *userRepository) Trash(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Delete(user).Error
+}
+
+func (r *userRepository) RecordLogin(ctx context.Context, user *models.User, at time.Time) error {
+	return r.db.WithContext(ctx).Model(user).Update("last_login", at).Error
+}
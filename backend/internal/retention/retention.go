@@ -0,0 +1,292 @@
+// Package retention purges messages according to the configured retention
+// policies. A policy only ever applies to its own organization's messages, and
+// messages or customers under legal hold are never purged.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// purgeLockKey is the advisory lock that keeps replicas from purging at the same time
+const purgeLockKey = 726_031
+
+// sampleSize is how many message IDs the dry-run report lists per policy
+const sampleSize = 5
+
+// PolicyReport describes what a single policy matches
+type PolicyReport struct {
+	PolicyID   uuid.UUID   `json:"policy_id"`
+	PolicyName string      `json:"policy_name"`
+	Cutoff     time.Time   `json:"cutoff"`
+	Matched    int64       `json:"matched"`
+	Oldest     *time.Time  `json:"oldest,omitempty"`
+	Newest     *time.Time  `json:"newest,omitempty"`
+	SampleIDs  []uuid.UUID `json:"sample_ids"`
+}
+
+// Report summarizes a purge or a dry run
+type Report struct {
+	DryRun      bool           `json:"dry_run"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Policies    []PolicyReport `json:"policies"`
+	Total       int64          `json:"total"` // Distinct messages; policies may overlap
+	OnHold      int64          `json:"on_hold"`
+}
+
+// Purger applies retention policies in batches
+type Purger struct {
+	db        *gorm.DB
+	batchSize int
+}
+
+// NewPurger creates a purger that deletes at most batchSize messages per transaction
+func NewPurger(db *gorm.DB, batchSize int) *Purger {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	return &Purger{db: db, batchSize: batchSize}
+}
+
+// Run purges on every interval until ctx is cancelled, sweeping expired
+// idempotency keys along the way
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := p.Purge(tenancy.System(ctx))
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Retention purge failed", slog.Any("error", err))
+				}
+			} else if report.Total > 0 {
+				slog.InfoContext(ctx, "Retention purge removed messages", slog.Int64("count", report.Total))
+			}
+
+			// Expired keys are otherwise only removed when a retry looks them up
+			swept, err := models.DeleteExpiredIdempotencyKeys(p.db.WithContext(ctx), time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.ErrorContext(ctx, "Idempotency key sweep failed", slog.Any("error", err))
+				}
+			} else if swept > 0 {
+				slog.InfoContext(ctx, "Removed expired idempotency keys", slog.Int64("count", swept))
+			}
+		}
+	}
+}
+
+// DryRun reports what the active policies would delete right now from the
+// organizations in ctx's tenant scope
+func (p *Purger) DryRun(ctx context.Context) (*Report, error) {
+	policies, err := p.activePolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &Report{DryRun: true, GeneratedAt: now, Policies: make([]PolicyReport, 0, len(policies))}
+	db := p.db.WithContext(ctx)
+
+	for i := range policies {
+		policy := &policies[i]
+		policyReport := PolicyReport{
+			PolicyID:   policy.ID,
+			PolicyName: policy.Name,
+			Cutoff:     policy.Cutoff(now),
+			SampleIDs:  []uuid.UUID{},
+		}
+
+		var stats struct {
+			Matched int64
+			Oldest  *time.Time
+			Newest  *time.Time
+		}
+		err := purgeable(db, policy, now).
+			Select("COUNT(*) AS matched, MIN(messages.timestamp) AS oldest, MAX(messages.timestamp) AS newest").
+			Scan(&stats).Error
+		if err != nil {
+			return nil, err
+		}
+		policyReport.Matched = stats.Matched
+		policyReport.Oldest = stats.Oldest
+		policyReport.Newest = stats.Newest
+
+		err = purgeable(db, policy, now).
+			Order("messages.timestamp ASC").
+			Limit(sampleSize).
+			Pluck("messages.id", &policyReport.SampleIDs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		report.Policies = append(report.Policies, policyReport)
+	}
+
+	if len(policies) > 0 {
+		if err := anyPolicy(db, policies, now, true).Count(&report.Total).Error; err != nil {
+			return nil, err
+		}
+		if err := anyPolicy(db, policies, now, false).
+			Where("messages.legal_hold OR customers.legal_hold").
+			Count(&report.OnHold).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// Purge deletes every message matched by an active policy, batch by batch.
+// Only the policies in ctx's tenant scope run, each against its own
+// organization's messages; scheduled purges run every organization's policies.
+func (p *Purger) Purge(ctx context.Context) (*Report, error) {
+	policies, err := p.activePolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &Report{GeneratedAt: now, Policies: make([]PolicyReport, 0, len(policies))}
+
+	for i := range policies {
+		policy := &policies[i]
+		policyReport := PolicyReport{
+			PolicyID:   policy.ID,
+			PolicyName: policy.Name,
+			Cutoff:     policy.Cutoff(now),
+			SampleIDs:  []uuid.UUID{},
+		}
+
+		for {
+			purged, locked, err := p.purgeBatch(ctx, policy, now)
+			if err != nil {
+				return report, err
+			}
+			if !locked {
+				// Another instance is purging; it will finish the job
+				return report, nil
+			}
+			policyReport.Matched += purged
+			report.Total += purged
+			if purged < int64(p.batchSize) {
+				break
+			}
+		}
+
+		report.Policies = append(report.Policies, policyReport)
+	}
+
+	return report, nil
+}
+
+// purgeBatch deletes up to batchSize messages for a policy and decrements the
+// owning customers' counters in the same transaction. The batch runs scoped to
+// the policy's organization, whatever the caller's scope.
+func (p *Purger) purgeBatch(ctx context.Context, policy *models.RetentionPolicy, now time.Time) (int64, bool, error) {
+	var purged int64
+	locked := false
+
+	ctx = tenancy.WithOrganization(ctx, policy.OrganizationID)
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", purgeLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		doomed := purgeable(tx, policy, now).
+			Select("messages.id").
+			Limit(p.batchSize)
+
+		// Trashed messages are purged too but were already removed from the counters
+		var perCustomer []struct {
+			CustomerID uuid.UUID
+			Purged     int64
+			Counted    int64
+		}
+		err := tx.Raw(`
+			WITH deleted AS (
+				DELETE FROM messages WHERE id IN (?) RETURNING customer_id, deleted_at
+			)
+			SELECT customer_id, COUNT(*) AS purged, COUNT(*) FILTER (WHERE deleted_at IS NULL) AS counted
+			FROM deleted GROUP BY customer_id`, doomed).
+			Scan(&perCustomer).Error
+		if err != nil {
+			return err
+		}
+
+		for _, row := range perCustomer {
+			purged += row.Purged
+			if row.Counted == 0 {
+				continue
+			}
+			if err := models.RemoveCustomerMessages(tx, row.CustomerID, row.Counted); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return purged, locked, err
+}
+
+// activePolicies loads the policies to apply
+func (p *Purger) activePolicies(ctx context.Context) ([]models.RetentionPolicy, error) {
+	var policies []models.RetentionPolicy
+	err := p.db.WithContext(ctx).Where("is_active = ?", true).Order("retain_days ASC, name ASC").Find(&policies).Error
+	return policies, err
+}
+
+// purgeable selects the messages a policy would delete now
+func purgeable(db *gorm.DB, policy *models.RetentionPolicy, now time.Time) *gorm.DB {
+	return db.Table("messages").
+		Joins("JOIN customers ON customers.id = messages.customer_id").
+		Scopes(tenancy.Filter("messages.organization_id")).
+		Where("NOT messages.legal_hold AND NOT customers.legal_hold").
+		Where(policyCondition(db, policy, now))
+}
+
+// anyPolicy selects messages matched by at least one policy, optionally excluding held ones
+func anyPolicy(db *gorm.DB, policies []models.RetentionPolicy, now time.Time, excludeHeld bool) *gorm.DB {
+	conditions := db.Session(&gorm.Session{NewDB: true}).Where(policyCondition(db, &policies[0], now))
+	for i := 1; i < len(policies); i++ {
+		conditions = conditions.Or(policyCondition(db, &policies[i], now))
+	}
+
+	query := db.Table("messages").
+		Joins("JOIN customers ON customers.id = messages.customer_id").
+		Scopes(tenancy.Filter("messages.organization_id")).
+		Where(conditions)
+	if excludeHeld {
+		query = query.Where("NOT messages.legal_hold AND NOT customers.legal_hold")
+	}
+	return query
+}
+
+// policyCondition builds the organization, age and pattern condition of a policy
+func policyCondition(db *gorm.DB, policy *models.RetentionPolicy, now time.Time) *gorm.DB {
+	condition := db.Session(&gorm.Session{NewDB: true}).
+		Where("messages.organization_id = ?", policy.OrganizationID).
+		Where("messages.timestamp < ?", policy.Cutoff(now))
+	if policy.ContentPattern != "" {
+		condition = condition.Where("messages.content ~* ?", policy.ContentPattern)
+	}
+	if policy.SenderPattern != "" {
+		condition = condition.Where("messages.sender ~* ?", policy.SenderPattern)
+	}
+	return condition
+}
@@ -0,0 +1,351 @@
+// Package rules evaluates admin defined rules against incoming messages.
+// Rules belong to an organization and only ever see its messages. Each
+// organization's active rules are cached for a short TTL and the cache is
+// dropped on every local write, so rule changes take effect without a restart.
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/models"
+	"message-backend/internal/smsparse"
+	"message-backend/internal/tenancy"
+)
+
+// cacheTTL bounds how long another instance's rule changes take to be picked up
+const cacheTTL = 10 * time.Second
+
+// Sample is the data a rule is evaluated against
+type Sample struct {
+	OrganizationID uuid.UUID // Only this organization's rules match
+	Sender         string
+	Content        string
+	Customer       *models.Customer // Optional, segment conditions never match without it
+}
+
+// Match is a rule that matched a sample
+type Match struct {
+	RuleID   uuid.UUID           `json:"rule_id"`
+	RuleName string              `json:"rule_name"`
+	Version  int                 `json:"version"`
+	Reasons  []string            `json:"reasons"`
+	Actions  []models.RuleAction `json:"actions"`
+}
+
+// Compiled is a rule with its patterns compiled
+type Compiled struct {
+	Rule    models.Rule
+	sender  *regexp.Regexp
+	content *regexp.Regexp
+}
+
+// Engine loads and evaluates the active rules of each organization
+type Engine struct {
+	db    *gorm.DB
+	mu    sync.Mutex
+	cache map[uuid.UUID]*cachedRules
+}
+
+// cachedRules are one organization's active rules
+type cachedRules struct {
+	rules    []*Compiled
+	loadedAt time.Time
+}
+
+// NewEngine creates a rule engine reading rules from db
+func NewEngine(db *gorm.DB) *Engine {
+	return &Engine{db: db, cache: make(map[uuid.UUID]*cachedRules)}
+}
+
+// Invalidate drops the cached rules of an organization so its next evaluation reloads them
+func (e *Engine) Invalidate(organizationID uuid.UUID) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.cache, organizationID)
+}
+
+// Active returns an organization's active rules in evaluation order,
+// reloading them when the cache is stale
+func (e *Engine) Active(ctx context.Context, organizationID uuid.UUID) ([]*Compiled, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if cached, ok := e.cache[organizationID]; ok && time.Since(cached.loadedAt) < cacheTTL {
+		return cached.rules, nil
+	}
+
+	var stored []models.Rule
+	err := e.db.WithContext(tenancy.WithOrganization(ctx, organizationID)).
+		Where("is_active = true").
+		Order("priority ASC, created_at ASC").
+		Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := make([]*Compiled, 0, len(stored))
+	for _, rule := range stored {
+		c, err := Compile(rule)
+		if err != nil {
+			// Rules are validated on write, so this only happens after manual edits
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	e.cache[organizationID] = &cachedRules{rules: compiled, loadedAt: time.Now()}
+	return compiled, nil
+}
+
+// Evaluate runs the active rules of the sample's organization against it
+func (e *Engine) Evaluate(ctx context.Context, sample Sample) ([]Match, error) {
+	active, err := e.Active(ctx, sample.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateAll(active, sample), nil
+}
+
+// EvaluateAll runs compiled rules in order, honoring StopProcessing. Rules of
+// another organization than the sample's never match.
+func EvaluateAll(compiled []*Compiled, sample Sample) []Match {
+	var matches []Match
+	for _, c := range compiled {
+		if c.Rule.OrganizationID != sample.OrganizationID {
+			continue
+		}
+		match, ok := c.Evaluate(sample)
+		if !ok {
+			continue
+		}
+		matches = append(matches, match)
+		if c.Rule.StopProcessing {
+			break
+		}
+	}
+	return matches
+}
+
+// Validate checks a rule's conditions and actions
+func Validate(rule *models.Rule) error {
+	cond := rule.Conditions
+	if cond.Match != "" && cond.Match != "all" && cond.Match != "any" {
+		return errors.New(`conditions.match must be "all" or "any"`)
+	}
+	if cond.SenderPattern == "" && cond.ContentPattern == "" && cond.AmountAbove == nil && cond.Segment == nil {
+		return errors.New("at least one condition is required")
+	}
+	if cond.Segment != nil && len(cond.Segment.CustomerIDs) == 0 &&
+		cond.Segment.MinTotalLimit == nil && cond.Segment.MaxTotalLimit == nil {
+		return errors.New("segment needs customer_ids, min_total_limit or max_total_limit")
+	}
+
+	if len(rule.Actions) == 0 {
+		return errors.New("at least one action is required")
+	}
+	for i, action := range rule.Actions {
+		switch action.Type {
+		case models.RuleActionStar:
+		case models.RuleActionAddLabel:
+			if strings.TrimSpace(action.Label) == "" || len(action.Label) > 50 {
+				return fmt.Errorf("actions[%d]: label must be 1-50 characters", i)
+			}
+		case models.RuleActionSetPriority:
+			if models.RankOf(models.MessagePriorities, action.Priority) < 0 {
+				return fmt.Errorf("actions[%d]: priority must be one of %s", i, strings.Join(models.MessagePriorities, ", "))
+			}
+		case models.RuleActionAlert:
+			if models.RankOf(models.AlertSeverities, action.Severity) < 0 {
+				return fmt.Errorf("actions[%d]: severity must be one of %s", i, strings.Join(models.AlertSeverities, ", "))
+			}
+			if len(action.Title) > 200 {
+				return fmt.Errorf("actions[%d]: title must be at most 200 characters", i)
+			}
+		default:
+			return fmt.Errorf("actions[%d]: unknown action type %q", i, action.Type)
+		}
+	}
+
+	_, err := Compile(*rule)
+	return err
+}
+
+// Compile compiles a rule's patterns. Patterns are case-insensitive RE2 expressions.
+func Compile(rule models.Rule) (*Compiled, error) {
+	c := &Compiled{Rule: rule}
+	var err error
+	if rule.Conditions.SenderPattern != "" {
+		if c.sender, err = regexp.Compile("(?i)" + rule.Conditions.SenderPattern); err != nil {
+			return nil, fmt.Errorf("sender_pattern: %w", err)
+		}
+	}
+	if rule.Conditions.ContentPattern != "" {
+		if c.content, err = regexp.Compile("(?i)" + rule.Conditions.ContentPattern); err != nil {
+			return nil, fmt.Errorf("content_pattern: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Evaluate checks every set condition against the sample and explains the ones that held
+func (c *Compiled) Evaluate(sample Sample) (Match, bool) {
+	cond := c.Rule.Conditions
+	var checked, held int
+	var reasons []string
+
+	check := func(ok bool, reason string) {
+		checked++
+		if ok {
+			held++
+			reasons = append(reasons, reason)
+		}
+	}
+
+	if c.sender != nil {
+		check(c.sender.MatchString(sample.Sender), fmt.Sprintf("sender %q matches %q", sample.Sender, cond.SenderPattern))
+	}
+	if c.content != nil {
+		check(c.content.MatchString(sample.Content), fmt.Sprintf("content matches %q", cond.ContentPattern))
+	}
+	if cond.AmountAbove != nil {
+		amount, ok := smsparse.ParseAmount(sample.Content)
+		check(ok && amount.Value > *cond.AmountAbove,
+			fmt.Sprintf("amount %.2f %s is above %.2f", amount.Value, amount.Currency, *cond.AmountAbove))
+	}
+	if cond.Segment != nil {
+		check(inSegment(cond.Segment, sample.Customer), "customer is in segment")
+	}
+
+	matched := checked > 0 && held == checked
+	if cond.Match == "any" {
+		matched = held > 0
+	}
+	if !matched {
+		return Match{}, false
+	}
+
+	return Match{
+		RuleID:   c.Rule.ID,
+		RuleName: c.Rule.Name,
+		Version:  c.Rule.Version,
+		Reasons:  reasons,
+		Actions:  c.Rule.Actions,
+	}, true
+}
+
+// inSegment reports whether a customer satisfies every set field of a segment
+func inSegment(segment *models.CustomerSegment, customer *models.Customer) bool {
+	if customer == nil {
+		return false
+	}
+	if len(segment.CustomerIDs) > 0 {
+		found := false
+		for _, id := range segment.CustomerIDs {
+			if id == customer.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if segment.MinTotalLimit != nil && customer.TotalLimit < *segment.MinTotalLimit {
+		return false
+	}
+	if segment.MaxTotalLimit != nil && customer.TotalLimit > *segment.MaxTotalLimit {
+		return false
+	}
+	return true
+}
+
+// Apply sets the fields the matched actions change on a message that is about to be inserted.
+// When several rules set a priority the highest one wins.
+func Apply(message *models.Message, matches []Match) {
+	for _, match := range matches {
+		for _, action := range match.Actions {
+			switch action.Type {
+			case models.RuleActionStar:
+				message.Starred = true
+			case models.RuleActionSetPriority:
+				if models.RankOf(models.MessagePriorities, action.Priority) > models.RankOf(models.MessagePriorities, message.Priority) {
+					message.Priority = action.Priority
+				}
+			}
+		}
+	}
+}
+
+// Attach adds the labels and raises the alerts of the matched actions for a stored message
+func Attach(tx *gorm.DB, message *models.Message, matches []Match) error {
+	var labels []models.Label
+	seen := make(map[string]bool)
+
+	for _, match := range matches {
+		for _, action := range match.Actions {
+			switch action.Type {
+			case models.RuleActionAddLabel:
+				name := strings.TrimSpace(action.Label)
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				label, err := findOrCreateLabel(tx, message.OrganizationID, name)
+				if err != nil {
+					return err
+				}
+				labels = append(labels, *label)
+			case models.RuleActionAlert:
+				ruleID := match.RuleID
+				title := action.Title
+				if title == "" {
+					title = fmt.Sprintf("Rule %q matched", match.RuleName)
+				}
+				alert := &models.Alert{
+					OrganizationID: message.OrganizationID,
+					CustomerID:     message.CustomerID,
+					MessageID:      &message.ID,
+					RuleID:         &ruleID,
+					Source:         models.AlertSourceRule,
+					Severity:       action.Severity,
+					Title:          title,
+					Explanation:    fmt.Sprintf("Rule %q v%d: %s", match.RuleName, match.Version, strings.Join(match.Reasons, "; ")),
+					Status:         models.AlertStatusOpen,
+				}
+				if err := tx.Create(alert).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+	return tx.Model(message).Association("Labels").Append(labels)
+}
+
+// findOrCreateLabel returns the organization's label with a name, creating it if needed
+func findOrCreateLabel(tx *gorm.DB, organizationID uuid.UUID, name string) (*models.Label, error) {
+	label := &models.Label{OrganizationID: organizationID, Name: name}
+	conflict := clause.OnConflict{Columns: []clause.Column{{Name: "organization_id"}, {Name: "name"}}, DoNothing: true}
+	if err := tx.Clauses(conflict).Create(label).Error; err != nil {
+		return nil, err
+	}
+	if label.ID == uuid.Nil {
+		if err := tx.Where("organization_id = ? AND name = ?", organizationID, name).First(label).Error; err != nil {
+			return nil, err
+		}
+	}
+	return label, nil
+}
@@ -0,0 +1,89 @@
+// Package server runs the HTTP server with the timeouts, protocols and TLS
+// settings from the configuration.
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"message-backend/internal/config"
+)
+
+// Server is the configured HTTP server
+type Server struct {
+	cfg   *config.Config
+	http  *http.Server
+	certs *certReloader // nil without TLS
+}
+
+// New builds the server for handler. With TLS configured the certificate,
+// key and client CA are loaded up front so a bad file fails startup.
+func New(cfg *config.Config, handler http.Handler) (*Server, error) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.ServerHTTP2)
+	protocols.SetUnencryptedHTTP2(cfg.ServerH2C)
+
+	s := &Server{
+		cfg: cfg,
+		http: &http.Server{
+			Addr:              cfg.ServerHost + ":" + cfg.ServerPort,
+			Handler:           handler,
+			ReadTimeout:       cfg.ServerReadTimeout,
+			ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+			WriteTimeout:      cfg.ServerWriteTimeout,
+			IdleTimeout:       cfg.ServerIdleTimeout,
+			MaxHeaderBytes:    cfg.ServerMaxHeaderBytes,
+			Protocols:         protocols,
+		},
+	}
+
+	if cfg.TLSEnabled() {
+		certs, err := newCertReloader(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = certs
+		s.http.TLSConfig = certs.baseConfig()
+	}
+	return s, nil
+}
+
+// Run serves until ctx is cancelled, then shuts down gracefully, giving
+// in-flight requests SERVER_SHUTDOWN_TIMEOUT to finish
+func (s *Server) Run(ctx context.Context) error {
+	served := make(chan error, 1)
+	go func() {
+		if s.certs != nil {
+			// Certificates come from the TLS config, so no files are passed
+			served <- s.http.ListenAndServeTLS("", "")
+		} else {
+			served <- s.http.ListenAndServe()
+		}
+	}()
+
+	reloadCtx, stopReload := context.WithCancel(ctx)
+	defer stopReload()
+	if s.certs != nil && s.cfg.TLSReloadInterval > 0 {
+		go s.certs.Run(reloadCtx, s.cfg.TLSReloadInterval)
+	}
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ServerShutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"message-backend/internal/config"
+)
+
+// certReloader serves the certificate and client CAs from disk, replacing them
+// when the files change so renewed certificates apply without a restart
+type certReloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	clientAuth tls.ClientAuthType
+	minVersion uint16
+	nextProtos []string
+
+	mu      sync.RWMutex
+	config  *tls.Config // Current per-connection config
+	modTime map[string]time.Time
+}
+
+func newCertReloader(cfg *config.Config) (*certReloader, error) {
+	r := &certReloader{
+		certFile:   cfg.TLSCertFile,
+		keyFile:    cfg.TLSKeyFile,
+		caFile:     cfg.TLSClientCAFile,
+		clientAuth: tls.RequireAndVerifyClientCert,
+		minVersion: tls.VersionTLS12,
+		nextProtos: []string{"http/1.1"},
+	}
+	if cfg.TLSClientAuth == "verify_if_given" {
+		r.clientAuth = tls.VerifyClientCertIfGiven
+	}
+	if cfg.TLSMinVersion == "1.3" {
+		r.minVersion = tls.VersionTLS13
+	}
+	if cfg.ServerHTTP2 {
+		r.nextProtos = []string{"h2", "http/1.1"}
+	}
+
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// baseConfig is the server's TLS config. Every handshake gets the current
+// certificate and client CAs through GetConfigForClient.
+func (r *certReloader) baseConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		NextProtos: r.nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+	}
+}
+
+// Run checks the files for changes every interval until ctx is cancelled. A
+// failed reload keeps the previous certificate.
+func (r *certReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				slog.Error("Failed to reload TLS certificate, keeping the current one", slog.Any("error", err))
+			} else if reloaded {
+				slog.Info("Reloaded TLS certificate")
+			}
+		}
+	}
+}
+
+// reload rebuilds the TLS config if any of the files changed since the last
+// load, and reports whether it did
+func (r *certReloader) reload() (bool, error) {
+	modTime := make(map[string]time.Time)
+	changed := r.config == nil
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		modTime[file] = info.ModTime()
+		if !info.ModTime().Equal(r.modTime[file]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.minVersion,
+		NextProtos:   r.nextProtos,
+	}
+
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read TLS client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("no certificates found in TLS client CA %s", r.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = r.clientAuth
+	}
+
+	r.mu.Lock()
+	r.config = config
+	r.modTime = modTime
+	r.mu.Unlock()
+	return true, nil
+}
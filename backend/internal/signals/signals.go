@@ -0,0 +1,275 @@
+// Package signals flags suspicious card activity in parsed bank alerts:
+// velocity spikes, large debits relative to the customer's limit, foreign
+// currency, first-seen merchants and late-night activity.
+package signals
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/config"
+	"message-backend/internal/models"
+	"message-backend/internal/smsparse"
+)
+
+// Late-night activity is any debit between these local hours
+const (
+	nightStartHour = 0
+	nightEndHour   = 5
+)
+
+// newMerchantMinHistory is how many earlier debits a customer needs before
+// an unseen merchant is worth flagging
+const newMerchantMinHistory = 3
+
+// Signal is a single suspicious pattern found in a transaction
+type Signal struct {
+	Type        string `json:"type"`
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Explanation string `json:"explanation"`
+}
+
+// Detector turns bank alerts into transactions and fraud signals
+type Detector struct {
+	velocityWindow  time.Duration
+	velocityCount   int
+	largeDebitRatio float64
+	homeCurrency    string
+	location        *time.Location
+}
+
+// NewDetector creates a detector from the signal settings
+func NewDetector(cfg *config.Config) *Detector {
+	location, err := time.LoadLocation(cfg.SignalTimezone)
+	if err != nil {
+		slog.Warn("Unknown signal timezone, using local time", slog.String("timezone", cfg.SignalTimezone), slog.Any("error", err))
+		location = time.Local
+	}
+	return &Detector{
+		velocityWindow:  cfg.SignalVelocityWindow,
+		velocityCount:   cfg.SignalVelocityCount,
+		largeDebitRatio: cfg.SignalLargeDebitRatio,
+		homeCurrency:    cfg.SignalHomeCurrency,
+		location:        location,
+	}
+}
+
+// WithSettings returns a copy of the detector using an organization's home
+// currency and timezone. Settings the organization left empty keep the
+// deployment-wide values.
+func (d *Detector) WithSettings(settings models.OrganizationSettings) *Detector {
+	custom := *d
+	if settings.HomeCurrency != "" {
+		custom.homeCurrency = settings.HomeCurrency
+	}
+	if settings.Timezone != "" {
+		if location, err := time.LoadLocation(settings.Timezone); err == nil {
+			custom.location = location
+		}
+	}
+	return &custom
+}
+
+// Process parses a stored message as a bank alert, records the transaction and
+// raises an alert for every signal. Messages that are not bank alerts are ignored.
+func (d *Detector) Process(tx *gorm.DB, message *models.Message, customer *models.Customer) ([]models.Alert, error) {
+	parsed, ok := smsparse.ParseBankAlert(message.Content)
+	if !ok {
+		return nil, nil
+	}
+
+	txn := &models.Transaction{
+		OrganizationID: message.OrganizationID,
+		MessageID:      message.ID,
+		CustomerID:     message.CustomerID,
+		Direction:      parsed.Direction,
+		Amount:         parsed.Amount.Value,
+		Currency:       parsed.Amount.Currency,
+		Merchant:       smsparse.NormalizeMerchant(parsed.Merchant),
+		CardLast4:      parsed.CardLast4,
+		OccurredAt:     message.Timestamp,
+	}
+
+	// Detect before inserting so history queries only see earlier transactions
+	found, err := d.Detect(tx, customer, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(txn).Error; err != nil {
+		return nil, err
+	}
+
+	alerts := make([]models.Alert, 0, len(found))
+	for _, signal := range found {
+		alert := models.Alert{
+			OrganizationID: message.OrganizationID,
+			CustomerID:     message.CustomerID,
+			MessageID:      &message.ID,
+			Source:         models.AlertSourceSignal,
+			Type:           signal.Type,
+			Severity:       signal.Severity,
+			Title:          signal.Title,
+			Explanation:    signal.Explanation,
+			Status:         models.AlertStatusOpen,
+		}
+		if err := tx.Create(&alert).Error; err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// Detect returns the signals a transaction raises against the customer's history.
+// Only debits are considered; credits are never suspicious on their own.
+func (d *Detector) Detect(tx *gorm.DB, customer *models.Customer, txn *models.Transaction) ([]Signal, error) {
+	if txn.Direction != smsparse.Debit {
+		return nil, nil
+	}
+
+	var found []Signal
+
+	velocity, err := d.velocity(tx, txn)
+	if err != nil {
+		return nil, err
+	}
+	if velocity != nil {
+		found = append(found, *velocity)
+	}
+
+	if signal := d.largeDebit(customer, txn); signal != nil {
+		found = append(found, *signal)
+	}
+
+	if txn.Currency != "" && txn.Currency != d.homeCurrency {
+		found = append(found, Signal{
+			Type:        models.SignalForeignCurrency,
+			Severity:    models.SeverityMedium,
+			Title:       "Foreign currency debit",
+			Explanation: fmt.Sprintf("Debit of %.2f %s is not in the home currency %s", txn.Amount, txn.Currency, d.homeCurrency),
+		})
+	}
+
+	merchant, err := d.newMerchant(tx, txn)
+	if err != nil {
+		return nil, err
+	}
+	if merchant != nil {
+		found = append(found, *merchant)
+	}
+
+	local := txn.OccurredAt.In(d.location)
+	if hour := local.Hour(); hour >= nightStartHour && hour < nightEndHour {
+		found = append(found, Signal{
+			Type:        models.SignalLateNight,
+			Severity:    models.SeverityLow,
+			Title:       "Late-night debit",
+			Explanation: fmt.Sprintf("Debit of %.2f %s at %s local time", txn.Amount, txn.Currency, local.Format("15:04")),
+		})
+	}
+
+	return found, nil
+}
+
+// velocity flags a burst of debits inside the window. A burst is reported once:
+// debits that keep it going while its alert is open add nothing.
+func (d *Detector) velocity(tx *gorm.DB, txn *models.Transaction) (*Signal, error) {
+	if d.velocityCount <= 0 {
+		return nil, nil
+	}
+
+	var earlier int64
+	err := tx.Model(&models.Transaction{}).
+		Where("customer_id = ? AND direction = ? AND occurred_at > ? AND occurred_at <= ?",
+			txn.CustomerID, smsparse.Debit, txn.OccurredAt.Add(-d.velocityWindow), txn.OccurredAt).
+		Count(&earlier).Error
+	if err != nil {
+		return nil, err
+	}
+	count := int(earlier) + 1
+	if count < d.velocityCount {
+		return nil, nil
+	}
+
+	var open int64
+	err = tx.Model(&models.Alert{}).
+		Where("customer_id = ? AND type = ? AND status = ? AND created_at > ?",
+			txn.CustomerID, models.SignalVelocity, models.AlertStatusOpen, time.Now().Add(-d.velocityWindow)).
+		Count(&open).Error
+	if err != nil || open > 0 {
+		return nil, err
+	}
+
+	severity := models.SeverityHigh
+	if count >= 2*d.velocityCount {
+		severity = models.SeverityCritical
+	}
+	return &Signal{
+		Type:        models.SignalVelocity,
+		Severity:    severity,
+		Title:       "Debit velocity spike",
+		Explanation: fmt.Sprintf("%d debits within %s (threshold %d)", count, d.velocityWindow, d.velocityCount),
+	}, nil
+}
+
+// largeDebit flags a home currency debit that uses a large share of the customer's total limit
+func (d *Detector) largeDebit(customer *models.Customer, txn *models.Transaction) *Signal {
+	if customer == nil || customer.TotalLimit <= 0 || d.largeDebitRatio <= 0 {
+		return nil
+	}
+	if txn.Currency != "" && txn.Currency != d.homeCurrency {
+		return nil
+	}
+
+	ratio := txn.Amount / customer.TotalLimit
+	if ratio < d.largeDebitRatio {
+		return nil
+	}
+
+	severity := models.SeverityHigh
+	if ratio >= 0.9 {
+		severity = models.SeverityCritical
+	}
+	return &Signal{
+		Type:     models.SignalLargeDebit,
+		Severity: severity,
+		Title:    "Unusually large debit",
+		Explanation: fmt.Sprintf("Debit of %.2f is %.0f%% of the total limit %.2f (threshold %.0f%%)",
+			txn.Amount, ratio*100, customer.TotalLimit, d.largeDebitRatio*100),
+	}
+}
+
+// newMerchant flags the first debit at a merchant for a customer with enough history
+func (d *Detector) newMerchant(tx *gorm.DB, txn *models.Transaction) (*Signal, error) {
+	if txn.Merchant == "" {
+		return nil, nil
+	}
+
+	var history struct {
+		Total int64
+		Seen  int64
+	}
+	err := tx.Model(&models.Transaction{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE merchant = ?) AS seen", txn.Merchant).
+		Where("customer_id = ? AND direction = ? AND occurred_at < ?", txn.CustomerID, smsparse.Debit, txn.OccurredAt).
+		Scan(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	if history.Total < newMerchantMinHistory || history.Seen > 0 {
+		return nil, nil
+	}
+
+	return &Signal{
+		Type:        models.SignalNewMerchant,
+		Severity:    models.SeverityLow,
+		Title:       "First-seen merchant",
+		Explanation: fmt.Sprintf("First debit at %s after %d earlier debits", txn.Merchant, history.Total),
+	}, nil
+}
@@ -0,0 +1,121 @@
+// Package smsparse extracts structured data from bank and card SMS alerts.
+package smsparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// currencySymbols maps the symbols and prefixes banks use to ISO currency codes
+var currencySymbols = map[string]string{
+	"rs":  "INR",
+	"rs.": "INR",
+	"inr": "INR",
+	"₹":   "INR",
+	"usd": "USD",
+	"$":   "USD",
+	"eur": "EUR",
+	"€":   "EUR",
+	"gbp": "GBP",
+	"£":   "GBP",
+	"aed": "AED",
+	"sgd": "SGD",
+}
+
+// amountBefore matches a currency followed by an amount, e.g. "Rs. 1,250.00" or
+// "INR 500". Currency codes must start a word so "Offers 500" or "hours 20"
+// aren't read as rupees; a digit may follow directly, as in "INR500".
+var amountBefore = regexp.MustCompile(`(?i)(\b(?:rs\.?|inr|usd|eur|gbp|aed|sgd)|₹|\$|€|£)\s*([0-9][0-9,]*(?:\.[0-9]{1,2})?)`)
+
+// amountAfter matches an amount followed by a currency code, e.g. "500.00 INR"
+var amountAfter = regexp.MustCompile(`(?i)\b([0-9][0-9,]*(?:\.[0-9]{1,2})?)\s*(inr|usd|eur|gbp|aed|sgd)\b`)
+
+// Amount is a monetary value found in a message
+type Amount struct {
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+// ParseAmount returns the first monetary amount in content
+func ParseAmount(content string) (Amount, bool) {
+	before := amountBefore.FindStringSubmatchIndex(content)
+	after := amountAfter.FindStringSubmatchIndex(content)
+
+	var symbol, number string
+	switch {
+	case before != nil && (after == nil || before[0] <= after[0]):
+		symbol, number = content[before[2]:before[3]], content[before[4]:before[5]]
+	case after != nil:
+		number, symbol = content[after[2]:after[3]], content[after[4]:after[5]]
+	default:
+		return Amount{}, false
+	}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+	if err != nil {
+		return Amount{}, false
+	}
+	return Amount{Value: value, Currency: currencySymbols[strings.ToLower(symbol)]}, true
+}
+
+// Transaction directions
+const (
+	Debit  = "debit"
+	Credit = "credit"
+)
+
+// debitWords and creditWords decide a bank alert's direction
+var (
+	debitWords  = regexp.MustCompile(`(?i)\b(debited|spent|purchase|withdrawn|withdrawal|paid|payment of|txn of|used for|charged)\b`)
+	creditWords = regexp.MustCompile(`(?i)\b(credited|received|refund(ed)?|deposited|reversed)\b`)
+)
+
+// merchantPattern finds the counterparty after "at", "to" or "towards"
+var merchantPattern = regexp.MustCompile(`(?i)\b(?:at|to|towards)\s+([A-Za-z0-9][A-Za-z0-9&'*._\-/ ]{1,40}?)(?:\s+on\b|\s+via\b|\s+ref\b|\s+for\b|\s+avl\b|\s+info\b|\.(?:\s|$)|[,;:(]|\s*$)`)
+
+// cardPattern finds the last four digits of a card or account number
+var cardPattern = regexp.MustCompile(`(?i)\b(?:card|a/c|acct|account|ac)\b[^0-9]{0,20}?[xX*]+\s*(\d{4})\b`)
+
+// BankAlert is the structured content of a bank or card transaction SMS
+type BankAlert struct {
+	Direction string `json:"direction"` // debit or credit
+	Amount    Amount `json:"amount"`
+	Merchant  string `json:"merchant,omitempty"`
+	CardLast4 string `json:"card_last4,omitempty"`
+}
+
+// ParseBankAlert recognizes a transaction alert. Messages without an amount or
+// a debit/credit keyword, such as OTPs and promotions, are not bank alerts.
+func ParseBankAlert(content string) (*BankAlert, bool) {
+	amount, ok := ParseAmount(content)
+	if !ok {
+		return nil, false
+	}
+
+	debit := debitWords.FindStringIndex(content)
+	credit := creditWords.FindStringIndex(content)
+
+	alert := &BankAlert{Amount: amount}
+	switch {
+	case debit != nil && (credit == nil || debit[0] <= credit[0]):
+		alert.Direction = Debit
+	case credit != nil:
+		alert.Direction = Credit
+	default:
+		return nil, false
+	}
+
+	if m := merchantPattern.FindStringSubmatch(content); m != nil {
+		alert.Merchant = strings.TrimSpace(m[1])
+	}
+	if m := cardPattern.FindStringSubmatch(content); m != nil {
+		alert.CardLast4 = m[1]
+	}
+	return alert, true
+}
+
+// NormalizeMerchant folds merchant names so spelling variants compare equal
+func NormalizeMerchant(merchant string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(merchant)), " ")
+}
@@ -0,0 +1,46 @@
+package smsparse
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		content  string
+		ok       bool
+		value    float64
+		currency string
+	}{
+		{"Rs. 1,250.00 debited from A/c XX1234", true, 1250, "INR"},
+		{"Rs.500 spent on card", true, 500, "INR"},
+		{"INR500 debited", true, 500, "INR"},
+		{"₹ 99.50 paid to Swiggy", true, 99.5, "INR"},
+		{"USD 20 charged", true, 20, "USD"},
+		{"$15.25 purchase at Amazon", true, 15.25, "USD"},
+		{"500.00 INR credited", true, 500, "INR"},
+		{"Offers 500 off on your next order", false, 0, ""},
+		{"Store hours 20 to 22 daily", false, 0, ""},
+		{"Your OTP is 123456", false, 0, ""},
+		{"Offers 500 cashback, Rs 200 debited", true, 200, "INR"},
+	}
+
+	for _, tt := range tests {
+		amount, ok := ParseAmount(tt.content)
+		if ok != tt.ok {
+			t.Errorf("ParseAmount(%q) ok = %v, want %v", tt.content, ok, tt.ok)
+			continue
+		}
+		if ok && (amount.Value != tt.value || amount.Currency != tt.currency) {
+			t.Errorf("ParseAmount(%q) = %v %s, want %v %s", tt.content, amount.Value, amount.Currency, tt.value, tt.currency)
+		}
+	}
+}
+
+func TestParseBankAlertIgnoresWordsEndingInCurrencyCodes(t *testing.T) {
+	for _, content := range []string{
+		"Offers 500 paid members only",
+		"Open hours 20 for payment of bills",
+	} {
+		if alert, ok := ParseBankAlert(content); ok {
+			t.Errorf("ParseBankAlert(%q) = %+v, want no alert", content, alert)
+		}
+	}
+}
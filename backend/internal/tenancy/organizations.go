@@ -0,0 +1,106 @@
+package tenancy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"message-backend/internal/models"
+)
+
+// cacheTTL bounds how long another instance's organization changes take to be picked up
+const cacheTTL = 30 * time.Second
+
+// EnsureDefault returns the default organization, creating it if needed
+func EnsureDefault(db *gorm.DB) (*models.Organization, error) {
+	org := models.Organization{
+		Name:     "Default",
+		Slug:     models.DefaultOrganizationSlug,
+		Settings: models.OrganizationSettings{AllowSignup: true}, // Signup was open before tenancy
+		IsActive: true,
+	}
+	if err := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slug"}}, DoNothing: true}).
+		Create(&org).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("slug = ?", models.DefaultOrganizationSlug).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// Directory caches organizations for lookups on the request path, such as
+// per-organization settings during message ingest
+type Directory struct {
+	db       *gorm.DB
+	mu       sync.Mutex
+	orgs     map[uuid.UUID]*models.Organization
+	loadedAt time.Time
+}
+
+// NewDirectory creates a directory reading organizations from db
+func NewDirectory(db *gorm.DB) *Directory {
+	return &Directory{db: db}
+}
+
+// Invalidate drops the cached organizations so the next lookup reloads them
+func (d *Directory) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.orgs = nil
+	d.loadedAt = time.Time{}
+}
+
+// Get returns an organization by ID, or nil when it doesn't exist
+func (d *Directory) Get(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.load(ctx); err != nil {
+		return nil, err
+	}
+	return d.orgs[id], nil
+}
+
+// Lookup resolves an organization reference, either its ID or its slug, and
+// returns nil when nothing matches
+func (d *Directory) Lookup(ctx context.Context, ref string) (*models.Organization, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return d.Get(ctx, id)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.load(ctx); err != nil {
+		return nil, err
+	}
+	for _, org := range d.orgs {
+		if org.Slug == ref {
+			return org, nil
+		}
+	}
+	return nil, nil
+}
+
+// load refreshes the cache when it has expired. The caller holds d.mu.
+func (d *Directory) load(ctx context.Context) error {
+	if d.orgs != nil && time.Since(d.loadedAt) < cacheTTL {
+		return nil
+	}
+
+	var stored []models.Organization
+	if err := d.db.WithContext(ctx).Find(&stored).Error; err != nil {
+		return err
+	}
+	d.orgs = make(map[uuid.UUID]*models.Organization, len(stored))
+	for i := range stored {
+		d.orgs[stored[i].ID] = &stored[i]
+	}
+	d.loadedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,206 @@
+// Package tenancy scopes staff, customers and messages to organizations.
+//
+// Every request carries a Scope, set from the caller's JWT or, for the public
+// Android routes, from the customer being written to. GORM callbacks add the
+// scope's organization to every query on a table with an organization_id
+// column and stamp it on every insert. A query without a scope fails instead
+// of silently reading every tenant, so background jobs must opt in with System.
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// scopeKey holds the Scope in gin.Context.Keys, which gin exposes through Value
+const scopeKey = "tenancy.scope"
+
+// contextKey holds the Scope in plain contexts
+type contextKey struct{}
+
+// Header names the organization a request acts in, by ID or slug. Staff may
+// only send it with the cross-tenant permission; the Android app uses it to
+// pick the organization a new customer signs up with.
+const Header = "X-Organization-ID"
+
+// AllOrganizations is the Header value for the read-only view of every organization
+const AllOrganizations = "all"
+
+// organizationField is the model field that marks a table as tenant-owned
+const organizationField = "OrganizationID"
+
+var (
+	// ErrNoScope is returned for tenant table queries made without a scope
+	ErrNoScope = errors.New("tenancy: tenant table queried without an organization scope")
+	// ErrNoOrganization is returned for inserts that don't say which organization owns the row
+	ErrNoOrganization = errors.New("tenancy: row has no organization")
+	// ErrWrongOrganization is returned for inserts into another organization
+	ErrWrongOrganization = errors.New("tenancy: row belongs to another organization")
+)
+
+// Scope is the set of organizations a query may touch: either one
+// organization or, for system jobs and the super-admin view, all of them
+type Scope struct {
+	OrganizationID uuid.UUID
+	All            bool
+}
+
+// Set scopes the rest of the request to one organization
+func Set(c *gin.Context, organizationID uuid.UUID) {
+	c.Set(scopeKey, Scope{OrganizationID: organizationID})
+}
+
+// SetAll lets the rest of the request read every organization. Inserts must
+// then name their organization explicitly.
+func SetAll(c *gin.Context) {
+	c.Set(scopeKey, Scope{All: true})
+}
+
+// WithScope returns a context carrying scope, for work that outlives a gin request
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, contextKey{}, scope)
+}
+
+// WithOrganization returns a context scoped to one organization
+func WithOrganization(ctx context.Context, organizationID uuid.UUID) context.Context {
+	return WithScope(ctx, Scope{OrganizationID: organizationID})
+}
+
+// System returns a context that may touch every organization, for background
+// jobs and for lookups that happen before the tenant is known
+func System(ctx context.Context) context.Context {
+	return WithScope(ctx, Scope{All: true})
+}
+
+// RequestContext returns the request's context carrying its scope, for code
+// that takes a plain context and should stop when the client goes away
+func RequestContext(c *gin.Context) context.Context {
+	scope, ok := FromContext(c)
+	if !ok {
+		return c.Request.Context()
+	}
+	return WithScope(c.Request.Context(), scope)
+}
+
+// FromContext returns the scope of a context or gin request
+func FromContext(ctx context.Context) (Scope, bool) {
+	if ctx == nil {
+		return Scope{}, false
+	}
+	if scope, ok := ctx.Value(contextKey{}).(Scope); ok {
+		return scope, true
+	}
+	scope, ok := ctx.Value(scopeKey).(Scope)
+	return scope, ok
+}
+
+// Register installs the callbacks that enforce scopes on db
+func Register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenancy:scope", scopeStatement); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenancy:scope", scopeStatement); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenancy:scope", scopeStatement); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenancy:scope", scopeStatement); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenancy:assign", assignOrganization)
+}
+
+// Filter scopes queries GORM can't scope by itself, such as Table and Joins
+// queries, on the given organization_id column
+func Filter(column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		scope, ok := FromContext(db.Statement.Context)
+		if !ok {
+			db.AddError(ErrNoScope)
+			return db
+		}
+		if scope.All {
+			return db
+		}
+		return db.Where(column+" = ?", scope.OrganizationID)
+	}
+}
+
+// scopeStatement restricts reads, updates and deletes of tenant tables to the scope's organization
+func scopeStatement(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(organizationField)
+	if field == nil {
+		return
+	}
+
+	scope, ok := FromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrNoScope)
+		return
+	}
+	if scope.All {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: field.DBName}, Value: scope.OrganizationID},
+	}})
+}
+
+// assignOrganization stamps the scope's organization on new tenant rows and
+// refuses rows that belong to another organization
+func assignOrganization(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(organizationField)
+	if field == nil {
+		return
+	}
+
+	scope, ok := FromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrNoScope)
+		return
+	}
+
+	ctx := db.Statement.Context
+	assign := func(row reflect.Value) error {
+		value, zero := field.ValueOf(ctx, row)
+		if zero {
+			if scope.All {
+				return ErrNoOrganization
+			}
+			return field.Set(ctx, row, scope.OrganizationID)
+		}
+		if !scope.All && value != scope.OrganizationID {
+			return ErrWrongOrganization
+		}
+		return nil
+	}
+
+	rows := db.Statement.ReflectValue
+	switch rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			if err := assign(rows.Index(i)); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if err := assign(rows); err != nil {
+			db.AddError(err)
+		}
+	}
+}
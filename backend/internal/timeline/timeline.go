@@ -0,0 +1,201 @@
+// Package timeline records customer activity. Handlers write events in the
+// same transaction as the change they describe, and the customer timeline
+// endpoint reads them back as one time-ordered feed.
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"message-backend/internal/models"
+)
+
+// Actor is who caused an event
+type Actor struct {
+	Type string
+	ID   *uuid.UUID
+}
+
+// Staff returns the actor for a staff user
+func Staff(user *models.User) Actor {
+	if user == nil {
+		return Actor{Type: models.ActorSystem}
+	}
+	return Actor{Type: models.ActorStaff, ID: &user.ID}
+}
+
+// Customer is the actor for the customer themselves, e.g. through the Android app
+var Customer = Actor{Type: models.ActorCustomer}
+
+// System is the actor for background jobs
+var System = Actor{Type: models.ActorSystem}
+
+// Record appends an event to a customer's timeline
+func Record(tx *gorm.DB, customerID uuid.UUID, eventType string, actor Actor, summary string, data map[string]interface{}) error {
+	return tx.Create(newEvent(customerID, eventType, actor, summary, data, time.Now())).Error
+}
+
+// AddNote records a staff note and returns the created event
+func AddNote(tx *gorm.DB, customerID uuid.UUID, author *models.User, body string) (*models.CustomerEvent, error) {
+	event := newEvent(customerID, models.CustomerEventNoteAdded, Staff(author), body, map[string]interface{}{"body": body}, time.Now())
+	if err := tx.Create(event).Error; err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// RecordMessage adds a received message to its customer's timeline
+func RecordMessage(tx *gorm.DB, message *models.Message) error {
+	event := newEvent(message.CustomerID, models.CustomerEventMessageReceived, Customer, message.GetPreview(200),
+		map[string]interface{}{"sender": message.Sender, "priority": message.Priority, "starred": message.Starred},
+		message.Timestamp)
+	event.OrganizationID = message.OrganizationID
+	event.SubjectID = &message.ID
+	return tx.Create(event).Error
+}
+
+// RecordCustomerChanges compares a customer before and after an update and records
+// limit changes, activation changes and other profile edits as separate events
+func RecordCustomerChanges(tx *gorm.DB, before, after *models.Customer, actor Actor) error {
+	limits := map[string]interface{}{}
+	profile := map[string]interface{}{}
+
+	if before.TotalLimit != after.TotalLimit {
+		limits["total_limit"] = change(before.TotalLimit, after.TotalLimit)
+	}
+	if before.AvailableLimit != after.AvailableLimit {
+		limits["available_limit"] = change(before.AvailableLimit, after.AvailableLimit)
+	}
+
+	diffString(profile, "phone_number", before.PhoneNumber, after.PhoneNumber)
+	diffString(profile, "full_name", before.FullName, after.FullName)
+	diffString(profile, "name", before.Name, after.Name)
+	diffString(profile, "email", before.Email, after.Email)
+	diffString(profile, "device_id", before.DeviceID, after.DeviceID)
+	diffString(profile, "cardholder_name", before.CardholderName, after.CardholderName)
+	diffString(profile, "expiry_date", before.ExpiryDate, after.ExpiryDate)
+	if before.CardNumber != after.CardNumber {
+		profile["card_number"] = change(maskCard(before.CardNumber), maskCard(after.CardNumber))
+	}
+	if before.CVV != after.CVV {
+		profile["cvv"] = "changed"
+	}
+	if dateString(before.DOB) != dateString(after.DOB) {
+		profile["dob"] = change(dateString(before.DOB), dateString(after.DOB))
+	}
+
+	if len(limits) > 0 {
+		summary := fmt.Sprintf("Limits changed: total %.2f → %.2f, available %.2f → %.2f",
+			before.TotalLimit, after.TotalLimit, before.AvailableLimit, after.AvailableLimit)
+		if err := Record(tx, after.ID, models.CustomerEventLimitChanged, actor, summary, limits); err != nil {
+			return err
+		}
+	}
+
+	if len(profile) > 0 {
+		fields := make([]string, 0, len(profile))
+		for field := range profile {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		summary := "Profile updated: " + strings.Join(fields, ", ")
+		if err := Record(tx, after.ID, models.CustomerEventProfileUpdated, actor, summary, profile); err != nil {
+			return err
+		}
+	}
+
+	if before.IsActive != after.IsActive {
+		eventType, summary := models.CustomerEventDeactivated, "Customer deactivated"
+		if after.IsActive {
+			eventType, summary = models.CustomerEventActivated, "Customer activated"
+		}
+		if err := Record(tx, after.ID, eventType, actor, summary, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newEvent(customerID uuid.UUID, eventType string, actor Actor, summary string, data map[string]interface{}, at time.Time) *models.CustomerEvent {
+	if len(summary) > 255 {
+		summary = summary[:252] + "..."
+	}
+	return &models.CustomerEvent{
+		CustomerID: customerID,
+		Type:       eventType,
+		ActorType:  actor.Type,
+		ActorID:    actor.ID,
+		Summary:    summary,
+		Data:       data,
+		OccurredAt: at,
+	}
+}
+
+func change(from, to interface{}) map[string]interface{} {
+	return map[string]interface{}{"from": from, "to": to}
+}
+
+func diffString(changes map[string]interface{}, field, before, after string) {
+	if before != after {
+		changes[field] = change(before, after)
+	}
+}
+
+func dateString(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// maskCard keeps only the last four digits of a card number
+func maskCard(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+// Backfill creates customer_created and message_received events for customers
+// and messages that predate the timeline, returning how many events were added
+func Backfill(db *gorm.DB) (int64, error) {
+	var total int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		created := tx.Exec(`
+			INSERT INTO customer_events (organization_id, customer_id, type, actor_type, summary, occurred_at, created_at)
+			SELECT c.organization_id, c.id, ?, ?, 'Customer registered', c.created_at, NOW()
+			FROM customers AS c
+			WHERE NOT EXISTS (
+				SELECT 1 FROM customer_events AS e WHERE e.customer_id = c.id AND e.type = ?
+			)`, models.CustomerEventCreated, models.ActorCustomer, models.CustomerEventCreated)
+		if created.Error != nil {
+			return created.Error
+		}
+
+		received := tx.Exec(`
+			INSERT INTO customer_events (organization_id, customer_id, type, actor_type, subject_id, summary, data, occurred_at, created_at)
+			SELECT m.organization_id, m.customer_id, ?, ?, m.id, LEFT(m.content, 200),
+				jsonb_build_object('sender', m.sender, 'priority', m.priority, 'starred', m.starred),
+				m.timestamp, NOW()
+			FROM messages AS m
+			WHERE NOT EXISTS (
+				SELECT 1 FROM customer_events AS e WHERE e.subject_id = m.id AND e.type = ?
+			)`, models.CustomerEventMessageReceived, models.ActorCustomer, models.CustomerEventMessageReceived)
+		if received.Error != nil {
+			return received.Error
+		}
+
+		total = created.RowsAffected + received.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill customer timeline: %v", err)
+	}
+	return total, nil
+}
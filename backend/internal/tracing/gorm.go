@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// dbSpanKey holds a statement's span between the start and end callbacks
+const dbSpanKey = "tracing:span"
+
+// maxQueryLength caps the SQL recorded on a span
+const maxQueryLength = 2048
+
+var (
+	// stringLiteral and numberLiteral match values written into SQL by hand.
+	// GORM sends values as $n parameters, which are never recorded.
+	stringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteral = regexp.MustCompile(`([^\w$.])-?\d+(?:\.\d+)?\b`)
+)
+
+// Register installs the callbacks that give every statement run inside a
+// trace a span of its own, carrying the table, operation and sanitized SQL
+func Register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("*").Register("tracing:start", startDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Create().After("*").Register("tracing:end", endDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("*").Register("tracing:start", startDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("*").Register("tracing:end", endDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("*").Register("tracing:start", startDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("*").Register("tracing:end", endDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("*").Register("tracing:start", startDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("*").Register("tracing:end", endDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("*").Register("tracing:start", startDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("*").Register("tracing:end", endDBSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Raw().Before("*").Register("tracing:start", startDBSpan); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("*").Register("tracing:end", endDBSpan)
+}
+
+// startDBSpan opens the statement's span. Statements outside a trace, such as
+// the background pollers', aren't traced so they don't each start a trace.
+func startDBSpan(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if !traced(ctx) {
+		return
+	}
+	_, span := Start(ctx, "db", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL))
+	db.InstanceSet(dbSpanKey, span)
+}
+
+// endDBSpan names the span after the statement GORM built and records its
+// outcome. The SQL is recorded with its placeholders and literals, never values.
+func endDBSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(dbSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	query := db.Statement.SQL.String()
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+	name := operation
+	if table := db.Statement.Table; table != "" {
+		name += " " + table
+		span.SetAttributes(semconv.DBCollectionName(table))
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		span.SetName(name)
+	}
+	span.SetAttributes(semconv.DBOperationName(operation), semconv.DBQueryText(SanitizeSQL(query)))
+	if operation == "SELECT" {
+		span.SetAttributes(semconv.DBResponseReturnedRows(int(db.Statement.RowsAffected)))
+	} else {
+		span.SetAttributes(attribute.Int64("db.rows_affected", db.Statement.RowsAffected))
+	}
+
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Not finding a row is an answer, not a failure
+		err = nil
+	}
+	End(span, err)
+}
+
+// SanitizeSQL replaces string and number literals with ? and caps the length,
+// so hand-written SQL can't leak card numbers or other values into traces
+func SanitizeSQL(query string) string {
+	query = stringLiteral.ReplaceAllString(query, "?")
+	query = numberLiteral.ReplaceAllString(query, "${1}?")
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxQueryLength {
+		query = query[:maxQueryLength] + "..."
+	}
+	return query
+}
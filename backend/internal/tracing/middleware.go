@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"message-backend/internal/logging"
+)
+
+// untracedRoutes are polled by infrastructure and would only add noise
+var untracedRoutes = map[string]bool{
+	"/health":  true,
+	"/metrics": true,
+}
+
+// Middleware starts a server span for every request, continuing the trace in
+// its traceparent header if there is one. Handlers reach the span through the
+// gin context or the request's own context, and log lines carry its trace ID.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if untracedRoutes[route] {
+			c.Next()
+			return
+		}
+
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(spanKey, span)
+		if sc := span.SpanContext(); sc.IsValid() {
+			logging.Add(c, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
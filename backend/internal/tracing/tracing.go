@@ -0,0 +1,130 @@
+// Package tracing sets up OpenTelemetry tracing: a span per HTTP request,
+// child spans for every SQL statement, SMTP send and webhook call made while
+// serving it, and W3C trace context read from incoming requests and sent on
+// outgoing ones.
+//
+// Spans are exported over OTLP/HTTP, printed to stdout for local runs, or not
+// recorded at all, as TRACING_EXPORTER says.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"message-backend/internal/config"
+)
+
+// instrumentationName names the tracer every span in the application comes from
+const instrumentationName = "message-backend"
+
+// serviceVersion is reported on every span, matching the version /health reports
+const serviceVersion = "1.0.0"
+
+// spanKey holds the request's span in gin.Context.Keys, which gin exposes through Value
+const spanKey = "tracing.span"
+
+// Setup installs the tracer provider and W3C propagator described by the
+// TRACING_* settings. The returned function flushes buffered spans and should
+// be called before exiting.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	// Trace context passes through even when nothing is recorded, so a caller's
+	// trace continues into the webhooks we send
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TracingExporter {
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimRight(cfg.TracingOTLPEndpoint, "/")+"/v1/traces"),
+			otlptracehttp.WithHeaders(parseHeaders(cfg.TracingOTLPHeaders)))
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		// The global provider is a no-op until one is set
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.TracingExporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.TracingServiceName),
+		semconv.ServiceVersion(serviceVersion),
+		semconv.DeploymentEnvironmentName(cfg.AppEnv),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// parseHeaders turns key=value entries into export headers
+func parseHeaders(entries []string) map[string]string {
+	headers := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
+
+// Start starts a span as a child of the one in ctx, which may be a gin.Context
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(withSpan(ctx), name, opts...)
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject writes the trace context of ctx into outgoing headers
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(withSpan(ctx), carrier)
+}
+
+// withSpan returns ctx with the request's span attached where OpenTelemetry
+// looks for it. A gin.Context only exposes its Keys through Value, so the span
+// the middleware stored there is moved onto the context.
+func withSpan(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if span, ok := ctx.Value(spanKey).(trace.Span); ok {
+		return trace.ContextWithSpan(ctx, span)
+	}
+	return ctx
+}
+
+// traced reports whether ctx belongs to a trace, so work done outside one,
+// such as background polling, doesn't start traces of its own
+func traced(ctx context.Context) bool {
+	return trace.SpanContextFromContext(withSpan(ctx)).IsValid()
+}
@@ -0,0 +1,6 @@
+package types
+
+// AcknowledgeAlertRequest for marking an alert as handled
+type AcknowledgeAlertRequest struct {
+	Note string `json:"note" binding:"max=1000"` // What was done about it (optional)
+}
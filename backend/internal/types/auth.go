@@ -23,7 +23,7 @@ type CreateUserRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email,max=100"`
 	Password string `json:"password" binding:"required,min=8"`
-	Role     string `json:"role" binding:"required,oneof=super_admin admin user"`
+	Role     string `json:"role" binding:"required,max=20"`
 }
 
 // AuthResponse represents the authentication response with both tokens
@@ -0,0 +1,40 @@
+package types
+
+// CreateCaseRequest for opening a case on a customer
+type CreateCaseRequest struct {
+	CustomerID  string   `json:"customer_id" binding:"required"`
+	Title       string   `json:"title" binding:"required,max=200"`
+	Description string   `json:"description"`
+	Severity    string   `json:"severity" binding:"required,oneof=low medium high critical"`
+	AssigneeID  string   `json:"assignee_id"`                   // Optional
+	MessageIDs  []string `json:"message_ids" binding:"max=500"` // Related messages (optional)
+	AlertIDs    []string `json:"alert_ids" binding:"max=100"`   // Alerts whose messages are linked and which get acknowledged (optional)
+}
+
+// UpdateCaseRequest for editing a case's details
+type UpdateCaseRequest struct {
+	Title       *string `json:"title" binding:"omitempty,min=1,max=200"`
+	Description *string `json:"description"`
+	Severity    *string `json:"severity" binding:"omitempty,oneof=low medium high critical"` // Recomputes SLA due dates
+}
+
+// AssignCaseRequest for assigning a case. A null assignee_id unassigns it.
+type AssignCaseRequest struct {
+	AssigneeID *string `json:"assignee_id"`
+}
+
+// TransitionCaseRequest for moving a case to another status
+type TransitionCaseRequest struct {
+	Status string `json:"status" binding:"required,oneof=open investigating resolved false_positive"`
+	Note   string `json:"note" binding:"max=5000"` // Stored as a comment (optional)
+}
+
+// CaseCommentRequest for commenting on a case
+type CaseCommentRequest struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+// CaseMessagesRequest for linking messages to or unlinking them from a case
+type CaseMessagesRequest struct {
+	MessageIDs []string `json:"message_ids" binding:"required,min=1,max=500"`
+}
@@ -18,17 +18,18 @@ type CreateCustomerRequest struct {
 
 // UpdateCustomerRequest for admin updating customer info
 type UpdateCustomerRequest struct {
-	PhoneNumber    string  `json:"phone_number"`
-	FullName       string  `json:"full_name"`
-	Email          string  `json:"email"`
-	Name           string  `json:"name"`
-	DOB            string  `json:"dob"` // Format: YYYY-MM-DD
-	TotalLimit     float64 `json:"total_limit"`
-	AvailableLimit float64 `json:"available_limit"`
-	CardholderName string  `json:"cardholder_name"`
-	CardNumber     string  `json:"card_number"`
-	ExpiryDate     string  `json:"expiry_date"` // Format: MM/YY
-	CVV            string  `json:"cvv"`
+	PhoneNumber    string   `json:"phone_number"`
+	FullName       string   `json:"full_name"`
+	Email          string   `json:"email"`
+	Name           string   `json:"name"`
+	DOB            string   `json:"dob"` // Format: YYYY-MM-DD
+	TotalLimit     *float64 `json:"total_limit" binding:"omitempty,gt=0"`
+	AvailableLimit *float64 `json:"available_limit" binding:"omitempty,gte=0"`
+	CardholderName string   `json:"cardholder_name"`
+	CardNumber     string   `json:"card_number"`
+	ExpiryDate     string   `json:"expiry_date"` // Format: MM/YY
+	CVV            string   `json:"cvv"`
+	IsActive       *bool    `json:"is_active"`
 }
 
 // CustomerSelfUpdateRequest for customers updating their own info
@@ -49,4 +50,9 @@ type TopCustomer struct {
 	Email   string `json:"email"`
 	Status  string `json:"status"`
 	Balance string `json:"balance"`
-}
\ No newline at end of file
+}
+
+// CreateCustomerNoteRequest for adding an admin note to a customer's timeline
+type CreateCustomerNoteRequest struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
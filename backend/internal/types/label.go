@@ -0,0 +1,29 @@
+package types
+
+import "message-backend/internal/models"
+
+// CreateLabelRequest for adding a label
+type CreateLabelRequest struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	Color       string `json:"color" binding:"omitempty,hexcolor"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+// UpdateLabelRequest for renaming or recoloring a label
+type UpdateLabelRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Color       *string `json:"color" binding:"omitempty,hexcolor"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
+}
+
+// BulkLabelRequest for adding labels to or removing labels from many messages at once
+type BulkLabelRequest struct {
+	MessageIDs []string `json:"message_ids" binding:"required,min=1,max=500"`
+	LabelIDs   []string `json:"label_ids" binding:"required,min=1,max=50"`
+}
+
+// LabelWithCount is a label with the number of live messages carrying it
+type LabelWithCount struct {
+	models.Label
+	MessageCount int64 `json:"message_count"`
+}
@@ -7,6 +7,39 @@ type CreateMessageRequest struct {
 	CustomerID string    `json:"customer_id" binding:"required"` // Use customer UUID directly
 	Content    string    `json:"content" binding:"required"`     // SMS content
 	Timestamp  time.Time `json:"timestamp"`                      // When SMS was received (optional)
+	ClientID   string    `json:"client_id"`                      // Client-generated ID (optional)
+	Sender     string    `json:"sender" binding:"max=50"`        // SMS sender address (optional)
+}
+
+// BatchMessageItem is a single message inside a batch upload
+type BatchMessageItem struct {
+	ClientID   string    `json:"client_id"`   // Client-generated ID, used to detect retried uploads
+	CustomerID string    `json:"customer_id"` // Use customer UUID directly
+	Sender     string    `json:"sender"`      // SMS sender address (optional)
+	Content    string    `json:"content"`     // SMS content
+	Timestamp  time.Time `json:"timestamp"`   // When SMS was received; required without client_id
+}
+
+// BatchCreateMessageRequest for uploading several messages from Android app in one request
+type BatchCreateMessageRequest struct {
+	Messages []BatchMessageItem `json:"messages" binding:"required,min=1"`
+}
+
+// BatchMessageResult reports what happened to a single item of a batch upload
+type BatchMessageResult struct {
+	Index     int    `json:"index"`
+	ClientID  string `json:"client_id,omitempty"`
+	Status    string `json:"status"` // created, duplicate or failed
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchCreateMessageResponse summarizes a batch upload
+type BatchCreateMessageResponse struct {
+	Results   []BatchMessageResult `json:"results"`
+	Created   int                  `json:"created"`
+	Duplicate int                  `json:"duplicate"`
+	Failed    int                  `json:"failed"`
 }
 
 // UpdateMessageRequest for updating message properties
@@ -14,12 +47,26 @@ type UpdateMessageRequest struct {
 	Starred *bool `json:"starred"` // Nullable boolean for starring/unstarring
 }
 
+// MessageSearchResult is a single full-text search hit
+type MessageSearchResult struct {
+	ID           string    `json:"id"`
+	CustomerID   string    `json:"customer_id"`
+	CustomerName string    `json:"customer_name"`
+	Sender       string    `json:"sender"`
+	Content      string    `json:"content"`
+	Snippet      string    `json:"snippet"` // Content excerpt with matches wrapped in <mark> tags
+	Rank         float64   `json:"rank"`
+	Timestamp    time.Time `json:"timestamp"`
+	Starred      bool      `json:"starred"`
+}
+
 // RecentMessage represents a message for display
 type RecentMessage struct {
-	ID      string `json:"id"`
-	Sender  string `json:"sender"`
-	Subject string `json:"subject"`
-	Preview string `json:"preview"`
-	Date    string `json:"date"`
-	Status  string `json:"status"`
+	ID      string   `json:"id"`
+	Sender  string   `json:"sender"`
+	Subject string   `json:"subject"`
+	Preview string   `json:"preview"`
+	Date    string   `json:"date"`
+	Status  string   `json:"status"`
+	Labels  []string `json:"labels,omitempty"` // Label names
 }
@@ -0,0 +1,24 @@
+package types
+
+// OrganizationSettingsRequest changes an organization's settings. Omitted fields keep their value.
+type OrganizationSettingsRequest struct {
+	SignalsEnabled *bool   `json:"signals_enabled"`
+	HomeCurrency   *string `json:"home_currency" binding:"omitempty,len=3,alpha"`
+	Timezone       *string `json:"timezone" binding:"omitempty,max=64"`
+	AllowSignup    *bool   `json:"allow_signup"`
+}
+
+// CreateOrganizationRequest for creating an organization
+type CreateOrganizationRequest struct {
+	Name     string                       `json:"name" binding:"required,max=100"`
+	Slug     string                       `json:"slug" binding:"required,min=2,max=50"`
+	Settings *OrganizationSettingsRequest `json:"settings"`
+}
+
+// UpdateOrganizationRequest for renaming, deactivating or reconfiguring an organization.
+// Slugs are fixed because the Android app and X-Organization-ID headers use them.
+type UpdateOrganizationRequest struct {
+	Name     *string                      `json:"name" binding:"omitempty,max=100"`
+	IsActive *bool                        `json:"is_active"`
+	Settings *OrganizationSettingsRequest `json:"settings"`
+}
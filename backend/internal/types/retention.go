@@ -0,0 +1,26 @@
+package types
+
+// CreateRetentionPolicyRequest for adding a retention policy
+type CreateRetentionPolicyRequest struct {
+	Name           string `json:"name" binding:"required,max=100"`
+	Description    string `json:"description" binding:"max=255"`
+	ContentPattern string `json:"content_pattern" binding:"max=255"` // Case-insensitive regex, e.g. \mOTP\M
+	SenderPattern  string `json:"sender_pattern" binding:"max=255"`
+	RetainDays     int    `json:"retain_days" binding:"required,min=1"`
+	IsActive       *bool  `json:"is_active"`
+}
+
+// UpdateRetentionPolicyRequest for changing a retention policy
+type UpdateRetentionPolicyRequest struct {
+	Name           *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description    *string `json:"description" binding:"omitempty,max=255"`
+	ContentPattern *string `json:"content_pattern" binding:"omitempty,max=255"`
+	SenderPattern  *string `json:"sender_pattern" binding:"omitempty,max=255"`
+	RetainDays     *int    `json:"retain_days" binding:"omitempty,min=1"`
+	IsActive       *bool   `json:"is_active"`
+}
+
+// LegalHoldRequest for placing or releasing a legal hold
+type LegalHoldRequest struct {
+	LegalHold *bool `json:"legal_hold" binding:"required"`
+}
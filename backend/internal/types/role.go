@@ -0,0 +1,25 @@
+package types
+
+import (
+	"message-backend/internal/models"
+)
+
+// CreateRoleRequest for creating a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=20"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" binding:"required,min=1,dive,required"`
+}
+
+// UpdateRoleRequest for changing a role's description or permissions. Names are
+// fixed because users reference roles by name.
+type UpdateRoleRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions" binding:"omitempty,min=1,dive,required"`
+}
+
+// RoleWithUsage is a role with the number of users holding it
+type RoleWithUsage struct {
+	models.Role
+	UserCount int64 `json:"user_count"`
+}
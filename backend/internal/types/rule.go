@@ -0,0 +1,40 @@
+package types
+
+import "message-backend/internal/models"
+
+// CreateRuleRequest for adding a message rule
+type CreateRuleRequest struct {
+	Name           string                `json:"name" binding:"required,max=100"`
+	Description    string                `json:"description" binding:"max=255"`
+	Priority       *int                  `json:"priority"` // Lower runs first, defaults to 100
+	StopProcessing bool                  `json:"stop_processing"`
+	IsActive       *bool                 `json:"is_active"`
+	Conditions     models.RuleConditions `json:"conditions"`
+	Actions        []models.RuleAction   `json:"actions" binding:"required,min=1"`
+}
+
+// UpdateRuleRequest for changing a message rule. Every update creates a new version.
+type UpdateRuleRequest struct {
+	Name           *string                `json:"name" binding:"omitempty,min=1,max=100"`
+	Description    *string                `json:"description" binding:"omitempty,max=255"`
+	Priority       *int                   `json:"priority"`
+	StopProcessing *bool                  `json:"stop_processing"`
+	IsActive       *bool                  `json:"is_active"`
+	Conditions     *models.RuleConditions `json:"conditions"`
+	Actions        []models.RuleAction    `json:"actions" binding:"omitempty,min=1"`
+}
+
+// RuleSample is a message to test rules against
+type RuleSample struct {
+	Sender     string `json:"sender"`
+	Content    string `json:"content" binding:"required"`
+	CustomerID string `json:"customer_id"` // Needed for segment conditions
+}
+
+// TestRuleRequest evaluates a stored rule, an unsaved definition or all active rules against a sample
+type TestRuleRequest struct {
+	RuleID     string                 `json:"rule_id"`
+	Conditions *models.RuleConditions `json:"conditions"`
+	Actions    []models.RuleAction    `json:"actions"`
+	Sample     RuleSample             `json:"sample" binding:"required"`
+}
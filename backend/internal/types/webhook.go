@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+// CreateWebhookRequest for registering a webhook endpoint
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=500"`
+	Description string   `json:"description" binding:"max=255"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+// UpdateWebhookRequest for changing a webhook endpoint
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" binding:"omitempty,url,max=500"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Events      []string `json:"events"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+// WebhookCustomer is the customer representation sent to webhooks. Card
+// number is masked and CVV is never included.
+type WebhookCustomer struct {
+	ID             string  `json:"id"`
+	PhoneNumber    string  `json:"phone_number"`
+	FullName       string  `json:"full_name"`
+	Email          string  `json:"email"`
+	Name           string  `json:"name"`
+	IsActive       bool    `json:"is_active"`
+	MessageCount   int     `json:"message_count"`
+	TotalLimit     float64 `json:"total_limit"`
+	AvailableLimit float64 `json:"available_limit"`
+	CardLast4      string  `json:"card_last4"`
+	Deleted        bool    `json:"deleted"`
+}
+
+// WebhookUser is the user representation sent to webhooks: enough to identify
+// the account, without its email, role or approval details
+type WebhookUser struct {
+	ID         string     `json:"id"`
+	Username   string     `json:"username"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+}
@@ -1,14 +1,36 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"message-backend/internal/config"
+	"message-backend/internal/tracing"
 	"net/smtp"
+	"strconv"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// SendSuperAdminCredentials sends real emails via SMTP
-func SendSuperAdminCredentials(username, email, password string) error {
-	cfg := config.LoadConfig()
+// Mailer sends the application's emails
+type Mailer interface {
+	SendSuperAdminCredentials(ctx context.Context, username, email, password string) error
+}
+
+// SMTPMailer sends emails through the configured SMTP server to ADMIN_EMAIL
+type SMTPMailer struct {
+	cfg *config.Config
+}
+
+// NewSMTPMailer returns a mailer using the SMTP settings of cfg
+func NewSMTPMailer(cfg *config.Config) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// SendSuperAdminCredentials sends real emails via SMTP, traced as part of the
+// request in ctx
+func (m *SMTPMailer) SendSuperAdminCredentials(ctx context.Context, username, email, password string) error {
+	cfg := m.cfg
 
 	//Email content
 	subject := "Super Admin Credentials Generated"
@@ -30,6 +52,9 @@ func SendSuperAdminCredentials(username, email, password string) error {
 		"%s\r\n", cfg.AdminEmail, subject, body))
 
 	// send email
+	port, _ := strconv.Atoi(cfg.SMTPPort)
+	_, span := tracing.Start(ctx, "smtp.SendMail", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.ServerAddress(cfg.SMTPHost), semconv.ServerPort(port)))
 	err := smtp.SendMail(
 		fmt.Sprintf("%s:%s", cfg.SMTPHost, cfg.SMTPPort),
 		auth,
@@ -37,5 +62,6 @@ func SendSuperAdminCredentials(username, email, password string) error {
 		[]string{cfg.AdminEmail},
 		msg,
 	)
+	tracing.End(span, err)
 	return err
 }
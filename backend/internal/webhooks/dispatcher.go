@@ -0,0 +1,274 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"message-backend/internal/metrics"
+	"message-backend/internal/models"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is marked failed
+	MaxAttempts = 8
+
+	pollInterval   = 2 * time.Second
+	claimBatchSize = 20
+	claimLease     = 2 * time.Minute // Claimed rows are retried if an instance dies mid-delivery
+	requestTimeout = 10 * time.Second
+	baseBackoff    = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	maxBodyStored  = 2048
+
+	// deliveryRetrying is the metrics result of a failed attempt that will be retried
+	deliveryRetrying = "retrying"
+)
+
+// ErrBlockedAddress is returned for endpoints that resolve to loopback,
+// link-local, private or otherwise internal addresses
+var ErrBlockedAddress = errors.New("webhook endpoint resolves to a blocked address")
+
+// Dispatcher delivers queued webhook events
+type Dispatcher struct {
+	db      *gorm.DB
+	client  *http.Client
+	metrics *metrics.Metrics
+}
+
+// NewDispatcher creates a dispatcher using the given database, counting deliveries in m
+func NewDispatcher(db *gorm.DB, m *metrics.Metrics) *Dispatcher {
+	return &Dispatcher{
+		db:      db,
+		metrics: m,
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: publicTransport(),
+		},
+	}
+}
+
+// publicTransport only connects to public addresses, so an endpoint URL can't
+// be used to reach internal services. The check runs on the address actually
+// dialed, which covers redirects and DNS that changes after the URL was saved.
+// Proxies are ignored for the same reason.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: requestTimeout,
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, internal like the private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// blockedIP reports whether ip is loopback, link-local (including cloud
+// metadata services), private, unspecified or multicast
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// Run polls for due deliveries until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				deliveries, err := d.claim(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						slog.ErrorContext(ctx, "Failed to claim webhook deliveries", slog.Any("error", err))
+					}
+					break
+				}
+				for i := range deliveries {
+					d.deliver(ctx, &deliveries[i])
+				}
+				if len(deliveries) < claimBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// claim locks due deliveries and pushes their next attempt out by the lease so
+// other instances skip them while they are being sent
+func (d *Dispatcher) claim(ctx context.Context) ([]models.WebhookDelivery, error) {
+	// One queue serves every organization; endpoints are loaded with their deliveries
+	var deliveries []models.WebhookDelivery
+	err := d.db.WithContext(tenancy.System(ctx)).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Preload("Endpoint").
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryStatusPending, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(claimBatchSize).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]interface{}, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(claimLease)).Error
+	})
+	return deliveries, err
+}
+
+// deliver sends a single delivery and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        delivery.Attempts + 1,
+		"last_attempt_at": now,
+	}
+
+	if delivery.Endpoint == nil || !delivery.Endpoint.IsActive {
+		updates["status"] = models.DeliveryStatusFailed
+		updates["last_error"] = "endpoint removed or disabled"
+		d.db.Model(delivery).Updates(updates)
+		d.metrics.WebhookDeliveries.Inc(models.DeliveryStatusFailed)
+		return
+	}
+
+	status, body, err := d.send(ctx, delivery)
+	updates["response_status"] = status
+	updates["response_body"] = body
+
+	result := deliveryRetrying
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		result = models.DeliveryStatusSucceeded
+		updates["status"] = models.DeliveryStatusSucceeded
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case delivery.Attempts+1 >= MaxAttempts:
+		result = models.DeliveryStatusFailed
+		updates["status"] = models.DeliveryStatusFailed
+		updates["last_error"] = describeFailure(status, err)
+	default:
+		updates["next_attempt_at"] = now.Add(Backoff(delivery.Attempts + 1))
+		updates["last_error"] = describeFailure(status, err)
+	}
+	d.metrics.WebhookDeliveries.Inc(result)
+
+	if err := d.db.Model(delivery).Updates(updates).Error; err != nil {
+		slog.Error("Failed to record webhook delivery", slog.String("delivery_id", delivery.ID.String()), slog.Any("error", err))
+	}
+}
+
+// send POSTs the signed payload and returns the response status and a truncated body.
+// Each attempt is traced, and the endpoint receives the trace in traceparent.
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (status int, respBody string, err error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	ctx, span := tracing.Start(ctx, "POST webhook", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(http.MethodPost),
+			attribute.String("webhook.event", delivery.Event),
+			attribute.String("webhook.delivery_id", delivery.ID.String()),
+			attribute.Int("webhook.attempt", delivery.Attempts+1),
+		))
+	defer func() {
+		if status != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		}
+		if err == nil && (status < 200 || status >= 300) {
+			span.SetStatus(codes.Error, describeFailure(status, nil))
+		}
+		tracing.End(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	span.SetAttributes(semconv.ServerAddress(req.URL.Hostname()))
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bank-app-webhooks/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.String())
+	req.Header.Set("X-Webhook-Timestamp", formatTimestamp(timestamp))
+	req.Header.Set("X-Webhook-Signature", Sign(delivery.Endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	stored, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyStored))
+	return resp.StatusCode, string(stored), nil
+}
+
+// Backoff returns the wait before the next attempt: 30s doubled per attempt,
+// capped at 6h, with up to 20% jitter so retries from an outage spread out
+func Backoff(attempt int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+func describeFailure(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("endpoint responded with HTTP %d", status)
+}
+
+func formatTimestamp(timestamp int64) string {
+	return strconv.FormatInt(timestamp, 10)
+}
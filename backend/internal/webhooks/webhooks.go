@@ -0,0 +1,122 @@
+// Package webhooks queues signed event notifications for registered endpoints
+// and delivers them in the background with exponential retry.
+//
+// Events are written to the webhook_deliveries table inside the transaction
+// that caused them, so a delivery exists if and only if the change committed.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"message-backend/internal/models"
+	"message-backend/internal/types"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Envelope is the JSON body POSTed to webhook endpoints
+type Envelope struct {
+	ID        string      `json:"id"` // Unique per event, shared by every endpoint receiving it
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Enqueue queues an event for every active endpoint subscribed to it
+func Enqueue(tx *gorm.DB, event string, data interface{}) error {
+	var endpoints []models.WebhookEndpoint
+	if err := tx.Where("is_active = ?", true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	var payload []byte
+	now := time.Now()
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event) {
+			continue
+		}
+
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(Envelope{
+				ID:        uuid.NewString(),
+				Event:     event,
+				CreatedAt: now.UTC(),
+				Data:      data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return tx.Create(&deliveries).Error
+}
+
+// Sign returns the signature header value for a payload: the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the endpoint secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(formatTimestamp(timestamp)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret creates a new random endpoint secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// CustomerPayload converts a customer into its webhook representation
+func CustomerPayload(customer *models.Customer) types.WebhookCustomer {
+	cardLast4 := ""
+	if len(customer.CardNumber) >= 4 {
+		cardLast4 = customer.CardNumber[len(customer.CardNumber)-4:]
+	}
+
+	return types.WebhookCustomer{
+		ID:             customer.ID.String(),
+		PhoneNumber:    customer.PhoneNumber,
+		FullName:       customer.FullName,
+		Email:          customer.Email,
+		Name:           customer.Name,
+		IsActive:       customer.IsActive,
+		MessageCount:   customer.MessageCount,
+		TotalLimit:     customer.TotalLimit,
+		AvailableLimit: customer.AvailableLimit,
+		CardLast4:      cardLast4,
+		Deleted:        customer.DeletedAt.Valid,
+	}
+}
+
+// UserPayload converts a user into its webhook representation
+func UserPayload(user *models.User) types.WebhookUser {
+	return types.WebhookUser{
+		ID:         user.ID.String(),
+		Username:   user.Username,
+		ApprovedAt: user.ApprovedAt,
+	}
+}
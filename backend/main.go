@@ -4,27 +4,62 @@ import (
 	// standard-modules
 	"context"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	// local-modules
+	"message-backend/internal/app"
+	"message-backend/internal/audit"
 	"message-backend/internal/config"
 	"message-backend/internal/database"
 	"message-backend/internal/handlers"
+	"message-backend/internal/logging"
+	"message-backend/internal/metrics"
 	"message-backend/internal/middleware"
 	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/repository"
+	"message-backend/internal/retention"
+	"message-backend/internal/server"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/tracing"
 	"message-backend/internal/utils"
+	"message-backend/internal/webhooks"
 
 	// external modules
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// Load configuration from environment variables
-	cfg := config.LoadConfig()
+	// Run a maintenance command instead of the server when one is given
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// Load configuration once for every component
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("❌ Invalid configuration:\n%v", err)
+	}
+
+	// Structured logs from here on; the standard log package goes through them too
+	logging.Setup(cfg)
+
+	// Tracing, exported as TRACING_EXPORTER says. Buffered spans are flushed on exit.
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ServerShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", slog.Any("error", err))
+		}
+	}()
 
 	// set Gin mode based on environment
 	if cfg.AppEnv == "production" {
@@ -33,84 +68,162 @@ func main() {
 		gin.SetMode(gin.DebugMode)
 	}
 
-	db, err := database.InitDB()
+	db, err := database.InitDB(cfg)
 	if err != nil {
-		log.Fatalf("❌ Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", err)
 	}
 	defer database.CloseDB()
 
-	// Auto migrate models
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.Customer{},
-		&models.Message{}, // Add Message model
-	); err != nil {
-		log.Fatalf("❌ Failed to migrate database: %v", err)
+	// The dependencies every handler shares. Nothing in it touches the
+	// database until it is used, so it can be built before migrating.
+	a := app.New(cfg, db)
+
+	// Connection pool statistics for /metrics
+	if sqlDB, err := db.DB(); err == nil {
+		a.Metrics.RegisterDBStats(sqlDB)
+	}
+
+	// A span for every statement run while serving a traced request
+	if err := tracing.Register(db); err != nil {
+		fatal("Failed to register tracing callbacks", err)
+	}
+
+	// Schema migrations. Replicas starting together take turns on an advisory lock.
+	if err := migrateOnStart(cfg, db); err != nil {
+		fatal("Failed to migrate database", err)
 	}
 
-	// Gin router
+	// Roles, seeded with the original super_admin, admin and user roles
+	if err := rbac.Seed(db); err != nil {
+		fatal("Failed to seed roles", err)
+	}
+
+	// Every query on a tenant table is scoped to an organization from here on
+	if err := tenancy.Register(db); err != nil {
+		fatal("Failed to register tenant scope", err)
+	}
+
+	// Realtime message feed, fed by Postgres LISTEN/NOTIFY
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go a.Broker.Run(backgroundCtx)
+
+	// Background webhook delivery
+	go webhooks.NewDispatcher(db, a.Metrics).Run(backgroundCtx)
+
+	// Scheduled retention purge
+	if cfg.RetentionEnabled {
+		go retention.NewPurger(db, cfg.RetentionBatchSize).Run(backgroundCtx, cfg.RetentionInterval)
+	}
+
+	// Gin router. Every request gets an ID, a trace span, a log line, a latency
+	// sample and panic recovery.
 	router := gin.New()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
-
-	// Setup routes
-	setupRoutes(router)
-
-	// Create server with timeout
-	server := &http.Server{
-		Addr:         cfg.ServerHost + ":" + cfg.ServerPort,
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	// Starting server in goroutine
-	go func() {
-		log.Printf("🚀 Server starting on %s:%s", cfg.ServerHost, cfg.ServerPort)
-		log.Printf("📊 Environment: %s", cfg.AppEnv)
-		log.Printf("🔧 Debug mode: %t", cfg.AppDebug)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("❌ Failed to start server: %v", err)
+	router.Use(middleware.RequestID())
+	router.Use(tracing.Middleware())
+	router.Use(middleware.RequestLogger())
+	router.Use(a.Metrics.Middleware())
+	router.Use(middleware.Recovery())
+
+	// Metrics, on their own listener unless METRICS_ADDRESS is empty. On the
+	// API port they are registered before setupRoutes so scrapes skip CORS and
+	// the audit log, and config validation has required a token.
+	if cfg.MetricsEnabled {
+		if cfg.MetricsAddress != "" {
+			go metrics.Serve(backgroundCtx, cfg.MetricsAddress, a.Metrics.Handler(cfg.MetricsToken))
+		} else {
+			router.GET("/metrics", gin.WrapH(a.Metrics.Handler(cfg.MetricsToken)))
 		}
-	}()
+	}
 
-	// Wait for interrupt signal for graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("🛑 Shutting down server...")
+	// Only believe forwarded client IPs from the configured proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("❌ Server forced to shutdown: %v", err)
+	// Setup routes with the dependencies every handler shares
+	setupRoutes(router, a,
+		repository.NewCustomerRepository(db), repository.NewMessageRepository(db), repository.NewUserRepository(db))
+
+	// Create server with the configured timeouts, protocols and TLS
+	srv, err := server.New(cfg, router)
+	if err != nil {
+		fatal("Failed to configure server", err)
 	}
 
-	log.Println("✅ Server exited properly")
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	slog.Info("Server starting",
+		slog.String("address", scheme+"://"+cfg.ServerHost+":"+cfg.ServerPort),
+		slog.String("environment", cfg.AppEnv),
+		slog.Bool("debug", cfg.AppDebug))
+
+	// Serve until an interrupt signal, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Run(ctx); err != nil {
+		fatal("Server stopped", err)
+	}
+
+	slog.Info("Server exited properly")
+}
+
+// fatal logs a startup or serving failure and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
 
-func setupRoutes(router *gin.Engine) {
+// setupRoutes registers every route. Handlers reach customers, messages and
+// users through the given repositories, so tests can pass in-memory ones.
+func setupRoutes(router *gin.Engine, a *app.App, customers repository.CustomerRepository,
+	messages repository.MessageRepository, users repository.UserRepository) {
 	// Add CORS middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(a.Config))
+
+	// Append mutating requests to the audit log
+	router.Use(audit.Middleware(a.DB))
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler()
-	adminHandler := handlers.NewAdminHandler()
-	customerHandler := handlers.NewCustomerHandler()
-	messageHandler := handlers.NewMessageHandler()
-	authMiddleware := middleware.NewAuthMiddleware()
+	authHandler := handlers.NewAuthHandler(a, users)
+	adminHandler := handlers.NewAdminHandler(a, customers, messages, users)
+	customerHandler := handlers.NewCustomerHandler(a, customers)
+	messageHandler := handlers.NewMessageHandler(a, customers, messages)
+	streamHandler := handlers.NewStreamHandler(a)
+	webhookHandler := handlers.NewWebhookHandler(a)
+	retentionHandler := handlers.NewRetentionHandler(a)
+	trashHandler := handlers.NewTrashHandler(a)
+	ruleHandler := handlers.NewRuleHandler(a)
+	labelHandler := handlers.NewLabelHandler(a)
+	alertHandler := handlers.NewAlertHandler(a)
+	caseHandler := handlers.NewCaseHandler(a)
+	timelineHandler := handlers.NewTimelineHandler(a)
+	auditHandler := handlers.NewAuditHandler(a)
+	roleHandler := handlers.NewRoleHandler(a)
+	organizationHandler := handlers.NewOrganizationHandler(a)
+	seedHandler := handlers.NewSeedHandler(a)
+	authMiddleware := middleware.NewAuthMiddleware(a, users)
 
 	// ========================
-	// HIDDEN SEED ROUTES (No authentication, only secret key)
+	// SEED ROUTES (No authentication, only secret key). Off by default: use
+	// bankctl to manage admin accounts.
 	// ========================
-	router.POST("/_seed/create-super-admin", handlers.SeedSuperAdmin)
-	router.POST("/_seed/recover-super-admin", handlers.RecoverSuperAdmin)
-	router.POST("/_seed/reset-admin", handlers.ResetSuperAdmin)
-	router.GET("/_seed/health", func(c *gin.Context) {
-		utils.Success(c, "Seed endpoints are available", gin.H{"available": true})
-	})
+	cfg := a.Config
+	if cfg.SeedRoutesEnabled {
+		if handlers.SeedKeyUsable(cfg.SuperAdminSeedKey) {
+			router.POST("/_seed/create-super-admin", seedHandler.SeedSuperAdmin)
+			router.POST("/_seed/recover-super-admin", seedHandler.RecoverSuperAdmin)
+			router.POST("/_seed/reset-admin", seedHandler.ResetSuperAdmin)
+			router.GET("/_seed/health", func(c *gin.Context) {
+				utils.Success(c, "Seed endpoints are available", gin.H{"available": true})
+			})
+			slog.Warn("Seed routes are enabled, disable them once a super admin exists")
+		} else {
+			slog.Warn("Seed routes not enabled: SUPER_ADMIN_SEED_KEY is too short", slog.Int("min_length", handlers.MinSeedKeyLength))
+		}
+	}
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -127,46 +240,153 @@ func setupRoutes(router *gin.Engine) {
 		auth := apiV1.Group("/auth")
 		{
 			auth.POST("/signup", authHandler.Signup)
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", authHandler.Logout)
-			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/login", audit.Skip(), authHandler.Login)
+			auth.POST("/logout", audit.Skip(), authHandler.Logout)
+			auth.POST("/refresh", audit.Skip(), authHandler.RefreshToken)
 		}
 
-		// Protected routes (authenticated users)
+		// Protected routes (authenticated users). Each route names the
+		// permissions it needs; roles grant permissions (see internal/rbac)
 		protected := apiV1.Group("")
 		protected.Use(authMiddleware.JWTAuth())
 		{
+			can := authMiddleware.RequirePermission
+
 			// User profile
 			protected.GET("/profile", authHandler.GetProfile)
 
+			// The organization the request acts in
+			protected.GET("/organization", organizationHandler.GetCurrentOrganization)
+			protected.PUT("/organization/settings", can(models.PermOrgSettings), organizationHandler.UpdateCurrentSettings)
+
+			// Organizations (tenants)
+			orgRoutes := protected.Group("/organizations", can(models.PermOrgsManage))
+			{
+				orgRoutes.GET("", organizationHandler.GetOrganizations)
+				orgRoutes.POST("", organizationHandler.CreateOrganization)
+				orgRoutes.GET("/:id", organizationHandler.GetOrganization)
+				orgRoutes.PUT("/:id", organizationHandler.UpdateOrganization)
+			}
+
 			// ========================
 			// GENERAL ENDPOINTS (Dashboard/Analytics)
 			// ========================
-			protected.GET("/stats", adminHandler.GetStats)
-			protected.GET("/messages/recent", messageHandler.GetRecentMessages)
-			protected.GET("/customers/top", customerHandler.GetTopCustomers)
-			protected.GET("/customers/search", customerHandler.SearchCustomers)
+			protected.GET("/stats", can(models.PermStatsRead), adminHandler.GetStats)
+			protected.GET("/messages/recent", can(models.PermMessagesRead), messageHandler.GetRecentMessages)
+			protected.GET("/customers/top", can(models.PermCustomersRead), customerHandler.GetTopCustomers)
+			protected.GET("/customers/search", can(models.PermCustomersRead), customerHandler.SearchCustomers)
+
+			// Message labels
+			protected.GET("/labels", can(models.PermMessagesRead), labelHandler.GetLabels)
+			protected.POST("/labels", can(models.PermLabelsWrite), labelHandler.CreateLabel)
+			protected.PUT("/labels/:id", can(models.PermLabelsWrite), labelHandler.UpdateLabel)
+			protected.DELETE("/labels/:id", can(models.PermLabelsDelete), labelHandler.DeleteLabel)
+			protected.POST("/messages/labels", can(models.PermMessagesLabel), labelHandler.LabelMessages)
+			protected.POST("/messages/labels/remove", can(models.PermMessagesLabel), labelHandler.UnlabelMessages)
 
-			// Customer routes for authenticated users
-			protected.GET("/customers", customerHandler.GetCustomers)
+			// Roles and permissions
+			protected.GET("/permissions", can(models.PermUsersRead), roleHandler.GetPermissions)
+			protected.GET("/roles", can(models.PermUsersRead), roleHandler.GetRoles)
+			protected.POST("/roles", can(models.PermRolesManage), roleHandler.CreateRole)
+			protected.GET("/roles/:id", can(models.PermUsersRead), roleHandler.GetRole)
+			protected.PUT("/roles/:id", can(models.PermRolesManage), roleHandler.UpdateRole)
+			protected.DELETE("/roles/:id", can(models.PermRolesManage), roleHandler.DeleteRole)
 
-			// Admin only routes
-			admin := protected.Group("")
-			admin.Use(authMiddleware.RoleMiddleware(models.RoleAdmin))
+			// User management
+			protected.POST("/users", can(models.PermUsersCreate), adminHandler.CreateUser)
+			protected.GET("/users", can(models.PermUsersRead), adminHandler.GetAllUsers)
+			protected.GET("/users/pending-approval", can(models.PermUsersRead), adminHandler.GetPendingUsers)
+			protected.PUT("/users/:id/role", can(models.PermUsersRoles), adminHandler.UpdateUserRole)
+			protected.PUT("/users/:id/approve", can(models.PermUsersApprove), adminHandler.ApproveUser)
+			protected.DELETE("/users/:id/reject", can(models.PermUsersApprove), adminHandler.RejectUser)
+
+			// Customers
+			protected.GET("/customers", can(models.PermCustomersRead), customerHandler.GetCustomers)
+			protected.GET("/customers/:id", can(models.PermCustomersRead), customerHandler.GetCustomer)
+			protected.PUT("/customers/:id", can(models.PermCustomersWrite), customerHandler.UpdateCustomer)
+			protected.DELETE("/customers/:id", can(models.PermCustomersDelete), customerHandler.DeleteCustomer)
+			protected.PUT("/customers/:id/legal-hold", can(models.PermLegalHoldWrite), retentionHandler.SetCustomerLegalHold)
+			protected.GET("/customers/:id/timeline", can(models.PermCustomersRead), timelineHandler.GetCustomerTimeline)
+			protected.POST("/customers/:id/notes", can(models.PermCustomersNotes), timelineHandler.AddCustomerNote)
+
+			// Message search
+			protected.GET("/messages/search", can(models.PermMessagesRead), messageHandler.SearchMessages)
+
+			// Outbound webhooks
+			webhookRoutes := protected.Group("/webhooks", can(models.PermWebhooksManage))
+			{
+				webhookRoutes.POST("", webhookHandler.CreateWebhook)
+				webhookRoutes.GET("", webhookHandler.GetWebhooks)
+				webhookRoutes.GET("/:id", webhookHandler.GetWebhook)
+				webhookRoutes.PUT("/:id", webhookHandler.UpdateWebhook)
+				webhookRoutes.DELETE("/:id", webhookHandler.DeleteWebhook)
+				webhookRoutes.POST("/:id/rotate-secret", webhookHandler.RotateWebhookSecret)
+				webhookRoutes.GET("/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+				webhookRoutes.POST("/deliveries/:id/redeliver", webhookHandler.RedeliverWebhook)
+			}
+
+			// Message retention
+			protected.GET("/retention/policies", can(models.PermRetentionManage), retentionHandler.GetRetentionPolicies)
+			protected.POST("/retention/policies", can(models.PermRetentionManage), retentionHandler.CreateRetentionPolicy)
+			protected.PUT("/retention/policies/:id", can(models.PermRetentionManage), retentionHandler.UpdateRetentionPolicy)
+			protected.DELETE("/retention/policies/:id", can(models.PermRetentionManage), retentionHandler.DeleteRetentionPolicy)
+			protected.GET("/retention/report", can(models.PermRetentionManage), retentionHandler.GetRetentionReport)
+			protected.POST("/retention/purge", can(models.PermRetentionPurge), retentionHandler.RunRetentionPurge)
+			protected.PUT("/messages/:id/legal-hold", can(models.PermLegalHoldWrite), retentionHandler.SetMessageLegalHold)
+
+			// Message rules
+			ruleRoutes := protected.Group("/rules", can(models.PermRulesManage))
 			{
-				// User management
-				admin.POST("/users", adminHandler.CreateUser)
-				admin.GET("/users", adminHandler.GetAllUsers)
-				admin.GET("/users/pending-approval", adminHandler.GetPendingUsers)
-				admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
-				admin.PUT("/users/:id/approve", adminHandler.ApproveUser)
-				admin.DELETE("/users/:id/reject", adminHandler.RejectUser)
-
-				// Admin customer management (specific customer operations)
-				admin.GET("/customers/:id", customerHandler.GetCustomer)
-				admin.PUT("/customers/:id", customerHandler.UpdateCustomer)
-				admin.DELETE("/customers/:id", customerHandler.DeleteCustomer)
+				ruleRoutes.GET("", ruleHandler.GetRules)
+				ruleRoutes.POST("", ruleHandler.CreateRule)
+				ruleRoutes.POST("/test", ruleHandler.TestRule)
+				ruleRoutes.GET("/:id", ruleHandler.GetRule)
+				ruleRoutes.PUT("/:id", ruleHandler.UpdateRule)
+				ruleRoutes.DELETE("/:id", ruleHandler.DeleteRule)
+				ruleRoutes.GET("/:id/versions", ruleHandler.GetRuleVersions)
+				ruleRoutes.POST("/:id/versions/:version/restore", ruleHandler.RestoreRuleVersion)
 			}
+
+			// Rule and fraud signal alerts
+			protected.GET("/alerts", can(models.PermAlertsRead), alertHandler.GetAlerts)
+			protected.GET("/alerts/:id", can(models.PermAlertsRead), alertHandler.GetAlert)
+			protected.POST("/alerts/:id/acknowledge", can(models.PermAlertsWrite), alertHandler.AcknowledgeAlert)
+
+			// Case management
+			protected.GET("/cases", can(models.PermCasesRead), caseHandler.GetCases)
+			protected.POST("/cases", can(models.PermCasesWrite), caseHandler.CreateCase)
+			protected.GET("/cases/:id", can(models.PermCasesRead), caseHandler.GetCase)
+			protected.PUT("/cases/:id", can(models.PermCasesWrite), caseHandler.UpdateCase)
+			protected.POST("/cases/:id/assign", can(models.PermCasesWrite), caseHandler.AssignCase)
+			protected.POST("/cases/:id/transition", can(models.PermCasesWrite), caseHandler.TransitionCase)
+			protected.POST("/cases/:id/comments", can(models.PermCasesWrite), caseHandler.AddCaseComment)
+			protected.POST("/cases/:id/messages", can(models.PermCasesWrite), caseHandler.LinkCaseMessages)
+			protected.DELETE("/cases/:id/messages", can(models.PermCasesWrite), caseHandler.UnlinkCaseMessages)
+			protected.GET("/cases/:id/history", can(models.PermCasesRead), caseHandler.GetCaseHistory)
+
+			// Trash (soft-deleted rows)
+			protected.GET("/trash/messages", can(models.PermTrashRead), trashHandler.GetTrashedMessages)
+			protected.GET("/trash/customers", can(models.PermTrashRead), trashHandler.GetTrashedCustomers)
+			protected.GET("/trash/users", can(models.PermTrashRead), trashHandler.GetTrashedUsers)
+			protected.POST("/trash/messages/:id/restore", can(models.PermTrashRestore), trashHandler.RestoreMessage)
+			protected.POST("/trash/customers/:id/restore", can(models.PermTrashRestore), trashHandler.RestoreCustomer)
+			protected.POST("/trash/users/:id/restore", can(models.PermTrashRestore), trashHandler.RestoreUser)
+			protected.DELETE("/trash/messages/:id", can(models.PermTrashPurge), trashHandler.PurgeMessage)
+			protected.DELETE("/trash/customers/:id", can(models.PermTrashPurge), trashHandler.PurgeCustomer)
+			protected.DELETE("/trash/users/:id", can(models.PermTrashPurge), trashHandler.PurgeUser)
+
+			// Audit log
+			protected.GET("/audit", can(models.PermAuditRead), auditHandler.GetAuditLog)
+			protected.GET("/audit/export", can(models.PermAuditRead), auditHandler.ExportAuditLog)
+			protected.GET("/audit/verify", can(models.PermAuditRead), auditHandler.VerifyAuditLog)
+		}
+
+		// Realtime message feed (token may also be passed as ?access_token=)
+		stream := apiV1.Group("/messages/stream")
+		stream.Use(authMiddleware.QueryTokenAuth(), authMiddleware.RequirePermission(models.PermMessagesRead))
+		{
+			stream.GET("", streamHandler.StreamMessages)
+			stream.GET("/ws", streamHandler.StreamMessagesWebSocket)
 		}
 
 		// ========================
@@ -187,7 +407,9 @@ func setupRoutes(router *gin.Engine) {
 		// MESSAGE ROUTES
 		// ========================
 		// Public message routes (for Android app)
-		apiV1.POST("/messages", messageHandler.CreateMessage)
+		// Ingest is recorded on customer timelines rather than the audit log
+		apiV1.POST("/messages", audit.Skip(), messageHandler.CreateMessage)
+		apiV1.POST("/messages/batch", audit.Skip(), messageHandler.CreateMessagesBatch)
 		apiV1.GET("/messages", messageHandler.GetMessages)
 		apiV1.GET("/messages/stats", messageHandler.GetMessageStats)
 		apiV1.GET("/messages/:id", messageHandler.GetMessage)
@@ -199,7 +421,7 @@ func setupRoutes(router *gin.Engine) {
 			utils.Success(c, "API is running", gin.H{"status": "operational"})
 		})
 
-		apiV1.GET("/_func-test", handlers.NewTestHandler().TestEndpoint)
+		apiV1.GET("/_func-test", handlers.NewTestHandler(a).TestEndpoint)
 	}
 
 	// Default route
@@ -213,6 +435,8 @@ func setupRoutes(router *gin.Engine) {
 				"/api/v1/auth/logout",
 				"/api/v1/auth/refresh",
 				"/api/v1/profile",
+				"/api/v1/organization",
+				"/api/v1/organizations",
 				"/api/v1/stats",              // Added
 				"/api/v1/messages/recent",    // Added
 				"/api/v1/customers/top",      // Added
@@ -222,9 +446,27 @@ func setupRoutes(router *gin.Engine) {
 				"/api/v1/customers",         // All authenticated users
 				"/api/v1/customers/profile", // User's own profile
 				"/api/v1/messages",
+				"/api/v1/messages/batch",
 				"/api/v1/messages/stats",
+				"/api/v1/messages/search",
+				"/api/v1/messages/stream",
+				"/api/v1/messages/stream/ws",
+				"/api/v1/webhooks",
+				"/api/v1/retention/policies",
+				"/api/v1/retention/report",
+				"/api/v1/trash/messages",
+				"/api/v1/trash/customers",
+				"/api/v1/trash/users",
+				"/api/v1/rules",
+				"/api/v1/labels",
+				"/api/v1/alerts",
+				"/api/v1/cases",
+				"/api/v1/customers/:id/timeline",
+				"/api/v1/audit",
+				"/api/v1/roles",
+				"/api/v1/permissions",
+				"/api/v1/rules/test",
 				"/api/v1/status",
-				"/_seed/health",
 			},
 		})
 	})
@@ -0,0 +1,865 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+
+	"message-backend/internal/app"
+	"message-backend/internal/auth"
+	"message-backend/internal/clock"
+	"message-backend/internal/config"
+	"message-backend/internal/metrics"
+	"message-backend/internal/models"
+	"message-backend/internal/rbac"
+	"message-backend/internal/realtime"
+	"message-backend/internal/repository"
+	"message-backend/internal/rules"
+	"message-backend/internal/tenancy"
+	"message-backend/internal/types"
+)
+
+// testPassword is the password of every user the tests create
+const testPassword = "correct-horse-battery"
+
+// roleGuest grants nothing, so every permission check fails for it
+const roleGuest = "guest"
+
+// testEnv is the router built by setupRoutes on in-memory repositories, with
+// the fake database behind everything else
+type testEnv struct {
+	t      *testing.T
+	router *gin.Engine
+	app    *app.App
+	store  *repository.MemoryStore
+	db     *fakeDB
+	orgA   models.Organization
+	orgB   models.Organization
+}
+
+// fakeMailer drops every email
+type fakeMailer struct{}
+
+func (fakeMailer) SendSuperAdminCredentials(context.Context, string, string, string) error {
+	return nil
+}
+
+// testRoles are the roles the tests log in with. Admins, like the system role,
+// can't manage roles or organizations.
+func testRoles() []models.Role {
+	var admin []string
+	for _, info := range models.PermissionCatalog {
+		switch info.Name {
+		case models.PermOrgsManage, models.PermOrgsCrossTenant, models.PermRolesManage:
+		default:
+			admin = append(admin, info.Name)
+		}
+	}
+	return []models.Role{
+		{ID: uuid.New(), Name: models.RoleSuperAdmin, Permissions: []string{models.PermAll}, IsSystem: true},
+		{ID: uuid.New(), Name: models.RoleAdmin, Permissions: admin, IsSystem: true},
+		{ID: uuid.New(), Name: models.RoleUser, Permissions: []string{models.PermStatsRead, models.PermCustomersRead, models.PermMessagesRead}, IsSystem: true},
+		{ID: uuid.New(), Name: roleGuest, Permissions: []string{}},
+	}
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	env := &testEnv{
+		t:     t,
+		store: repository.NewMemoryStore(),
+		orgA: models.Organization{
+			ID: uuid.New(), Name: "Default", Slug: models.DefaultOrganizationSlug, IsActive: true,
+			Settings: models.OrganizationSettings{AllowSignup: true},
+		},
+		orgB: models.Organization{ID: uuid.New(), Name: "Other Bank", Slug: "other-bank", IsActive: true},
+	}
+
+	db, fake := newFakeDB(t, testRoles(), []models.Organization{env.orgA, env.orgB})
+	env.db = fake
+
+	cfg := &config.Config{
+		AppEnv:               "test",
+		JWTSecret:            strings.Repeat("s", 32),
+		JWTAccessExpiration:  15 * time.Minute,
+		JWTRefreshExpiration: 24 * time.Hour,
+	}
+	env.app = &app.App{
+		Config:  cfg,
+		DB:      db,
+		Mailer:  fakeMailer{},
+		JWT:     auth.NewJWTService(cfg, clock.System),
+		Clock:   clock.System,
+		Policy:  rbac.NewPolicy(db),
+		Orgs:    tenancy.NewDirectory(db),
+		Rules:   rules.NewEngine(db),
+		Broker:  realtime.NewBroker(db, ""),
+		Metrics: metrics.New(),
+	}
+
+	env.router = gin.New()
+	setupRoutes(env.router, env.app, env.store.Customers(), env.store.Messages(), env.store.Users())
+	return env
+}
+
+// addUser stores an approved, active user of an organization
+func (e *testEnv) addUser(org models.Organization, username, role string) *models.User {
+	e.t.Helper()
+	user := &models.User{
+		Username:   username,
+		Email:      username + "@example.com",
+		Role:       role,
+		IsActive:   true,
+		IsApproved: true,
+	}
+	if err := user.SetPassword(testPassword); err != nil {
+		e.t.Fatalf("set password: %v", err)
+	}
+	if err := e.store.Users().Create(tenancy.WithOrganization(context.Background(), org.ID), user); err != nil {
+		e.t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// addCustomer stores an active customer of an organization
+func (e *testEnv) addCustomer(org models.Organization, name, phone string) *models.Customer {
+	e.t.Helper()
+	customer := &models.Customer{
+		FullName:    name,
+		PhoneNumber: phone,
+		DeviceID:    "device-" + phone,
+		TotalLimit:  1000,
+		CardNumber:  "4111111111111111",
+		IsActive:    true,
+		LastActive:  time.Now(),
+	}
+	if err := e.store.Customers().Create(tenancy.WithOrganization(context.Background(), org.ID), customer); err != nil {
+		e.t.Fatalf("create customer %s: %v", name, err)
+	}
+	return customer
+}
+
+// addMessage stores a message of a customer
+func (e *testEnv) addMessage(customer *models.Customer, content string) *models.Message {
+	e.t.Helper()
+	message := &models.Message{
+		OrganizationID: customer.OrganizationID,
+		CustomerID:     customer.ID,
+		Sender:         "VK-HDFCBK",
+		Content:        content,
+		Timestamp:      time.Now(),
+	}
+	ctx := tenancy.WithOrganization(context.Background(), customer.OrganizationID)
+	if _, err := e.store.Messages().Create(ctx, message, nil); err != nil {
+		e.t.Fatalf("create message: %v", err)
+	}
+	return message
+}
+
+// token returns an access token for user
+func (e *testEnv) token(user *models.User) string {
+	e.t.Helper()
+	token, err := e.app.JWT.GenerateAccessToken(user, e.app.Config)
+	if err != nil {
+		e.t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+// request sends a request through the router. headers alternate names and values.
+func (e *testEnv) request(method, path, token string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// apiResponse is the envelope of every utils response
+type apiResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// expect checks a response's status code and decodes its data into out, if given
+func expect(t *testing.T, rec *httptest.ResponseRecorder, status int, out interface{}) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body.String())
+	}
+	if out == nil {
+		return
+	}
+	var resp apiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v; body: %s", err, rec.Body.String())
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		t.Fatalf("decode data: %v; data: %s", err, resp.Data)
+	}
+}
+
+func TestPublicRoutes(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, path := range []string{"/", "/health", "/api/v1/status"} {
+		t.Run(path, func(t *testing.T) {
+			expect(t, env.request(http.MethodGet, path, "", nil), http.StatusOK, nil)
+		})
+	}
+}
+
+func TestSignup(t *testing.T) {
+	env := newTestEnv(t)
+	body := gin.H{"username": "newteller", "email": "newteller@example.com", "password": testPassword}
+
+	var created struct {
+		User models.User `json:"user"`
+	}
+	expect(t, env.request(http.MethodPost, "/api/v1/auth/signup", "", body), http.StatusCreated, &created)
+	if created.User.OrganizationID != env.orgA.ID || created.User.Role != models.RoleUser || created.User.IsApproved {
+		t.Errorf("signup created %+v, want an unapproved user of the default organization", created.User)
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		expect(t, env.request(http.MethodPost, "/api/v1/auth/signup", "", body), http.StatusConflict, nil)
+	})
+	t.Run("organization without signup", func(t *testing.T) {
+		other := gin.H{"username": "elsewhere", "email": "elsewhere@example.com", "password": testPassword}
+		rec := env.request(http.MethodPost, "/api/v1/auth/signup", "", other, tenancy.Header, env.orgB.Slug)
+		expect(t, rec, http.StatusForbidden, nil)
+	})
+}
+
+func TestLoginRefreshLogout(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	login := gin.H{"email": user.Email, "password": testPassword}
+	expect(t, env.request(http.MethodPost, "/api/v1/auth/login", "", login), http.StatusOK, &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login returned tokens %+v", tokens)
+	}
+	expect(t, env.request(http.MethodGet, "/api/v1/profile", tokens.AccessToken, nil), http.StatusOK, nil)
+
+	var refreshed struct {
+		AccessToken string `json:"access_token"`
+	}
+	refresh := gin.H{"refresh_token": tokens.RefreshToken}
+	expect(t, env.request(http.MethodPost, "/api/v1/auth/refresh", "", refresh), http.StatusOK, &refreshed)
+	if refreshed.AccessToken == "" {
+		t.Error("refresh returned no access token")
+	}
+
+	expect(t, env.request(http.MethodPost, "/api/v1/auth/logout", tokens.AccessToken, nil), http.StatusOK, nil)
+
+	t.Run("wrong password", func(t *testing.T) {
+		rec := env.request(http.MethodPost, "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": "wrong-password"})
+		expect(t, rec, http.StatusUnauthorized, nil)
+	})
+	t.Run("unknown email", func(t *testing.T) {
+		rec := env.request(http.MethodPost, "/api/v1/auth/login", "", gin.H{"email": "nobody@example.com", "password": testPassword})
+		expect(t, rec, http.StatusUnauthorized, nil)
+	})
+	t.Run("access token as refresh token", func(t *testing.T) {
+		rec := env.request(http.MethodPost, "/api/v1/auth/refresh", "", gin.H{"refresh_token": tokens.AccessToken})
+		expect(t, rec, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestProfile(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+
+	var profile models.User
+	expect(t, env.request(http.MethodGet, "/api/v1/profile", env.token(user), nil), http.StatusOK, &profile)
+	if profile.ID != user.ID || len(profile.Permissions) != 3 {
+		t.Errorf("profile = %s with %v, want %s with the user role's 3 permissions", profile.ID, profile.Permissions, user.ID)
+	}
+}
+
+// publicRoutes need no staff token; everything else under /api/v1 does
+var publicRoutes = map[string]bool{
+	"POST /api/v1/auth/signup":    true,
+	"POST /api/v1/auth/login":     true,
+	"POST /api/v1/auth/logout":    true,
+	"POST /api/v1/auth/refresh":   true,
+	"POST /api/v1/customers":      true,
+	"POST /api/v1/messages":       true,
+	"POST /api/v1/messages/batch": true,
+	"GET /api/v1/messages":        true,
+	"GET /api/v1/messages/stats":  true,
+	"GET /api/v1/messages/:id":    true,
+	"PUT /api/v1/messages/:id":    true,
+	"DELETE /api/v1/messages/:id": true,
+	"GET /api/v1/status":          true,
+	"GET /api/v1/_func-test":      true,
+}
+
+// routePath fills a route's parameters with values that parse
+func routePath(route string) string {
+	path := strings.ReplaceAll(route, ":id", uuid.NewString())
+	return strings.ReplaceAll(path, ":version", "1")
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	env := newTestEnv(t)
+	inactive := env.addUser(env.orgA, "leaver", models.RoleSuperAdmin)
+	inactive.IsActive = false
+	if err := env.store.Users().Trash(tenancy.WithOrganization(context.Background(), env.orgA.ID), inactive); err != nil {
+		t.Fatalf("trash user: %v", err)
+	}
+
+	for _, route := range env.router.Routes() {
+		key := route.Method + " " + route.Path
+		if !strings.HasPrefix(route.Path, "/api/v1/") || publicRoutes[key] {
+			continue
+		}
+		t.Run(key, func(t *testing.T) {
+			path := routePath(route.Path)
+			expect(t, env.request(route.Method, path, "", nil), http.StatusUnauthorized, nil)
+			expect(t, env.request(route.Method, path, "not-a-token", nil), http.StatusUnauthorized, nil)
+			expect(t, env.request(route.Method, path, env.token(inactive), nil), http.StatusUnauthorized, nil)
+		})
+	}
+}
+
+// routePermissions is the permission each protected route checks first
+var routePermissions = map[string]string{
+	"PUT /api/v1/organization/settings":                models.PermOrgSettings,
+	"GET /api/v1/organizations":                        models.PermOrgsManage,
+	"POST /api/v1/organizations":                       models.PermOrgsManage,
+	"GET /api/v1/organizations/:id":                    models.PermOrgsManage,
+	"PUT /api/v1/organizations/:id":                    models.PermOrgsManage,
+	"GET /api/v1/stats":                                models.PermStatsRead,
+	"GET /api/v1/messages/recent":                      models.PermMessagesRead,
+	"GET /api/v1/customers/top":                        models.PermCustomersRead,
+	"GET /api/v1/customers/search":                     models.PermCustomersRead,
+	"GET /api/v1/labels":                               models.PermMessagesRead,
+	"POST /api/v1/labels":                              models.PermLabelsWrite,
+	"PUT /api/v1/labels/:id":                           models.PermLabelsWrite,
+	"DELETE /api/v1/labels/:id":                        models.PermLabelsDelete,
+	"POST /api/v1/messages/labels":                     models.PermMessagesLabel,
+	"POST /api/v1/messages/labels/remove":              models.PermMessagesLabel,
+	"GET /api/v1/permissions":                          models.PermUsersRead,
+	"GET /api/v1/roles":                                models.PermUsersRead,
+	"POST /api/v1/roles":                               models.PermRolesManage,
+	"GET /api/v1/roles/:id":                            models.PermUsersRead,
+	"PUT /api/v1/roles/:id":                            models.PermRolesManage,
+	"DELETE /api/v1/roles/:id":                         models.PermRolesManage,
+	"POST /api/v1/users":                               models.PermUsersCreate,
+	"GET /api/v1/users":                                models.PermUsersRead,
+	"GET /api/v1/users/pending-approval":               models.PermUsersRead,
+	"PUT /api/v1/users/:id/role":                       models.PermUsersRoles,
+	"PUT /api/v1/users/:id/approve":                    models.PermUsersApprove,
+	"DELETE /api/v1/users/:id/reject":                  models.PermUsersApprove,
+	"GET /api/v1/customers":                            models.PermCustomersRead,
+	"GET /api/v1/customers/:id":                        models.PermCustomersRead,
+	"PUT /api/v1/customers/:id":                        models.PermCustomersWrite,
+	"DELETE /api/v1/customers/:id":                     models.PermCustomersDelete,
+	"PUT /api/v1/customers/:id/legal-hold":             models.PermLegalHoldWrite,
+	"GET /api/v1/customers/:id/timeline":               models.PermCustomersRead,
+	"POST /api/v1/customers/:id/notes":                 models.PermCustomersNotes,
+	"GET /api/v1/messages/search":                      models.PermMessagesRead,
+	"POST /api/v1/webhooks":                            models.PermWebhooksManage,
+	"GET /api/v1/webhooks":                             models.PermWebhooksManage,
+	"GET /api/v1/webhooks/:id":                         models.PermWebhooksManage,
+	"PUT /api/v1/webhooks/:id":                         models.PermWebhooksManage,
+	"DELETE /api/v1/webhooks/:id":                      models.PermWebhooksManage,
+	"POST /api/v1/webhooks/:id/rotate-secret":          models.PermWebhooksManage,
+	"GET /api/v1/webhooks/:id/deliveries":              models.PermWebhooksManage,
+	"POST /api/v1/webhooks/deliveries/:id/redeliver":   models.PermWebhooksManage,
+	"GET /api/v1/retention/policies":                   models.PermRetentionManage,
+	"POST /api/v1/retention/policies":                  models.PermRetentionManage,
+	"PUT /api/v1/retention/policies/:id":               models.PermRetentionManage,
+	"DELETE /api/v1/retention/policies/:id":            models.PermRetentionManage,
+	"GET /api/v1/retention/report":                     models.PermRetentionManage,
+	"POST /api/v1/retention/purge":                     models.PermRetentionPurge,
+	"PUT /api/v1/messages/:id/legal-hold":              models.PermLegalHoldWrite,
+	"GET /api/v1/rules":                                models.PermRulesManage,
+	"POST /api/v1/rules":                               models.PermRulesManage,
+	"POST /api/v1/rules/test":                          models.PermRulesManage,
+	"GET /api/v1/rules/:id":                            models.PermRulesManage,
+	"PUT /api/v1/rules/:id":                            models.PermRulesManage,
+	"DELETE /api/v1/rules/:id":                         models.PermRulesManage,
+	"GET /api/v1/rules/:id/versions":                   models.PermRulesManage,
+	"POST /api/v1/rules/:id/versions/:version/restore": models.PermRulesManage,
+	"GET /api/v1/alerts":                               models.PermAlertsRead,
+	"GET /api/v1/alerts/:id":                           models.PermAlertsRead,
+	"POST /api/v1/alerts/:id/acknowledge":              models.PermAlertsWrite,
+	"GET /api/v1/cases":                                models.PermCasesRead,
+	"POST /api/v1/cases":                               models.PermCasesWrite,
+	"GET /api/v1/cases/:id":                            models.PermCasesRead,
+	"PUT /api/v1/cases/:id":                            models.PermCasesWrite,
+	"POST /api/v1/cases/:id/assign":                    models.PermCasesWrite,
+	"POST /api/v1/cases/:id/transition":                models.PermCasesWrite,
+	"POST /api/v1/cases/:id/comments":                  models.PermCasesWrite,
+	"POST /api/v1/cases/:id/messages":                  models.PermCasesWrite,
+	"DELETE /api/v1/cases/:id/messages":                models.PermCasesWrite,
+	"GET /api/v1/cases/:id/history":                    models.PermCasesRead,
+	"GET /api/v1/trash/messages":                       models.PermTrashRead,
+	"GET /api/v1/trash/customers":                      models.PermTrashRead,
+	"GET /api/v1/trash/users":                          models.PermTrashRead,
+	"POST /api/v1/trash/messages/:id/restore":          models.PermTrashRestore,
+	"POST /api/v1/trash/customers/:id/restore":         models.PermTrashRestore,
+	"POST /api/v1/trash/users/:id/restore":             models.PermTrashRestore,
+	"DELETE /api/v1/trash/messages/:id":                models.PermTrashPurge,
+	"DELETE /api/v1/trash/customers/:id":               models.PermTrashPurge,
+	"DELETE /api/v1/trash/users/:id":                   models.PermTrashPurge,
+	"GET /api/v1/audit":                                models.PermAuditRead,
+	"GET /api/v1/audit/export":                         models.PermAuditRead,
+	"GET /api/v1/audit/verify":                         models.PermAuditRead,
+	"GET /api/v1/messages/stream":                      models.PermMessagesRead,
+	"GET /api/v1/messages/stream/ws":                   models.PermMessagesRead,
+}
+
+// tokenOnlyRoutes need a staff token but no permission
+var tokenOnlyRoutes = map[string]bool{
+	"GET /api/v1/profile":           true,
+	"GET /api/v1/organization":      true,
+	"GET /api/v1/customers/profile": true,
+	"PUT /api/v1/customers/profile": true,
+}
+
+func TestProtectedRoutesCheckPermissions(t *testing.T) {
+	env := newTestEnv(t)
+	guest := env.token(env.addUser(env.orgA, "guest", roleGuest))
+
+	for _, route := range env.router.Routes() {
+		key := route.Method + " " + route.Path
+		if !strings.HasPrefix(route.Path, "/api/v1/") || publicRoutes[key] || tokenOnlyRoutes[key] {
+			continue
+		}
+		permission, ok := routePermissions[key]
+		if !ok {
+			t.Errorf("%s has no permission in routePermissions", key)
+			continue
+		}
+		t.Run(key, func(t *testing.T) {
+			rec := env.request(route.Method, routePath(route.Path), guest, nil)
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want 403; body: %s", rec.Code, rec.Body.String())
+			}
+			var body struct {
+				MissingPermission string `json:"missing_permission"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.MissingPermission != permission {
+				t.Errorf("missing_permission = %q, want %q", body.MissingPermission, permission)
+			}
+		})
+	}
+}
+
+func TestOrganizationRoute(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+
+	var org models.Organization
+	expect(t, env.request(http.MethodGet, "/api/v1/organization", env.token(user), nil), http.StatusOK, &org)
+	if org.ID != env.orgA.ID {
+		t.Errorf("organization = %s, want %s", org.ID, env.orgA.ID)
+	}
+
+	t.Run("other organization without cross-tenant permission", func(t *testing.T) {
+		rec := env.request(http.MethodGet, "/api/v1/organization", env.token(user), nil, tenancy.Header, env.orgB.Slug)
+		expect(t, rec, http.StatusForbidden, nil)
+	})
+}
+
+func TestStats(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+	customer := env.addCustomer(env.orgA, "Asha Rao", "+919800000001")
+	env.addMessage(customer, "Rs. 500 debited from A/c XX1234")
+	env.addMessage(customer, "Rs. 200 credited to A/c XX1234")
+
+	var stats struct {
+		TotalCustomers   int64   `json:"totalCustomers"`
+		TotalMessages    int64   `json:"totalMessages"`
+		TotalCreditLimit float64 `json:"totalCreditLimit"`
+	}
+	expect(t, env.request(http.MethodGet, "/api/v1/stats", env.token(user), nil), http.StatusOK, &stats)
+	if stats.TotalCustomers != 1 || stats.TotalMessages != 2 || stats.TotalCreditLimit != 1000 {
+		t.Errorf("stats = %+v, want 1 customer, 2 messages and a 1000 limit", stats)
+	}
+}
+
+func TestCustomerRoutes(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.token(env.addUser(env.orgA, "manager", models.RoleAdmin))
+	reader := env.token(env.addUser(env.orgA, "teller", models.RoleUser))
+	customer := env.addCustomer(env.orgA, "Asha Rao", "+919800000001")
+	env.addCustomer(env.orgA, "Vikram Shah", "+919800000002")
+	path := "/api/v1/customers/" + customer.ID.String()
+
+	t.Run("create", func(t *testing.T) {
+		body := gin.H{"phone_number": "+919800000003", "full_name": "Meera Iyer", "device_id": "device-new"}
+		var created models.Customer
+		expect(t, env.request(http.MethodPost, "/api/v1/customers", "", body), http.StatusCreated, &created)
+		if created.OrganizationID != env.orgA.ID {
+			t.Errorf("customer created in %s, want the default organization", created.OrganizationID)
+		}
+		expect(t, env.request(http.MethodPost, "/api/v1/customers", "", body), http.StatusConflict, nil)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		var list struct {
+			Customers []models.Customer `json:"customers"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/customers", admin, nil), http.StatusOK, &list)
+		if len(list.Customers) != 3 {
+			t.Errorf("listed %d customers, want 3", len(list.Customers))
+		}
+	})
+
+	t.Run("top", func(t *testing.T) {
+		var top struct {
+			Customers []struct {
+				ID string `json:"id"`
+			} `json:"customers"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/customers/top?limit=1", admin, nil), http.StatusOK, &top)
+		if len(top.Customers) != 1 {
+			t.Errorf("top returned %d customers, want 1", len(top.Customers))
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		var found struct {
+			Customers []struct {
+				ID string `json:"id"`
+			} `json:"customers"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/customers/search?q=asha", admin, nil), http.StatusOK, &found)
+		if len(found.Customers) != 1 || found.Customers[0].ID != customer.ID.String() {
+			t.Errorf("search found %+v, want only %s", found.Customers, customer.ID)
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/customers/search", admin, nil), http.StatusBadRequest, nil)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		var got models.Customer
+		expect(t, env.request(http.MethodGet, path, admin, nil), http.StatusOK, &got)
+		if got.CardNumber != customer.CardNumber {
+			t.Errorf("card number = %q, want it unmasked for admins", got.CardNumber)
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/customers/"+uuid.NewString(), admin, nil), http.StatusNotFound, nil)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		var updated models.Customer
+		expect(t, env.request(http.MethodPut, path, admin, gin.H{"full_name": "Asha R."}), http.StatusOK, &updated)
+		if updated.FullName != "Asha R." {
+			t.Errorf("full name = %q, want the update", updated.FullName)
+		}
+		expect(t, env.request(http.MethodPut, path, reader, gin.H{"full_name": "Nope"}), http.StatusForbidden, nil)
+	})
+
+	t.Run("own profile", func(t *testing.T) {
+		var profile models.Customer
+		rec := env.request(http.MethodGet, "/api/v1/customers/profile", reader, nil, "X-Device-ID", customer.DeviceID)
+		expect(t, rec, http.StatusOK, &profile)
+		if profile.ID != customer.ID {
+			t.Errorf("profile = %s, want %s", profile.ID, customer.ID)
+		}
+
+		rec = env.request(http.MethodPut, "/api/v1/customers/profile", reader, gin.H{"name": "Asha"}, "X-Device-ID", customer.DeviceID)
+		expect(t, rec, http.StatusOK, &profile)
+		if profile.Name != "Asha" {
+			t.Errorf("name = %q, want the update", profile.Name)
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/customers/profile", reader, nil), http.StatusBadRequest, nil)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		expect(t, env.request(http.MethodDelete, path, admin, nil), http.StatusOK, nil)
+		expect(t, env.request(http.MethodGet, path, admin, nil), http.StatusNotFound, nil)
+	})
+}
+
+func TestMessageRoutes(t *testing.T) {
+	env := newTestEnv(t)
+	reader := env.token(env.addUser(env.orgA, "teller", models.RoleUser))
+	customer := env.addCustomer(env.orgA, "Asha Rao", "+919800000001")
+	stored := env.addMessage(customer, "Rs. 500 debited from A/c XX1234")
+	asCustomer := []string{"X-Customer-ID", customer.ID.String()}
+	path := "/api/v1/messages/" + stored.ID.String()
+
+	t.Run("create", func(t *testing.T) {
+		body := gin.H{"customer_id": customer.ID, "content": "Your OTP is 123456", "sender": "VK-HDFCBK", "client_id": "sms-1"}
+		var created models.Message
+		expect(t, env.request(http.MethodPost, "/api/v1/messages", "", body), http.StatusCreated, &created)
+		if created.OrganizationID != env.orgA.ID || created.CustomerID != customer.ID {
+			t.Errorf("message stored for %s/%s, want the customer's", created.OrganizationID, created.CustomerID)
+		}
+		expect(t, env.request(http.MethodPost, "/api/v1/messages", "", body), http.StatusOK, nil)
+
+		unknown := gin.H{"customer_id": uuid.New(), "content": "hello"}
+		expect(t, env.request(http.MethodPost, "/api/v1/messages", "", unknown), http.StatusNotFound, nil)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		var list struct {
+			Messages []models.Message `json:"messages"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/messages", "", nil, asCustomer...), http.StatusOK, &list)
+		if len(list.Messages) != 2 {
+			t.Errorf("listed %d messages, want 2", len(list.Messages))
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/messages", "", nil), http.StatusBadRequest, nil)
+	})
+
+	t.Run("recent", func(t *testing.T) {
+		var recent struct {
+			Messages []struct {
+				Sender string `json:"sender"`
+			} `json:"messages"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/messages/recent", reader, nil), http.StatusOK, &recent)
+		if len(recent.Messages) != 2 || recent.Messages[0].Sender != customer.FullName {
+			t.Errorf("recent = %+v, want 2 messages from %s", recent.Messages, customer.FullName)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		var stats repository.MessageStats
+		expect(t, env.request(http.MethodGet, "/api/v1/messages/stats", "", nil, asCustomer...), http.StatusOK, &stats)
+		if stats.TotalMessages != 2 {
+			t.Errorf("stats = %+v, want 2 messages", stats)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		var got models.Message
+		expect(t, env.request(http.MethodGet, path, "", nil, asCustomer...), http.StatusOK, &got)
+		if got.ID != stored.ID {
+			t.Errorf("got message %s, want %s", got.ID, stored.ID)
+		}
+		expect(t, env.request(http.MethodGet, path, "", nil), http.StatusBadRequest, nil)
+	})
+
+	t.Run("star", func(t *testing.T) {
+		expect(t, env.request(http.MethodPut, path, "", gin.H{"starred": true}, asCustomer...), http.StatusOK, nil)
+		var got models.Message
+		expect(t, env.request(http.MethodGet, path, "", nil, asCustomer...), http.StatusOK, &got)
+		if !got.Starred {
+			t.Error("message was not starred")
+		}
+		expect(t, env.request(http.MethodPut, path, "", gin.H{}, asCustomer...), http.StatusBadRequest, nil)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		expect(t, env.request(http.MethodDelete, path, "", nil, asCustomer...), http.StatusOK, nil)
+		expect(t, env.request(http.MethodGet, path, "", nil, asCustomer...), http.StatusNotFound, nil)
+	})
+}
+
+func TestMessageBatchNeedsRetryKey(t *testing.T) {
+	env := newTestEnv(t)
+	customer := env.addCustomer(env.orgA, "Asha Rao", "+919800000001")
+
+	body := gin.H{"messages": []gin.H{{"customer_id": customer.ID, "content": "Rs. 500 debited from A/c XX1234"}}}
+	var batch types.BatchCreateMessageResponse
+	expect(t, env.request(http.MethodPost, "/api/v1/messages/batch", "", body), http.StatusOK, &batch)
+	if batch.Failed != 1 || len(batch.Results) != 1 || batch.Results[0].Error != "timestamp or client_id is required" {
+		t.Errorf("batch = %+v, want the item rejected for lacking a timestamp or client ID", batch)
+	}
+}
+
+func TestUserRoutes(t *testing.T) {
+	env := newTestEnv(t)
+	adminUser := env.addUser(env.orgA, "manager", models.RoleAdmin)
+	admin := env.token(adminUser)
+	teller := env.addUser(env.orgA, "teller", models.RoleUser)
+
+	pending := &models.User{Username: "applicant", Email: "applicant@example.com", Role: models.RoleUser, IsActive: true}
+	if err := pending.SetPassword(testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.Users().Create(tenancy.WithOrganization(context.Background(), env.orgA.ID), pending); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("create", func(t *testing.T) {
+		body := gin.H{"username": "auditor", "email": "auditor@example.com", "password": testPassword, "role": models.RoleUser}
+		var created models.User
+		expect(t, env.request(http.MethodPost, "/api/v1/users", admin, body), http.StatusCreated, &created)
+		if created.OrganizationID != env.orgA.ID {
+			t.Errorf("user created in %s, want the admin's organization", created.OrganizationID)
+		}
+		expect(t, env.request(http.MethodPost, "/api/v1/users", admin, body), http.StatusConflict, nil)
+
+		body = gin.H{"username": "boss", "email": "boss@example.com", "password": testPassword, "role": models.RoleSuperAdmin}
+		expect(t, env.request(http.MethodPost, "/api/v1/users", admin, body), http.StatusForbidden, nil)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		var list struct {
+			Users []models.User `json:"users"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/users", admin, nil), http.StatusOK, &list)
+		for _, user := range list.Users {
+			if user.ID == adminUser.ID {
+				t.Error("user list includes the viewer")
+			}
+		}
+		if len(list.Users) != 3 {
+			t.Errorf("listed %d users, want 3", len(list.Users))
+		}
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		var list struct {
+			Users []models.User `json:"users"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/users/pending-approval", admin, nil), http.StatusOK, &list)
+		if len(list.Users) != 2 {
+			t.Errorf("listed %d pending users, want 2", len(list.Users))
+		}
+	})
+
+	t.Run("approve", func(t *testing.T) {
+		path := "/api/v1/users/" + pending.ID.String() + "/approve"
+		var approved models.User
+		expect(t, env.request(http.MethodPut, path, admin, nil), http.StatusOK, &approved)
+		if !approved.IsApproved {
+			t.Error("user was not approved")
+		}
+		expect(t, env.request(http.MethodPut, path, admin, nil), http.StatusBadRequest, nil)
+	})
+
+	t.Run("role", func(t *testing.T) {
+		path := "/api/v1/users/" + teller.ID.String() + "/role"
+		var updated models.User
+		expect(t, env.request(http.MethodPut, path, admin, gin.H{"role": roleGuest}), http.StatusOK, &updated)
+		if updated.Role != roleGuest {
+			t.Errorf("role = %q, want %q", updated.Role, roleGuest)
+		}
+		self := "/api/v1/users/" + adminUser.ID.String() + "/role"
+		expect(t, env.request(http.MethodPut, self, admin, gin.H{"role": models.RoleUser}), http.StatusForbidden, nil)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		var list struct {
+			Users []models.User `json:"users"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/users/pending-approval", admin, nil), http.StatusOK, &list)
+		if len(list.Users) != 1 {
+			t.Fatalf("listed %d pending users, want 1", len(list.Users))
+		}
+		path := "/api/v1/users/" + list.Users[0].ID.String() + "/reject"
+		expect(t, env.request(http.MethodDelete, path, admin, nil), http.StatusOK, nil)
+		expect(t, env.request(http.MethodDelete, path, admin, nil), http.StatusNotFound, nil)
+	})
+}
+
+func TestMessageStream(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+	server := httptest.NewServer(env.router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/messages/stream?access_token="+env.token(user), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Errorf("stream answered %d %q, want 200 text/event-stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestMessageStreamWebSocketOrigin(t *testing.T) {
+	env := newTestEnv(t)
+	env.app.Config.CORSAllowedOrigins = []string{"https://app.example.com"}
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+	server := httptest.NewServer(env.router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/messages/stream/ws?access_token=" + env.token(user)
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com":  true,
+		"https://APP.example.com":  true,
+		"https://evil.example.com": false,
+	} {
+		wsConfig, err := websocket.NewConfig(url, origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws, err := websocket.DialConfig(wsConfig)
+		if err == nil {
+			ws.Close()
+		}
+		if (err == nil) != allowed {
+			t.Errorf("origin %s: dial error %v, want allowed = %v", origin, err, allowed)
+		}
+	}
+}
+
+func TestTrashedSuperAdminsNeedSuperAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	tokens := map[string]string{
+		models.RoleAdmin:      env.token(env.addUser(env.orgA, "admin", models.RoleAdmin)),
+		models.RoleSuperAdmin: env.token(env.addUser(env.orgA, "root", models.RoleSuperAdmin)),
+	}
+
+	for role, token := range tokens {
+		for _, route := range []string{"POST /api/v1/trash/users/:id/restore", "DELETE /api/v1/trash/users/:id"} {
+			method, path, _ := strings.Cut(route, " ")
+			env.db.reset()
+			env.request(method, routePath(path), token, nil)
+
+			statements := env.db.touching("users")
+			if len(statements) == 0 {
+				t.Fatalf("%s as %s: no statement touched users", route, role)
+			}
+			for _, statement := range statements {
+				if filtered := strings.Contains(statement.query, "role != "); filtered != (role != models.RoleSuperAdmin) {
+					t.Errorf("%s as %s: %s\nfilters super admins = %v", route, role, statement.query, filtered)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"message-backend/internal/models"
+	"message-backend/internal/realtime"
+	"message-backend/internal/retention"
+	"message-backend/internal/tenancy"
+)
+
+// expectScoped checks that the statements touching tables ran, and that each
+// of them is filtered to org and never mentions other
+func expectScoped(t *testing.T, statements []fakeStatement, org, other models.Organization) {
+	t.Helper()
+	if len(statements) == 0 {
+		t.Fatal("no statements ran")
+	}
+	for _, statement := range statements {
+		if !strings.Contains(statement.query, "organization_id") {
+			t.Errorf("statement is not filtered by organization: %s", statement.query)
+			continue
+		}
+		if !binds(statement, org.ID) {
+			t.Errorf("statement does not bind %s: %s %v", org.Slug, statement.query, statement.args)
+		}
+		if binds(statement, other.ID) {
+			t.Errorf("statement binds %s: %s %v", other.Slug, statement.query, statement.args)
+		}
+	}
+}
+
+// binds reports whether a statement has id among its arguments
+func binds(statement fakeStatement, id uuid.UUID) bool {
+	for _, arg := range statement.args {
+		if fmt.Sprint(arg) == id.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCustomersStayInTheirOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.token(env.addUser(env.orgA, "manager", models.RoleAdmin))
+	own := env.addCustomer(env.orgA, "Asha Rao", "+919800000001")
+	other := env.addCustomer(env.orgB, "Asha Verma", "+919800000002")
+	env.addMessage(own, "Rs. 500 debited from A/c XX1234")
+	env.addMessage(other, "Rs. 900 debited from A/c XX9876")
+	env.addMessage(other, "Rs. 100 credited to A/c XX9876")
+	path := "/api/v1/customers/" + other.ID.String()
+
+	var list struct {
+		Customers []models.Customer `json:"customers"`
+	}
+	expect(t, env.request(http.MethodGet, "/api/v1/customers", admin, nil), http.StatusOK, &list)
+	if len(list.Customers) != 1 || list.Customers[0].ID != own.ID {
+		t.Errorf("listed %+v, want only %s", list.Customers, own.ID)
+	}
+
+	for _, path := range []string{"/api/v1/customers/search?q=asha", "/api/v1/customers/top"} {
+		var found struct {
+			Customers []struct {
+				ID string `json:"id"`
+			} `json:"customers"`
+		}
+		expect(t, env.request(http.MethodGet, path, admin, nil), http.StatusOK, &found)
+		if len(found.Customers) != 1 || found.Customers[0].ID != own.ID.String() {
+			t.Errorf("%s found %+v, want only %s", path, found.Customers, own.ID)
+		}
+	}
+
+	var stats struct {
+		TotalCustomers int64 `json:"totalCustomers"`
+		TotalMessages  int64 `json:"totalMessages"`
+	}
+	expect(t, env.request(http.MethodGet, "/api/v1/stats", admin, nil), http.StatusOK, &stats)
+	if stats.TotalCustomers != 1 || stats.TotalMessages != 1 {
+		t.Errorf("stats = %+v, want 1 customer and 1 message", stats)
+	}
+
+	expect(t, env.request(http.MethodGet, path, admin, nil), http.StatusNotFound, nil)
+	expect(t, env.request(http.MethodPut, path, admin, gin.H{"full_name": "Taken"}), http.StatusNotFound, nil)
+	expect(t, env.request(http.MethodDelete, path, admin, nil), http.StatusNotFound, nil)
+
+	ctx := tenancy.WithOrganization(context.Background(), env.orgB.ID)
+	if stored, err := env.store.Customers().Get(ctx, other.ID); err != nil || stored.FullName != other.FullName {
+		t.Errorf("other organization's customer = %+v, %v; want it untouched", stored, err)
+	}
+}
+
+func TestMessagesStayInTheirOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	reader := env.token(env.addUser(env.orgA, "teller", models.RoleUser))
+	own := env.addCustomer(env.orgA, "Asha Rao", "+919800000001")
+	other := env.addCustomer(env.orgB, "Vikram Shah", "+919800000002")
+	ownMessage := env.addMessage(own, "Rs. 500 debited from A/c XX1234")
+	otherMessage := env.addMessage(other, "Rs. 900 debited from A/c XX9876")
+
+	var recent struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	expect(t, env.request(http.MethodGet, "/api/v1/messages/recent", reader, nil), http.StatusOK, &recent)
+	if len(recent.Messages) != 1 || recent.Messages[0].ID != ownMessage.ID.String() {
+		t.Errorf("recent = %+v, want only %s", recent.Messages, ownMessage.ID)
+	}
+
+	// Devices are scoped to the organization of the customer they act for
+	asOwn := []string{"X-Customer-ID", own.ID.String()}
+	var list struct {
+		Messages []models.Message `json:"messages"`
+	}
+	expect(t, env.request(http.MethodGet, "/api/v1/messages", "", nil, asOwn...), http.StatusOK, &list)
+	if len(list.Messages) != 1 || list.Messages[0].ID != ownMessage.ID {
+		t.Errorf("listed %d messages, want only %s", len(list.Messages), ownMessage.ID)
+	}
+	path := "/api/v1/messages/" + otherMessage.ID.String()
+	expect(t, env.request(http.MethodGet, path, "", nil, asOwn...), http.StatusNotFound, nil)
+	expect(t, env.request(http.MethodPut, path, "", gin.H{"starred": true}, asOwn...), http.StatusNotFound, nil)
+	expect(t, env.request(http.MethodDelete, path, "", nil, asOwn...), http.StatusNotFound, nil)
+}
+
+func TestUsersStayInTheirOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	adminUser := env.addUser(env.orgA, "manager", models.RoleAdmin)
+	admin := env.token(adminUser)
+	own := env.addUser(env.orgA, "teller", models.RoleUser)
+	other := env.addUser(env.orgB, "clerk", models.RoleUser)
+	pending := &models.User{Username: "applicant", Email: "applicant@example.com", Role: models.RoleUser, IsActive: true}
+	if err := pending.SetPassword(testPassword); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	if err := env.store.Users().Create(tenancy.WithOrganization(context.Background(), env.orgB.ID), pending); err != nil {
+		t.Fatalf("create pending user: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/users", "/api/v1/users/pending-approval"} {
+		var list struct {
+			Users []models.User `json:"users"`
+		}
+		expect(t, env.request(http.MethodGet, path, admin, nil), http.StatusOK, &list)
+		for _, user := range list.Users {
+			if user.OrganizationID != env.orgA.ID {
+				t.Errorf("%s listed %s of another organization", path, user.Username)
+			}
+		}
+		if path == "/api/v1/users" && (len(list.Users) != 1 || list.Users[0].ID != own.ID) {
+			t.Errorf("listed %d users, want only %s", len(list.Users), own.Username)
+		}
+	}
+
+	userPath := "/api/v1/users/" + other.ID.String()
+	expect(t, env.request(http.MethodPut, userPath+"/role", admin, gin.H{"role": models.RoleAdmin}), http.StatusNotFound, nil)
+	expect(t, env.request(http.MethodPut, "/api/v1/users/"+pending.ID.String()+"/approve", admin, nil), http.StatusNotFound, nil)
+	expect(t, env.request(http.MethodDelete, "/api/v1/users/"+pending.ID.String()+"/reject", admin, nil), http.StatusNotFound, nil)
+}
+
+// TestDatabaseRoutesStayInTheirOrganization checks the SQL of the routes that
+// go straight to the database: every statement on the route's tables is
+// filtered to the caller's organization and never names another one
+func TestDatabaseRoutesStayInTheirOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.token(env.addUser(env.orgA, "manager", models.RoleAdmin))
+	id := uuid.NewString()
+
+	routes := []struct {
+		method, path string
+		body         interface{}
+		tables       []string
+	}{
+		{http.MethodGet, "/api/v1/cases", nil, []string{"cases"}},
+		{http.MethodGet, "/api/v1/cases/" + id, nil, []string{"cases"}},
+		{http.MethodGet, "/api/v1/cases/" + id + "/history", nil, []string{"cases", "case_events"}},
+		{http.MethodGet, "/api/v1/alerts", nil, []string{"alerts"}},
+		{http.MethodGet, "/api/v1/alerts/" + id, nil, []string{"alerts"}},
+		{http.MethodGet, "/api/v1/labels", nil, []string{"labels", "messages"}},
+		{http.MethodPost, "/api/v1/labels", gin.H{"name": "Fraud", "color": "#ff0000"}, []string{"labels"}},
+		{http.MethodGet, "/api/v1/rules", nil, []string{"rules"}},
+		{http.MethodGet, "/api/v1/rules/" + id, nil, []string{"rules"}},
+		{http.MethodPost, "/api/v1/rules/test", gin.H{"sample": gin.H{"content": "Rs. 500 debited"}}, []string{"rules"}},
+		{http.MethodGet, "/api/v1/retention/policies", nil, []string{"retention_policies"}},
+		{http.MethodPost, "/api/v1/retention/policies", gin.H{"name": "OTPs", "retain_days": 30}, []string{"retention_policies"}},
+		{http.MethodGet, "/api/v1/messages/search?q=debit", nil, []string{"messages"}},
+	}
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			env.db.reset()
+			rec := env.request(route.method, route.path, admin, route.body)
+			if rec.Code >= http.StatusInternalServerError {
+				t.Fatalf("status = %d; body: %s", rec.Code, rec.Body.String())
+			}
+			expectScoped(t, env.db.touching(route.tables...), env.orgA, env.orgB)
+		})
+	}
+}
+
+func TestTimelineStaysInItsOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.token(env.addUser(env.orgA, "manager", models.RoleAdmin))
+	own, other := uuid.New(), uuid.New()
+	env.db.seed("customers", []string{"id", "organization_id", "full_name"},
+		[]driver.Value{other.String(), env.orgB.ID.String(), "Vikram Shah"},
+		[]driver.Value{own.String(), env.orgA.ID.String(), "Asha Rao"},
+	)
+
+	expect(t, env.request(http.MethodGet, "/api/v1/customers/"+other.String()+"/timeline", admin, nil), http.StatusNotFound, nil)
+
+	env.db.reset()
+	expect(t, env.request(http.MethodGet, "/api/v1/customers/"+own.String()+"/timeline", admin, nil), http.StatusOK, nil)
+	expectScoped(t, env.db.touching("customers", "customer_events"), env.orgA, env.orgB)
+}
+
+func TestRetentionPoliciesStayInTheirOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.token(env.addUser(env.orgA, "manager", models.RoleAdmin))
+	own, other := uuid.New(), uuid.New()
+	env.db.seed("retention_policies", []string{"id", "organization_id", "name", "retain_days", "is_active"},
+		[]driver.Value{other.String(), env.orgB.ID.String(), "Keep a week", int64(7), true},
+		[]driver.Value{own.String(), env.orgA.ID.String(), "Keep a month", int64(30), true},
+	)
+
+	t.Run("list", func(t *testing.T) {
+		var list struct {
+			Policies []models.RetentionPolicy `json:"policies"`
+		}
+		expect(t, env.request(http.MethodGet, "/api/v1/retention/policies", admin, nil), http.StatusOK, &list)
+		if len(list.Policies) != 1 || list.Policies[0].ID != own {
+			t.Errorf("listed %+v, want only %s", list.Policies, own)
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		env.db.reset()
+		var report retention.Report
+		expect(t, env.request(http.MethodGet, "/api/v1/retention/report", admin, nil), http.StatusOK, &report)
+		if len(report.Policies) != 1 || report.Policies[0].PolicyID != own {
+			t.Errorf("report covers %+v, want only %s", report.Policies, own)
+		}
+		expectScoped(t, env.db.touching("retention_policies", "messages"), env.orgA, env.orgB)
+	})
+
+	t.Run("scheduled purge", func(t *testing.T) {
+		env.db.stub("pg_try_advisory_xact_lock", []string{"locked"}, []driver.Value{true})
+		env.db.reset()
+		if _, err := retention.NewPurger(env.app.DB, 1000).Purge(tenancy.System(context.Background())); err != nil {
+			t.Fatalf("purge: %v", err)
+		}
+
+		var deletes []fakeStatement
+		for _, statement := range env.db.touching("messages") {
+			if strings.Contains(statement.query, "DELETE FROM messages") {
+				deletes = append(deletes, statement)
+			}
+		}
+		if len(deletes) != 2 {
+			t.Fatalf("ran %d purge batches, want one per policy", len(deletes))
+		}
+		expectScoped(t, deletes[:1], env.orgB, env.orgA)
+		expectScoped(t, deletes[1:], env.orgA, env.orgB)
+	})
+}
+
+func TestStreamStaysInItsOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(env.orgA, "teller", models.RoleUser)
+	since := time.Now().Add(-time.Hour)
+	own, other := uuid.New(), uuid.New()
+	ownEvent := realtime.EventID(&models.Message{ID: own, CreatedAt: since.Add(2 * time.Minute)})
+	otherEvent := realtime.EventID(&models.Message{ID: other, CreatedAt: since.Add(time.Minute)})
+	env.db.seed("messages", []string{"id", "organization_id", "customer_id", "content", "created_at"},
+		[]driver.Value{other.String(), env.orgB.ID.String(), uuid.NewString(), "Rs. 900 debited", since.Add(time.Minute)},
+		[]driver.Value{own.String(), env.orgA.ID.String(), uuid.NewString(), "Rs. 500 debited", since.Add(2 * time.Minute)},
+	)
+
+	t.Run("replay", func(t *testing.T) {
+		server := httptest.NewServer(env.router)
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/messages/stream?access_token="+env.token(user), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Last-Event-ID", realtime.EventID(&models.Message{ID: uuid.Nil, CreatedAt: since}))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("stream answered %d, want 200", resp.StatusCode)
+		}
+
+		// The other organization's message is older, so it would be replayed first
+		lines := bufio.NewScanner(resp.Body)
+		for lines.Scan() {
+			switch strings.TrimSpace(strings.TrimPrefix(lines.Text(), "id:")) {
+			case otherEvent:
+				t.Fatal("replayed another organization's message")
+			case ownEvent:
+				return
+			}
+		}
+		t.Fatalf("stream ended before replaying %s: %v", ownEvent, lines.Err())
+	})
+
+	t.Run("live", func(t *testing.T) {
+		sub := env.app.Broker.Subscribe(tenancy.Scope{OrganizationID: env.orgA.ID}, nil)
+		defer env.app.Broker.Unsubscribe(sub)
+		if sub.Matches(env.orgB.ID, uuid.New()) {
+			t.Error("subscription matches another organization's messages")
+		}
+		if !sub.Matches(env.orgA.ID, uuid.New()) {
+			t.Error("subscription misses its own organization's messages")
+		}
+	})
+}